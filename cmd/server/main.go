@@ -9,10 +9,14 @@ import (
 	"path/filepath"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/joho/godotenv"
 	"github.com/rs/cors"
 
+	"github.com/michaelgalloway/sophia/internal/actions"
+	"github.com/michaelgalloway/sophia/internal/api"
+	"github.com/michaelgalloway/sophia/internal/briefing"
 	"github.com/michaelgalloway/sophia/internal/config"
 	"github.com/michaelgalloway/sophia/internal/database"
 	"github.com/michaelgalloway/sophia/internal/datasources"
@@ -22,8 +26,13 @@ import (
 	"github.com/michaelgalloway/sophia/internal/datasources/slack"
 	"github.com/michaelgalloway/sophia/internal/datasources/todoist"
 	"github.com/michaelgalloway/sophia/internal/embeddings"
+	"github.com/michaelgalloway/sophia/internal/notify"
+	"github.com/michaelgalloway/sophia/internal/pipeline"
+	"github.com/michaelgalloway/sophia/internal/prep"
+	"github.com/michaelgalloway/sophia/internal/retry"
 	"github.com/michaelgalloway/sophia/internal/scheduler"
 	"github.com/michaelgalloway/sophia/internal/service"
+	"github.com/michaelgalloway/sophia/internal/watch"
 )
 
 func initializeSources(ctx context.Context, sourceConfig config.DataSourceConfig, tokenDir string, googleCreds []byte) (map[string]datasources.DataSource, error) {
@@ -31,8 +40,12 @@ func initializeSources(ctx context.Context, sourceConfig config.DataSourceConfig
 
 	if sourceConfig.GoogleCalendar {
 		calendarConfig := map[string]interface{}{
-			"credentials": string(googleCreds),
-			"token_dir":   tokenDir,
+			"credentials":   string(googleCreds),
+			"token_dir":     tokenDir,
+			"calendar_ids":  config.GetEnvList("CALENDAR_IDS"),
+			"window_past":   config.GetEnvDuration("CALENDAR_WINDOW_PAST", 30*24*time.Hour),
+			"window_future": config.GetEnvDuration("CALENDAR_WINDOW_FUTURE", 90*24*time.Hour),
+			"allow_writes":  config.GetEnvBool("CALENDAR_ALLOW_WRITES", false),
 		}
 
 		calendarSource, err := gcalendar.New(calendarConfig)
@@ -47,8 +60,13 @@ func initializeSources(ctx context.Context, sourceConfig config.DataSourceConfig
 
 	if sourceConfig.Gmail {
 		gmailConfig := map[string]interface{}{
-			"credentials": string(googleCreds),
-			"token_dir":   tokenDir,
+			"credentials":    string(googleCreds),
+			"token_dir":      tokenDir,
+			"max_threads":    config.GetEnvInt("GMAIL_MAX_THREADS", 500),
+			"query":          os.Getenv("GMAIL_QUERY"),
+			"include_labels": config.GetEnvList("GMAIL_INCLUDE_LABELS"),
+			"exclude_labels": config.GetEnvList("GMAIL_EXCLUDE_LABELS"),
+			"allow_drafts":   config.GetEnvBool("GMAIL_ALLOW_DRAFTS", false),
 		}
 
 		gmailSource, err := gmail.New(gmailConfig)
@@ -63,8 +81,10 @@ func initializeSources(ctx context.Context, sourceConfig config.DataSourceConfig
 
 	if sourceConfig.GoogleDocs {
 		docsConfig := map[string]interface{}{
-			"credentials": string(googleCreds),
-			"token_dir":   tokenDir,
+			"credentials":   string(googleCreds),
+			"token_dir":     tokenDir,
+			"max_file_size": config.GetEnvInt("DRIVE_MAX_FILE_SIZE", 20<<20),
+			"mime_types":    config.GetEnvList("DRIVE_MIME_TYPES"),
 		}
 
 		docsSource, err := gdocs.New(docsConfig)
@@ -79,8 +99,13 @@ func initializeSources(ctx context.Context, sourceConfig config.DataSourceConfig
 
 	if sourceConfig.Slack {
 		slackConfig := map[string]interface{}{
-			"token":    os.Getenv("SLACK_TOKEN"),
-			"channels": strings.Split(os.Getenv("SLACK_CHANNELS"), ","),
+			"token":         os.Getenv("SLACK_TOKEN"),
+			"channels":      config.GetEnvList("SLACK_CHANNELS"),
+			"history":       config.GetEnvDuration("SLACK_HISTORY", 90*24*time.Hour),
+			"thread_window": config.GetEnvDuration("SLACK_THREAD_WINDOW", 7*24*time.Hour),
+			"include_dms":   config.GetEnvBool("SLACK_INCLUDE_DMS", false),
+			"index_files":   config.GetEnvBool("SLACK_INDEX_FILES", true),
+			"max_file_size": config.GetEnvInt("SLACK_MAX_FILE_SIZE", 10<<20),
 		}
 
 		slackSource, err := slack.New(slackConfig)
@@ -94,9 +119,18 @@ func initializeSources(ctx context.Context, sourceConfig config.DataSourceConfig
 	}
 
 	if sourceConfig.Todoist {
+		// Todoist filters were replaced by a project list; a leftover filter would
+		// otherwise silently widen the sync to every project
+		if filter := os.Getenv("TODOIST_FILTER"); filter != "" {
+			log.Printf("Warning: TODOIST_FILTER (%q) is no longer supported and is ignored; "+
+				"list the projects to index in TODOIST_PROJECTS instead", filter)
+		}
+
 		todoistConfig := map[string]interface{}{
-			"token":  os.Getenv("TODOIST_TOKEN"),
-			"filter": os.Getenv("TODOIST_FILTER"),
+			"token":             os.Getenv("TODOIST_TOKEN"),
+			"base_url":          config.GetEnv("TODOIST_BASE_URL", todoist.DefaultBaseURL),
+			"projects":          config.GetEnvList("TODOIST_PROJECTS"),
+			"completed_history": config.GetEnvDuration("TODOIST_COMPLETED_HISTORY", 90*24*time.Hour),
 		}
 
 		todoistSource, err := todoist.New(todoistConfig)
@@ -109,6 +143,141 @@ func initializeSources(ctx context.Context, sourceConfig config.DataSourceConfig
 	return sources, nil
 }
 
+// initializeActions sets up the actions the assistant may propose, based on the
+// enabled sources. It returns nil when no source supports actions.
+func initializeActions(sources map[string]datasources.DataSource) (*actions.Manager, error) {
+	var executors []actions.Executor
+	if todoistSource, ok := sources["todoist"].(*todoist.TodoistSource); ok {
+		executors = append(executors, actions.NewTodoistExecutors(todoistSource.Client())...)
+	}
+	if gmailSource, ok := sources["gmail"].(*gmail.GmailSource); ok && gmailSource.AllowsDrafts() {
+		executors = append(executors, actions.NewGmailExecutors(gmailSource.Service())...)
+	}
+	if calendarSource, ok := sources["google_calendar"].(*gcalendar.GoogleCalendarSource); ok && calendarSource.AllowsWrites() {
+		executors = append(executors, actions.NewCalendarExecutors(calendarSource.Service())...)
+	}
+	if len(executors) == 0 {
+		return nil, nil
+	}
+
+	audit, err := actions.NewAuditLog(config.GetEnv("ACTIONS_AUDIT_LOG", "./data/actions.jsonl"))
+	if err != nil {
+		return nil, err
+	}
+	return actions.NewManager(executors, audit, config.GetEnvDuration("ACTIONS_PENDING_TTL", actions.DefaultPendingTTL)), nil
+}
+
+// initializeNotifier sets up a notification sink for each destination configured
+// in the environment
+func initializeNotifier() (*notify.Notifier, error) {
+	var sinks []notify.Sink
+
+	if host := os.Getenv("NOTIFY_SMTP_HOST"); host != "" {
+		sink, err := notify.NewSMTPSink(notify.SMTPConfig{
+			Host:     host,
+			Port:     config.GetEnvInt("NOTIFY_SMTP_PORT", 587),
+			Username: os.Getenv("NOTIFY_SMTP_USERNAME"),
+			Password: os.Getenv("NOTIFY_SMTP_PASSWORD"),
+			From:     os.Getenv("NOTIFY_SMTP_FROM"),
+			To:       config.GetEnvList("NOTIFY_SMTP_TO"),
+		})
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, sink)
+	}
+
+	if channel := os.Getenv("NOTIFY_SLACK_CHANNEL"); channel != "" {
+		sink, err := notify.NewSlackSink(config.GetEnv("NOTIFY_SLACK_TOKEN", os.Getenv("SLACK_TOKEN")), channel)
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, sink)
+	}
+
+	if url := os.Getenv("NOTIFY_WEBHOOK_URL"); url != "" {
+		sink, err := notify.NewWebhookSink(url, os.Getenv("NOTIFY_WEBHOOK_SECRET"))
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, sink)
+	}
+
+	if topic := os.Getenv("NOTIFY_NTFY_TOPIC"); topic != "" {
+		server := config.GetEnv("NOTIFY_NTFY_SERVER", notify.DefaultNtfyServer)
+		token := os.Getenv("NOTIFY_NTFY_TOKEN")
+
+		// Topics on the public server are open to anyone who knows or guesses the name
+		if strings.TrimRight(server, "/") == notify.DefaultNtfyServer && token == "" {
+			log.Printf("Warning: ntfy notifications are published to the public server %s without a token; "+
+				"anyone subscribed to topic %q can read them. Set NOTIFY_NTFY_SERVER to your own server, "+
+				"or NOTIFY_NTFY_TOKEN for a reserved topic", server, topic)
+		}
+
+		sink, err := notify.NewNtfySink(server, topic, token)
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, sink)
+	}
+
+	notifyConfig := notify.Config{Retry: retry.DefaultPolicy()}
+	notifyConfig.Retry.MaxAttempts = config.GetEnvInt("NOTIFY_RETRY_ATTEMPTS", 3)
+
+	if dir := os.Getenv("NOTIFY_TEMPLATES_DIR"); dir != "" {
+		templates, err := notify.LoadTemplates(dir)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("Loaded notification templates for %v", templates.Kinds())
+		notifyConfig.Templates = templates
+	}
+
+	deliveryLog, err := notify.NewDeliveryLog(config.GetEnv("NOTIFY_DELIVERY_LOG", "./data/notifications.jsonl"))
+	if err != nil {
+		return nil, err
+	}
+	notifyConfig.Log = deliveryLog
+
+	return notify.NewNotifier(notifyConfig, sinks...), nil
+}
+
+// loadSchedulerConfig reads sync schedules from the environment. SYNC_SCHEDULE sets the
+// default and SYNC_SCHEDULE_<SOURCE> (e.g. SYNC_SCHEDULE_TODOIST=5m) overrides it per source.
+func loadSchedulerConfig(sources map[string]datasources.DataSource) (scheduler.Config, error) {
+	cfg := scheduler.Config{
+		DefaultSchedule:  config.GetEnv("SYNC_SCHEDULE", scheduler.DefaultSchedule),
+		Schedules:        make(map[string]string),
+		Jitter:           config.GetEnvDuration("SYNC_JITTER", 0),
+		Retry:            retry.DefaultPolicy(),
+		BreakerThreshold: config.GetEnvInt("SYNC_BREAKER_THRESHOLD", 5),
+		BreakerCooldown:  config.GetEnvDuration("SYNC_BREAKER_COOLDOWN", 30*time.Minute),
+		Pipeline: pipeline.Config{
+			Workers:   config.GetEnvInt("WORKER_THREADS", 4),
+			BatchSize: config.GetEnvInt("EMBEDDING_BATCH_SIZE", 100),
+			QueueSize: config.GetEnvInt("PIPELINE_QUEUE_SIZE", 0),
+		},
+	}
+	cfg.Retry.MaxAttempts = config.GetEnvInt("SYNC_RETRY_ATTEMPTS", cfg.Retry.MaxAttempts)
+	cfg.Retry.InitialInterval = config.GetEnvDuration("SYNC_RETRY_INTERVAL", cfg.Retry.InitialInterval)
+
+	for name := range sources {
+		if spec := config.GetEnv("SYNC_SCHEDULE_"+config.EnvKey(name), ""); spec != "" {
+			cfg.Schedules[name] = spec
+		}
+	}
+
+	if quiet := config.GetEnv("SYNC_QUIET_HOURS", ""); quiet != "" {
+		q, err := scheduler.ParseQuietHours(quiet)
+		if err != nil {
+			return cfg, err
+		}
+		cfg.QuietHours = q
+	}
+
+	return cfg, nil
+}
+
 func main() {
 	if err := godotenv.Load(); err != nil {
 		log.Printf("Warning: Error loading .env file: %v", err)
@@ -138,13 +307,6 @@ func main() {
 		log.Fatalf("Failed to initialize data sources: %v", err)
 	}
 
-	// Initialize embedding service
-	embeddingService := embeddings.NewOpenAIEmbedding(embeddings.Config{
-		OpenAIKey: os.Getenv("OPENAI_API_KEY"),
-		ModelName: "text-embedding-ada-002",
-		BatchSize: 100,
-	})
-
 	cfg := database.Config{
 		Host:     os.Getenv("POSTGRES_HOST"),
 		Port:     5432,
@@ -164,17 +326,93 @@ func main() {
 		log.Fatalf("Failed to initialize vector database: %v", err)
 	}
 
+	// Initialize embedding service
+	openAIEmbedding := embeddings.NewOpenAIEmbedding(embeddings.Config{
+		OpenAIKey:     os.Getenv("OPENAI_API_KEY"),
+		ModelName:     "text-embedding-3-small",
+		BatchSize:     config.GetEnvInt("EMBEDDING_BATCH_SIZE", 100),
+		MaxRetries:    config.GetEnvInt("EMBEDDING_MAX_RETRIES", 3),
+		RetryInterval: config.GetEnvInt("EMBEDDING_RETRY_INTERVAL", 2),
+
+		MaxBatchTokens:    config.GetEnvInt("EMBEDDING_MAX_BATCH_TOKENS", 200000),
+		RequestsPerMinute: config.GetEnvInt("EMBEDDING_REQUESTS_PER_MINUTE", 3000),
+		TokensPerMinute:   config.GetEnvInt("EMBEDDING_TOKENS_PER_MINUTE", 1000000),
+	})
+
+	var embeddingService embeddings.EmbeddingService = openAIEmbedding
+	var embeddingCache *embeddings.CachedEmbedding
+	if config.GetEnvBool("EMBEDDING_CACHE", true) {
+		embeddingCache = embeddings.NewCachedEmbedding(
+			openAIEmbedding,
+			vectorDB.EmbeddingCache(),
+			openAIEmbedding.Model(),
+			config.GetEnvInt("EMBEDDING_QUERY_CACHE_SIZE", 1000),
+		)
+		embeddingService = embeddingCache
+	}
+
 	// Create and start the scheduler
-	sched := scheduler.NewScheduler(sources, embeddingService, vectorDB)
+	schedConfig, err := loadSchedulerConfig(sources)
+	if err != nil {
+		log.Fatalf("Failed to load scheduler configuration: %v", err)
+	}
+
+	// Notification sinks for briefings, meeting prep and sync alerts
+	notifier, err := initializeNotifier()
+	if err != nil {
+		log.Fatalf("Failed to initialize notifications: %v", err)
+	}
+
+	sched := scheduler.NewScheduler(sources, embeddingService, vectorDB, schedConfig)
+	if after := config.GetEnvInt("SYNC_ALERT_AFTER", 3); after > 0 {
+		sched.OnRunFinished(scheduler.NewAlerter(notifier, after).RunFinished)
+	}
+
+	// Saved queries checked against every newly stored chunk, notified after each sync
+	watcher, err := watch.NewWatcher(config.GetEnv("WATCHES_FILE", "./data/watches.json"), embeddingService, notifier)
+	if err != nil {
+		log.Fatalf("Failed to load watches: %v", err)
+	}
+	sched.OnStored(watcher.Observe)
+	sched.OnRunFinished(watcher.RunFinished)
 	if err := sched.Start(ctx); err != nil {
 		log.Fatalf("Failed to start scheduler: %v", err)
 	}
 
+	// Actions the assistant can propose for the user to confirm
+	actionManager, err := initializeActions(sources)
+	if err != nil {
+		log.Fatalf("Failed to initialize actions: %v", err)
+	}
+
 	// Create the assistant service
 	assistant := service.NewAssistant(service.Config{
 		OpenAIKey: os.Getenv("OPENAI_API_KEY"),
 		ModelName: "gpt-4",
-	}, embeddingService, vectorDB)
+	}, embeddingService, vectorDB, actionManager)
+
+	// Daily briefing, generated each morning and on request
+	briefings := briefing.NewGenerator(vectorDB, assistant, notifier, briefing.Config{
+		SlackUserID:   os.Getenv("BRIEFING_SLACK_USER_ID"),
+		EmailLookback: config.GetEnvDuration("BRIEFING_EMAIL_LOOKBACK", 7*24*time.Hour),
+		SlackLookback: config.GetEnvDuration("BRIEFING_SLACK_LOOKBACK", 24*time.Hour),
+	})
+	if config.GetEnvBool("BRIEFING_ENABLED", true) {
+		if err := sched.AddJob("briefing", config.GetEnv("BRIEFING_SCHEDULE", "0 7 * * *"), briefings.Run); err != nil {
+			log.Fatalf("Failed to schedule briefing: %v", err)
+		}
+	}
+
+	// Meeting prep packs, delivered shortly before each meeting and on request
+	preps := prep.NewGenerator(vectorDB, embeddingService, assistant, notifier, prep.Config{
+		LeadTime: config.GetEnvDuration("PREP_LEAD_TIME", 15*time.Minute),
+		Lookback: config.GetEnvDuration("PREP_LOOKBACK", 30*24*time.Hour),
+	})
+	if config.GetEnvBool("PREP_ENABLED", true) {
+		if err := sched.AddJob("meeting prep", "1m", preps.Run); err != nil {
+			log.Fatalf("Failed to schedule meeting prep: %v", err)
+		}
+	}
 
 	// Set up HTTP handlers
 	mux := http.NewServeMux()
@@ -204,13 +442,49 @@ func main() {
 		}
 
 		w.Header().Set("Content-Type", "text/plain")
-		w.Write([]byte(response))
+		w.Write([]byte(response.Text))
 	})
 
+	// Semantic search without the chat model
+	api.NewSearchHandler(embeddingService, vectorDB).Register(mux)
+
+	// Browsing, inspecting and pruning the index
+	api.NewDocumentHandler(vectorDB).Register(mux)
+
+	// Questions with proposed actions, and their confirmation
+	api.NewAskHandler(assistant, actionManager).Register(mux)
+
+	// Daily briefing
+	api.NewBriefingHandler(briefings).Register(mux)
+
+	// Meeting prep packs
+	api.NewPrepHandler(preps).Register(mux)
+
+	// Notification deliveries and a test message
+	api.NewNotifyHandler(notifier).Register(mux)
+
+	// Saved queries and their matches
+	api.NewWatchHandler(watcher).Register(mux)
+
+	// Sync status, history and manual triggers
+	api.NewSyncHandler(sched).Register(mux)
+
+	// Real-time Slack ingestion through the Events API
+	if slackSource, ok := sources["slack"].(*slack.SlackSource); ok {
+		if secret := os.Getenv("SLACK_SIGNING_SECRET"); secret != "" {
+			mux.Handle("/api/v1/slack/events", slack.NewEventHandler(ctx, slackSource, secret, sched))
+		}
+	}
+
+	// Embedding cache metrics
+	if embeddingCache != nil {
+		api.NewEmbeddingHandler(embeddingCache).Register(mux)
+	}
+
 	// Add CORS middleware
 	corsHandler := cors.New(cors.Options{
 		AllowedOrigins: []string{"http://localhost:8080"},
-		AllowedMethods: []string{"GET", "POST", "OPTIONS"},
+		AllowedMethods: []string{"GET", "POST", "DELETE", "OPTIONS"},
 		AllowedHeaders: []string{"Content-Type"},
 	})
 
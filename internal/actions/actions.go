@@ -0,0 +1,233 @@
+package actions
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultPendingTTL is how long a proposed action waits for confirmation
+const DefaultPendingTTL = time.Hour
+
+// Action states
+const (
+	StatusPending  = "pending"
+	StatusExecuted = "executed"
+	StatusFailed   = "failed"
+	StatusRejected = "rejected"
+)
+
+var (
+	// ErrUnknownAction is returned when the model proposes an action no executor handles
+	ErrUnknownAction = errors.New("unknown action")
+	// ErrNotFound is returned for an action that is not pending, or has expired
+	ErrNotFound = errors.New("action not found")
+)
+
+// Action is a change proposed by the assistant. Nothing is written to an
+// external service until the user confirms it.
+type Action struct {
+	ID         string          `json:"id"`
+	Type       string          `json:"type"`
+	Summary    string          `json:"summary"`
+	Params     json.RawMessage `json:"params"`
+	Status     string          `json:"status"`
+	Result     string          `json:"result,omitempty"`
+	Error      string          `json:"error,omitempty"`
+	ProposedAt time.Time       `json:"proposed_at"`
+	ResolvedAt time.Time       `json:"resolved_at,omitempty"`
+}
+
+// Definition describes an action to the model as a callable tool
+type Definition struct {
+	Name        string
+	Description string
+	// Parameters is the JSON schema of the action's arguments
+	Parameters map[string]interface{}
+}
+
+// Executor validates and performs one kind of action
+type Executor interface {
+	Definition() Definition
+	// Describe validates params and returns the summary shown for confirmation
+	Describe(ctx context.Context, params json.RawMessage) (string, error)
+	// Execute performs the action and returns a short description of the outcome
+	Execute(ctx context.Context, params json.RawMessage) (string, error)
+}
+
+// Manager holds proposed actions until they are confirmed or rejected, runs
+// confirmed ones through their executor and records the outcome in the audit log
+type Manager struct {
+	executors map[string]Executor
+	audit     *AuditLog
+	ttl       time.Duration
+
+	mu      sync.Mutex
+	pending map[string]*Action
+}
+
+// NewManager creates a manager for the given executors. audit may be nil to
+// disable the audit log; a zero ttl uses DefaultPendingTTL.
+func NewManager(executors []Executor, audit *AuditLog, ttl time.Duration) *Manager {
+	if ttl <= 0 {
+		ttl = DefaultPendingTTL
+	}
+
+	m := &Manager{
+		executors: make(map[string]Executor),
+		audit:     audit,
+		ttl:       ttl,
+		pending:   make(map[string]*Action),
+	}
+	for _, executor := range executors {
+		m.executors[executor.Definition().Name] = executor
+	}
+	return m
+}
+
+// Definitions lists the available actions, sorted by name
+func (m *Manager) Definitions() []Definition {
+	defs := make([]Definition, 0, len(m.executors))
+	for _, executor := range m.executors {
+		defs = append(defs, executor.Definition())
+	}
+	sort.Slice(defs, func(i, j int) bool {
+		return defs[i].Name < defs[j].Name
+	})
+	return defs
+}
+
+// Propose validates an action and holds it for confirmation
+func (m *Manager) Propose(ctx context.Context, name string, params json.RawMessage) (*Action, error) {
+	executor, ok := m.executors[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownAction, name)
+	}
+
+	summary, err := executor.Describe(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := newID()
+	if err != nil {
+		return nil, err
+	}
+
+	action := &Action{
+		ID:         id,
+		Type:       name,
+		Summary:    summary,
+		Params:     params,
+		Status:     StatusPending,
+		ProposedAt: time.Now(),
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.prune()
+	m.pending[id] = action
+
+	copied := *action
+	return &copied, nil
+}
+
+// Pending lists actions awaiting confirmation, oldest first
+func (m *Manager) Pending() []Action {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.prune()
+
+	actions := make([]Action, 0, len(m.pending))
+	for _, action := range m.pending {
+		actions = append(actions, *action)
+	}
+	sort.Slice(actions, func(i, j int) bool {
+		return actions[i].ProposedAt.Before(actions[j].ProposedAt)
+	})
+	return actions
+}
+
+// Confirm executes a pending action. An execution error is recorded on the
+// returned action rather than returned, since the action is resolved either way.
+func (m *Manager) Confirm(ctx context.Context, id string) (*Action, error) {
+	action, err := m.take(id)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := m.executors[action.Type].Execute(ctx, action.Params)
+	if err != nil {
+		action.Status = StatusFailed
+		action.Error = err.Error()
+	} else {
+		action.Status = StatusExecuted
+		action.Result = result
+	}
+	action.ResolvedAt = time.Now()
+
+	m.record(action)
+	return action, nil
+}
+
+// Reject discards a pending action without executing it
+func (m *Manager) Reject(id string) (*Action, error) {
+	action, err := m.take(id)
+	if err != nil {
+		return nil, err
+	}
+
+	action.Status = StatusRejected
+	action.ResolvedAt = time.Now()
+
+	m.record(action)
+	return action, nil
+}
+
+// take removes a pending action so it can be resolved exactly once
+func (m *Manager) take(id string) (*Action, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.prune()
+
+	action, ok := m.pending[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	delete(m.pending, id)
+	return action, nil
+}
+
+// prune drops actions that were never confirmed. Callers must hold mu.
+func (m *Manager) prune() {
+	cutoff := time.Now().Add(-m.ttl)
+	for id, action := range m.pending {
+		if action.ProposedAt.Before(cutoff) {
+			delete(m.pending, id)
+		}
+	}
+}
+
+func (m *Manager) record(action *Action) {
+	if m.audit == nil {
+		return
+	}
+	if err := m.audit.Record(*action); err != nil {
+		log.Printf("Failed to record action %s in audit log: %v", action.ID, err)
+	}
+}
+
+func newID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate action ID: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,354 @@
+package actions
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"google.golang.org/api/calendar/v3"
+)
+
+// dateLayout is the format of all-day event dates
+const dateLayout = "2006-01-02"
+
+// eventParams are the arguments the model gives for creating or changing an event
+type eventParams struct {
+	ID          string   `json:"id"`
+	CalendarID  string   `json:"calendar_id"`
+	Summary     string   `json:"summary"`
+	Start       string   `json:"start"`
+	End         string   `json:"end"`
+	Location    string   `json:"location"`
+	Description string   `json:"description"`
+	Attendees   []string `json:"attendees"`
+}
+
+// eventProperties are the JSON schema properties shared by create and update
+var eventProperties = map[string]interface{}{
+	"summary": map[string]interface{}{
+		"type":        "string",
+		"description": "Event title",
+	},
+	"start": map[string]interface{}{
+		"type":        "string",
+		"description": "Start as an RFC 3339 timestamp with offset, e.g. 2024-06-03T10:00:00+02:00, or a date (2024-06-03) for an all-day event",
+	},
+	"end": map[string]interface{}{
+		"type":        "string",
+		"description": "End in the same form as start. For all-day events this is the day after the last day.",
+	},
+	"location": map[string]interface{}{
+		"type":        "string",
+		"description": "Where the event takes place",
+	},
+	"description": map[string]interface{}{
+		"type":        "string",
+		"description": "Event notes",
+	},
+}
+
+// NewCalendarExecutors returns the executors that create and change events
+// through service
+func NewCalendarExecutors(service *calendar.Service) []Executor {
+	return []Executor{
+		&calendarCreate{service: service},
+		&calendarUpdate{service: service},
+	}
+}
+
+type calendarCreate struct {
+	service *calendar.Service
+}
+
+func (e *calendarCreate) Definition() Definition {
+	properties := map[string]interface{}{
+		"calendar_id": map[string]interface{}{
+			"type":        "string",
+			"description": `Calendar to add the event to (default "primary")`,
+		},
+		"attendees": map[string]interface{}{
+			"type":        "array",
+			"items":       map[string]interface{}{"type": "string"},
+			"description": "Email addresses to invite",
+		},
+	}
+	for key, schema := range eventProperties {
+		properties[key] = schema
+	}
+
+	return Definition{
+		Name:        "calendar_create_event",
+		Description: "Create a calendar event, inviting any attendees",
+		Parameters: map[string]interface{}{
+			"type":       "object",
+			"properties": properties,
+			"required":   []string{"summary", "start", "end"},
+		},
+	}
+}
+
+func (e *calendarCreate) Describe(ctx context.Context, params json.RawMessage) (string, error) {
+	p, err := parseEventParams(params)
+	if err != nil {
+		return "", err
+	}
+	if strings.TrimSpace(p.Summary) == "" {
+		return "", fmt.Errorf("summary is required")
+	}
+
+	when, err := describeWhen(p.Start, p.End)
+	if err != nil {
+		return "", err
+	}
+
+	summary := fmt.Sprintf("Create event %q %s", p.Summary, when)
+	if p.Location != "" {
+		summary += " at " + p.Location
+	}
+	if len(p.Attendees) > 0 {
+		summary += " and invite " + strings.Join(p.Attendees, ", ")
+	}
+	return summary, nil
+}
+
+func (e *calendarCreate) Execute(ctx context.Context, params json.RawMessage) (string, error) {
+	p, err := parseEventParams(params)
+	if err != nil {
+		return "", err
+	}
+
+	event := &calendar.Event{
+		Summary:     p.Summary,
+		Location:    p.Location,
+		Description: p.Description,
+	}
+	if event.Start, _, err = eventDateTime(p.Start); err != nil {
+		return "", err
+	}
+	if event.End, _, err = eventDateTime(p.End); err != nil {
+		return "", err
+	}
+	for _, email := range p.Attendees {
+		event.Attendees = append(event.Attendees, &calendar.EventAttendee{Email: email})
+	}
+
+	created, err := e.service.Events.Insert(p.CalendarID, event).
+		SendUpdates(sendUpdates(event.Attendees)).
+		Context(ctx).
+		Do()
+	if err != nil {
+		return "", fmt.Errorf("failed to create event: %w", err)
+	}
+	return fmt.Sprintf("Created event %q (%s)", created.Summary, created.HtmlLink), nil
+}
+
+type calendarUpdate struct {
+	service *calendar.Service
+}
+
+func (e *calendarUpdate) Definition() Definition {
+	properties := map[string]interface{}{
+		"id": map[string]interface{}{
+			"type":        "string",
+			"description": "ID of the event to change, as given in the context (calendar ID/event ID)",
+		},
+	}
+	for key, schema := range eventProperties {
+		properties[key] = schema
+	}
+
+	return Definition{
+		Name:        "calendar_update_event",
+		Description: "Change an existing calendar event, such as moving it to a new time. Only the given fields are changed and attendees are notified.",
+		Parameters: map[string]interface{}{
+			"type":       "object",
+			"properties": properties,
+			"required":   []string{"id"},
+		},
+	}
+}
+
+func (e *calendarUpdate) Describe(ctx context.Context, params json.RawMessage) (string, error) {
+	p, err := parseEventParams(params)
+	if err != nil {
+		return "", err
+	}
+
+	current, err := e.event(ctx, p)
+	if err != nil {
+		return "", err
+	}
+
+	var changes []string
+	if p.Summary != "" && p.Summary != current.Summary {
+		changes = append(changes, fmt.Sprintf("rename to %q", p.Summary))
+	}
+	if p.Start != "" || p.End != "" {
+		start, end := p.Start, p.End
+		if start == "" {
+			start = dateTimeString(current.Start)
+		}
+		if end == "" {
+			end = dateTimeString(current.End)
+		}
+		when, err := describeWhen(start, end)
+		if err != nil {
+			return "", err
+		}
+		changes = append(changes, "reschedule "+when)
+	}
+	if p.Location != "" {
+		changes = append(changes, "set location to "+p.Location)
+	}
+	if p.Description != "" {
+		changes = append(changes, "update the description")
+	}
+	if len(changes) == 0 {
+		return "", fmt.Errorf("no changes given")
+	}
+
+	summary := fmt.Sprintf("Update event %q: %s", current.Summary, strings.Join(changes, ", "))
+	switch n := len(current.Attendees); {
+	case n == 1:
+		summary += " (notifying 1 attendee)"
+	case n > 1:
+		summary += fmt.Sprintf(" (notifying %d attendees)", n)
+	}
+	return summary, nil
+}
+
+func (e *calendarUpdate) Execute(ctx context.Context, params json.RawMessage) (string, error) {
+	p, err := parseEventParams(params)
+	if err != nil {
+		return "", err
+	}
+
+	current, err := e.event(ctx, p)
+	if err != nil {
+		return "", err
+	}
+
+	patch := &calendar.Event{
+		Summary:     p.Summary,
+		Location:    p.Location,
+		Description: p.Description,
+	}
+	if p.Start != "" {
+		if patch.Start, _, err = eventDateTime(p.Start); err != nil {
+			return "", err
+		}
+	}
+	if p.End != "" {
+		if patch.End, _, err = eventDateTime(p.End); err != nil {
+			return "", err
+		}
+	}
+
+	calendarID, eventID := splitEventID(p)
+	updated, err := e.service.Events.Patch(calendarID, eventID, patch).
+		SendUpdates(sendUpdates(current.Attendees)).
+		Context(ctx).
+		Do()
+	if err != nil {
+		return "", fmt.Errorf("failed to update event: %w", err)
+	}
+	return fmt.Sprintf("Updated event %q (%s)", updated.Summary, updated.HtmlLink), nil
+}
+
+// event loads the event being changed
+func (e *calendarUpdate) event(ctx context.Context, p eventParams) (*calendar.Event, error) {
+	if p.ID == "" {
+		return nil, fmt.Errorf("id is required")
+	}
+
+	calendarID, eventID := splitEventID(p)
+	event, err := e.service.Events.Get(calendarID, eventID).Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch event %s: %w", p.ID, err)
+	}
+	return event, nil
+}
+
+func parseEventParams(params json.RawMessage) (eventParams, error) {
+	var p eventParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return p, fmt.Errorf("invalid parameters: %w", err)
+	}
+	if p.CalendarID == "" {
+		p.CalendarID = "primary"
+	}
+	return p, nil
+}
+
+// splitEventID separates the calendar from an indexed event ID, which takes the
+// form calendar ID/event ID. A bare event ID is looked up in the given calendar.
+func splitEventID(p eventParams) (calendarID, eventID string) {
+	if i := strings.LastIndex(p.ID, "/"); i > 0 {
+		return p.ID[:i], p.ID[i+1:]
+	}
+	return p.CalendarID, p.ID
+}
+
+// eventDateTime parses an RFC 3339 timestamp, or a date for all-day events
+func eventDateTime(s string) (*calendar.EventDateTime, time.Time, error) {
+	if t, err := time.Parse(dateLayout, s); err == nil {
+		return &calendar.EventDateTime{Date: s}, t, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("invalid time %q: use RFC 3339 or YYYY-MM-DD", s)
+	}
+	return &calendar.EventDateTime{DateTime: t.Format(time.RFC3339)}, t, nil
+}
+
+// dateTimeString returns an event time in the form eventDateTime accepts
+func dateTimeString(t *calendar.EventDateTime) string {
+	if t == nil {
+		return ""
+	}
+	if t.DateTime != "" {
+		return t.DateTime
+	}
+	return t.Date
+}
+
+// describeWhen validates a start and end and formats them for a confirmation summary
+func describeWhen(start, end string) (string, error) {
+	startDT, startTime, err := eventDateTime(start)
+	if err != nil {
+		return "", err
+	}
+	endDT, endTime, err := eventDateTime(end)
+	if err != nil {
+		return "", err
+	}
+	if (startDT.Date == "") != (endDT.Date == "") {
+		return "", fmt.Errorf("start and end must both be dates or both be timestamps")
+	}
+	if !endTime.After(startTime) {
+		return "", fmt.Errorf("end must be after start")
+	}
+
+	if startDT.Date != "" {
+		last := endTime.AddDate(0, 0, -1)
+		if last.Equal(startTime) {
+			return "on " + startTime.Format("Mon Jan 2, 2006") + " (all day)", nil
+		}
+		return fmt.Sprintf("from %s to %s (all day)", startTime.Format("Mon Jan 2"), last.Format("Mon Jan 2, 2006")), nil
+	}
+
+	if endTime.Format(dateLayout) == startTime.Format(dateLayout) {
+		return fmt.Sprintf("on %s, %s–%s", startTime.Format("Mon Jan 2, 2006"), startTime.Format("15:04"), endTime.Format("15:04 MST")), nil
+	}
+	return fmt.Sprintf("from %s to %s", startTime.Format("Mon Jan 2 15:04"), endTime.Format("Mon Jan 2 15:04 MST")), nil
+}
+
+// sendUpdates notifies attendees of a change, when there are any
+func sendUpdates(attendees []*calendar.EventAttendee) string {
+	if len(attendees) > 0 {
+		return "all"
+	}
+	return "none"
+}
@@ -0,0 +1,207 @@
+package actions
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/mail"
+	"strings"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+// draftParams are the arguments the model gives for a reply
+type draftParams struct {
+	ThreadID string   `json:"thread_id"`
+	Body     string   `json:"body"`
+	Cc       []string `json:"cc"`
+}
+
+// replyHeaders are read from a thread to address a reply
+var replyHeaders = []string{"Subject", "From", "To", "Reply-To", "Message-ID", "References"}
+
+// NewGmailExecutors returns the executor that saves reply drafts through
+// service. Drafts are never sent; the user reviews and sends them in Gmail.
+func NewGmailExecutors(service *gmail.Service) []Executor {
+	return []Executor{&gmailDraftReply{service: service}}
+}
+
+type gmailDraftReply struct {
+	service *gmail.Service
+}
+
+func (e *gmailDraftReply) Definition() Definition {
+	return Definition{
+		Name:        "gmail_draft_reply",
+		Description: "Save a draft reply to an email thread. The draft is not sent; the user sends it from Gmail.",
+		Parameters: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"thread_id": map[string]interface{}{
+					"type":        "string",
+					"description": "ID of the Gmail thread to reply to",
+				},
+				"body": map[string]interface{}{
+					"type":        "string",
+					"description": "Plain text body of the reply",
+				},
+				"cc": map[string]interface{}{
+					"type":        "array",
+					"items":       map[string]interface{}{"type": "string"},
+					"description": "Additional addresses to copy",
+				},
+			},
+			"required": []string{"thread_id", "body"},
+		},
+	}
+}
+
+func (e *gmailDraftReply) Describe(ctx context.Context, params json.RawMessage) (string, error) {
+	p, err := parseDraftParams(params)
+	if err != nil {
+		return "", err
+	}
+
+	r, err := e.address(ctx, p)
+	if err != nil {
+		return "", err
+	}
+
+	summary := fmt.Sprintf("Save a draft reply to %q for %s", r.subject, r.to)
+	if len(p.Cc) > 0 {
+		summary += fmt.Sprintf(" (cc %s)", strings.Join(p.Cc, ", "))
+	}
+	return summary + ": " + excerpt(p.Body), nil
+}
+
+func (e *gmailDraftReply) Execute(ctx context.Context, params json.RawMessage) (string, error) {
+	p, err := parseDraftParams(params)
+	if err != nil {
+		return "", err
+	}
+
+	r, err := e.address(ctx, p)
+	if err != nil {
+		return "", err
+	}
+
+	draft, err := e.service.Users.Drafts.Create("me", &gmail.Draft{
+		Message: &gmail.Message{
+			ThreadId: p.ThreadID,
+			Raw:      base64.URLEncoding.EncodeToString(r.message(p)),
+		},
+	}).Context(ctx).Do()
+	if err != nil {
+		return "", fmt.Errorf("failed to create draft: %w", err)
+	}
+	return fmt.Sprintf("Saved draft %s replying to %q", draft.Id, r.subject), nil
+}
+
+// reply holds the addressing of a reply to a thread's latest message
+type reply struct {
+	to         string
+	subject    string
+	inReplyTo  string
+	references string
+}
+
+// address works out who a reply to the latest message in a thread goes to. When
+// that message was sent by the user, the reply goes to its original recipients.
+func (e *gmailDraftReply) address(ctx context.Context, p draftParams) (*reply, error) {
+	thread, err := e.service.Users.Threads.Get("me", p.ThreadID).
+		Format("metadata").
+		MetadataHeaders(replyHeaders...).
+		Context(ctx).
+		Do()
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch thread %s: %w", p.ThreadID, err)
+	}
+	if len(thread.Messages) == 0 {
+		return nil, fmt.Errorf("thread %s has no messages", p.ThreadID)
+	}
+
+	last := thread.Messages[len(thread.Messages)-1]
+	headers := make(map[string]string)
+	if last.Payload != nil {
+		for _, header := range last.Payload.Headers {
+			headers[header.Name] = header.Value
+		}
+	}
+
+	to := headers["Reply-To"]
+	if to == "" {
+		to = headers["From"]
+	}
+	for _, label := range last.LabelIds {
+		if label == "SENT" {
+			to = headers["To"]
+			break
+		}
+	}
+	if to == "" {
+		return nil, fmt.Errorf("thread %s has no address to reply to", p.ThreadID)
+	}
+
+	subject := headers["Subject"]
+	if !strings.HasPrefix(strings.ToLower(subject), "re:") {
+		subject = "Re: " + subject
+	}
+
+	references := strings.TrimSpace(headers["References"] + " " + headers["Message-ID"])
+
+	return &reply{
+		to:         to,
+		subject:    subject,
+		inReplyTo:  headers["Message-ID"],
+		references: references,
+	}, nil
+}
+
+// message renders the reply as an RFC 2822 message
+func (r *reply) message(p draftParams) []byte {
+	var sb strings.Builder
+	sb.WriteString("To: " + r.to + "\r\n")
+	if len(p.Cc) > 0 {
+		sb.WriteString("Cc: " + strings.Join(p.Cc, ", ") + "\r\n")
+	}
+	sb.WriteString("Subject: " + mime.QEncoding.Encode("utf-8", r.subject) + "\r\n")
+	if r.inReplyTo != "" {
+		sb.WriteString("In-Reply-To: " + r.inReplyTo + "\r\n")
+		sb.WriteString("References: " + r.references + "\r\n")
+	}
+	sb.WriteString("MIME-Version: 1.0\r\n")
+	sb.WriteString("Content-Type: text/plain; charset=\"UTF-8\"\r\n")
+	sb.WriteString("\r\n")
+	sb.WriteString(strings.ReplaceAll(p.Body, "\n", "\r\n"))
+	return []byte(sb.String())
+}
+
+func parseDraftParams(params json.RawMessage) (draftParams, error) {
+	var p draftParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return p, fmt.Errorf("invalid parameters: %w", err)
+	}
+	if p.ThreadID == "" {
+		return p, fmt.Errorf("thread_id is required")
+	}
+	if strings.TrimSpace(p.Body) == "" {
+		return p, fmt.Errorf("body is required")
+	}
+	for _, addr := range p.Cc {
+		if _, err := mail.ParseAddress(addr); err != nil {
+			return p, fmt.Errorf("invalid cc address %q", addr)
+		}
+	}
+	return p, nil
+}
+
+// excerpt shortens text to a single line for a confirmation summary
+func excerpt(text string) string {
+	line := strings.Join(strings.Fields(text), " ")
+	if len([]rune(line)) > 100 {
+		line = string([]rune(line)[:99]) + "…"
+	}
+	return line
+}
@@ -0,0 +1,318 @@
+package actions
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/michaelgalloway/sophia/internal/datasources/todoist"
+)
+
+// taskParams are the arguments the model gives for creating or updating a task.
+// Priority uses the app's convention, where 1 is the most urgent.
+type taskParams struct {
+	ID          string   `json:"id"`
+	Content     string   `json:"content"`
+	Description string   `json:"description"`
+	Project     string   `json:"project"`
+	Labels      []string `json:"labels"`
+	Priority    int      `json:"priority"`
+	Due         string   `json:"due"`
+}
+
+// taskProperties are the JSON schema properties shared by create and update
+var taskProperties = map[string]interface{}{
+	"content": map[string]interface{}{
+		"type":        "string",
+		"description": "The task title",
+	},
+	"description": map[string]interface{}{
+		"type":        "string",
+		"description": "Longer notes for the task",
+	},
+	"project": map[string]interface{}{
+		"type":        "string",
+		"description": "Name of the project to put the task in",
+	},
+	"labels": map[string]interface{}{
+		"type":        "array",
+		"items":       map[string]interface{}{"type": "string"},
+		"description": "Label names",
+	},
+	"priority": map[string]interface{}{
+		"type":        "integer",
+		"enum":        []int{1, 2, 3, 4},
+		"description": "Priority from 1 (urgent) to 4 (normal)",
+	},
+	"due": map[string]interface{}{
+		"type":        "string",
+		"description": `Due date in natural language, e.g. "tomorrow at 5pm" or "every monday"`,
+	},
+}
+
+// NewTodoistExecutors returns the executors that create, update and complete
+// tasks through client
+func NewTodoistExecutors(client *todoist.Client) []Executor {
+	return []Executor{
+		&todoistCreate{client: client},
+		&todoistUpdate{client: client},
+		&todoistComplete{client: client},
+	}
+}
+
+type todoistCreate struct {
+	client *todoist.Client
+}
+
+func (e *todoistCreate) Definition() Definition {
+	return Definition{
+		Name:        "todoist_create_task",
+		Description: "Create a new Todoist task",
+		Parameters: map[string]interface{}{
+			"type":       "object",
+			"properties": taskProperties,
+			"required":   []string{"content"},
+		},
+	}
+}
+
+func (e *todoistCreate) Describe(ctx context.Context, params json.RawMessage) (string, error) {
+	p, err := parseTaskParams(params)
+	if err != nil {
+		return "", err
+	}
+	if strings.TrimSpace(p.Content) == "" {
+		return "", fmt.Errorf("content is required")
+	}
+	return "Create Todoist task " + describeTask(p), nil
+}
+
+func (e *todoistCreate) Execute(ctx context.Context, params json.RawMessage) (string, error) {
+	p, err := parseTaskParams(params)
+	if err != nil {
+		return "", err
+	}
+
+	input, err := taskInput(ctx, e.client, p)
+	if err != nil {
+		return "", err
+	}
+
+	task, err := e.client.CreateTask(ctx, input)
+	if err != nil {
+		return "", err
+	}
+	return "Created task " + taskName(task), nil
+}
+
+type todoistUpdate struct {
+	client *todoist.Client
+}
+
+func (e *todoistUpdate) Definition() Definition {
+	properties := map[string]interface{}{
+		"id": map[string]interface{}{
+			"type":        "string",
+			"description": "ID of the task to update",
+		},
+	}
+	for key, schema := range taskProperties {
+		properties[key] = schema
+	}
+
+	return Definition{
+		Name:        "todoist_update_task",
+		Description: "Change an existing Todoist task. Only the given fields are changed.",
+		Parameters: map[string]interface{}{
+			"type":       "object",
+			"properties": properties,
+			"required":   []string{"id"},
+		},
+	}
+}
+
+func (e *todoistUpdate) Describe(ctx context.Context, params json.RawMessage) (string, error) {
+	p, err := parseTaskParams(params)
+	if err != nil {
+		return "", err
+	}
+	if p.ID == "" {
+		return "", fmt.Errorf("id is required")
+	}
+
+	changes := describeTask(p)
+	if changes == "" {
+		return "", fmt.Errorf("no changes given")
+	}
+
+	// Name the task as stored rather than trusting the model to
+	task, err := e.client.Task(ctx, p.ID)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Update Todoist task %s: %s", taskName(task), changes), nil
+}
+
+func (e *todoistUpdate) Execute(ctx context.Context, params json.RawMessage) (string, error) {
+	p, err := parseTaskParams(params)
+	if err != nil {
+		return "", err
+	}
+
+	input, err := taskInput(ctx, e.client, p)
+	if err != nil {
+		return "", err
+	}
+
+	// Updates ignore the project, so a change of project is a separate move
+	if input.ProjectID != "" {
+		if err := e.client.MoveTask(ctx, p.ID, input.ProjectID); err != nil {
+			return "", err
+		}
+		input.ProjectID = ""
+	}
+
+	var task *todoist.Task
+	if hasFieldChanges(p) {
+		task, err = e.client.UpdateTask(ctx, p.ID, input)
+	} else {
+		task, err = e.client.Task(ctx, p.ID)
+	}
+	if err != nil {
+		return "", err
+	}
+	return "Updated task " + taskName(task), nil
+}
+
+type todoistComplete struct {
+	client *todoist.Client
+}
+
+func (e *todoistComplete) Definition() Definition {
+	return Definition{
+		Name:        "todoist_complete_task",
+		Description: "Mark a Todoist task as done. Recurring tasks move to their next date.",
+		Parameters: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"id": map[string]interface{}{
+					"type":        "string",
+					"description": "ID of the task to complete",
+				},
+			},
+			"required": []string{"id"},
+		},
+	}
+}
+
+func (e *todoistComplete) Describe(ctx context.Context, params json.RawMessage) (string, error) {
+	p, err := parseTaskParams(params)
+	if err != nil {
+		return "", err
+	}
+	if p.ID == "" {
+		return "", fmt.Errorf("id is required")
+	}
+
+	task, err := e.client.Task(ctx, p.ID)
+	if err != nil {
+		return "", err
+	}
+	return "Complete Todoist task " + taskName(task), nil
+}
+
+func (e *todoistComplete) Execute(ctx context.Context, params json.RawMessage) (string, error) {
+	p, err := parseTaskParams(params)
+	if err != nil {
+		return "", err
+	}
+
+	// Closing returns no body, so read the task first to report what was done
+	task, err := e.client.Task(ctx, p.ID)
+	if err != nil {
+		return "", err
+	}
+	if err := e.client.CloseTask(ctx, p.ID); err != nil {
+		return "", err
+	}
+	return "Completed task " + taskName(task), nil
+}
+
+func parseTaskParams(params json.RawMessage) (taskParams, error) {
+	var p taskParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return p, fmt.Errorf("invalid parameters: %w", err)
+	}
+	if p.Priority < 0 || p.Priority > 4 {
+		return p, fmt.Errorf("priority must be between 1 and 4")
+	}
+	return p, nil
+}
+
+// taskInput converts the model's arguments to an API request, resolving the
+// project name and flipping the priority scale
+func taskInput(ctx context.Context, client *todoist.Client, p taskParams) (todoist.TaskInput, error) {
+	input := todoist.TaskInput{
+		Content:     p.Content,
+		Description: p.Description,
+		Labels:      p.Labels,
+		DueString:   p.Due,
+	}
+	if p.Priority > 0 {
+		// The API counts up to 4 for the most urgent, the app counts down to 1
+		input.Priority = 5 - p.Priority
+	}
+
+	if p.Project != "" {
+		projects, err := client.Projects(ctx)
+		if err != nil {
+			return input, err
+		}
+		for _, project := range projects {
+			if strings.EqualFold(project.Name, p.Project) {
+				input.ProjectID = project.ID
+				break
+			}
+		}
+		if input.ProjectID == "" {
+			return input, fmt.Errorf("no project named %q", p.Project)
+		}
+	}
+
+	return input, nil
+}
+
+// describeTask lists the fields being set, for the confirmation summary
+func describeTask(p taskParams) string {
+	var parts []string
+	if p.Content != "" {
+		parts = append(parts, fmt.Sprintf("%q", p.Content))
+	}
+	if p.Project != "" {
+		parts = append(parts, "in "+p.Project)
+	}
+	if p.Due != "" {
+		parts = append(parts, "due "+p.Due)
+	}
+	if p.Priority > 0 {
+		parts = append(parts, fmt.Sprintf("priority p%d", p.Priority))
+	}
+	if len(p.Labels) > 0 {
+		parts = append(parts, "labels "+strings.Join(p.Labels, ", "))
+	}
+	if p.Description != "" {
+		parts = append(parts, "with a description")
+	}
+	return strings.Join(parts, ", ")
+}
+
+// hasFieldChanges reports whether an update sets anything besides the project
+func hasFieldChanges(p taskParams) bool {
+	return p.Content != "" || p.Description != "" || len(p.Labels) > 0 || p.Priority > 0 || p.Due != ""
+}
+
+// taskName identifies an existing task by its title and ID
+func taskName(task *todoist.Task) string {
+	return fmt.Sprintf("%q (%s)", task.Content, task.ID)
+}
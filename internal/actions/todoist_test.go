@@ -0,0 +1,346 @@
+package actions
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/michaelgalloway/sophia/internal/datasources/todoist"
+)
+
+const testToken = "todoist-test-token"
+
+// todoistRequest is a request received by the fake Todoist API
+type todoistRequest struct {
+	Method string
+	Path   string
+	Auth   string
+	Body   map[string]interface{}
+}
+
+// fakeTodoist serves the task and project endpoints the executors call
+type fakeTodoist struct {
+	mu       sync.Mutex
+	tasks    map[string]todoist.Task
+	requests []todoistRequest
+	// fail makes every write return a server error
+	fail bool
+}
+
+func newFakeTodoist(t *testing.T) (*fakeTodoist, *todoist.Client) {
+	api := &fakeTodoist{
+		tasks: map[string]todoist.Task{
+			"6X7rM8997g3RQmvh": {ID: "6X7rM8997g3RQmvh", Content: "Renew passport", ProjectID: "inbox"},
+		},
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(api.serveHTTP))
+	t.Cleanup(server.Close)
+	return api, todoist.NewClient(server.URL, testToken)
+}
+
+func (f *fakeTodoist) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	req := todoistRequest{Method: r.Method, Path: r.URL.Path, Auth: r.Header.Get("Authorization")}
+	if data, _ := io.ReadAll(r.Body); len(data) > 0 {
+		json.Unmarshal(data, &req.Body)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests = append(f.requests, req)
+
+	if r.Method == http.MethodPost && f.fail {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/projects":
+		writeTodoistJSON(w, map[string]interface{}{
+			"results": []todoist.Project{{ID: "inbox", Name: "Inbox"}, {ID: "errands", Name: "Errands"}},
+		})
+	case r.Method == http.MethodPost && r.URL.Path == "/tasks":
+		task := todoist.Task{ID: "7Y8sN9008h4SRnwi", Content: req.Body["content"].(string)}
+		f.tasks[task.ID] = task
+		writeTodoistJSON(w, task)
+	case len(parts) == 2 && parts[0] == "tasks":
+		task, ok := f.tasks[parts[1]]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method == http.MethodPost {
+			if content, ok := req.Body["content"].(string); ok {
+				task.Content = content
+			}
+			f.tasks[task.ID] = task
+		}
+		writeTodoistJSON(w, task)
+	case len(parts) == 3 && parts[0] == "tasks" && parts[2] == "move" && r.Method == http.MethodPost:
+		task, ok := f.tasks[parts[1]]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		task.ProjectID, _ = req.Body["project_id"].(string)
+		f.tasks[task.ID] = task
+		writeTodoistJSON(w, task)
+	case len(parts) == 3 && parts[0] == "tasks" && parts[2] == "close" && r.Method == http.MethodPost:
+		if _, ok := f.tasks[parts[1]]; !ok {
+			http.NotFound(w, r)
+			return
+		}
+		delete(f.tasks, parts[1])
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// writes returns the POST requests received so far
+func (f *fakeTodoist) writes() []todoistRequest {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var writes []todoistRequest
+	for _, req := range f.requests {
+		if req.Method == http.MethodPost {
+			writes = append(writes, req)
+		}
+	}
+	return writes
+}
+
+func writeTodoistJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func newTestManager(t *testing.T, client *todoist.Client) (*Manager, string) {
+	path := filepath.Join(t.TempDir(), "actions.jsonl")
+	audit, err := NewAuditLog(path)
+	if err != nil {
+		t.Fatalf("NewAuditLog() error = %v", err)
+	}
+	return NewManager(NewTodoistExecutors(client), audit, 0), path
+}
+
+func TestTodoistRequests(t *testing.T) {
+	tests := []struct {
+		action  string
+		params  string
+		summary string
+		result  string
+		path    string
+		body    map[string]interface{}
+	}{
+		{
+			action:  "todoist_create_task",
+			params:  `{"content": "Book dentist", "project": "errands", "priority": 1, "due": "friday"}`,
+			summary: `Create Todoist task "Book dentist", in errands, due friday, priority p1`,
+			result:  `Created task "Book dentist" (7Y8sN9008h4SRnwi)`,
+			path:    "/tasks",
+			body: map[string]interface{}{
+				"content": "Book dentist", "project_id": "errands", "priority": float64(4), "due_string": "friday",
+			},
+		},
+		{
+			action:  "todoist_update_task",
+			params:  `{"id": "6X7rM8997g3RQmvh", "content": "Renew passport and ID", "due": "next week"}`,
+			summary: `Update Todoist task "Renew passport" (6X7rM8997g3RQmvh): "Renew passport and ID", due next week`,
+			result:  `Updated task "Renew passport and ID" (6X7rM8997g3RQmvh)`,
+			path:    "/tasks/6X7rM8997g3RQmvh",
+			body:    map[string]interface{}{"content": "Renew passport and ID", "due_string": "next week"},
+		},
+		{
+			action:  "todoist_update_task",
+			params:  `{"id": "6X7rM8997g3RQmvh", "project": "Errands"}`,
+			summary: `Update Todoist task "Renew passport" (6X7rM8997g3RQmvh): in Errands`,
+			result:  `Updated task "Renew passport" (6X7rM8997g3RQmvh)`,
+			path:    "/tasks/6X7rM8997g3RQmvh/move",
+			body:    map[string]interface{}{"project_id": "errands"},
+		},
+		{
+			action:  "todoist_complete_task",
+			params:  `{"id": "6X7rM8997g3RQmvh"}`,
+			summary: `Complete Todoist task "Renew passport" (6X7rM8997g3RQmvh)`,
+			result:  `Completed task "Renew passport" (6X7rM8997g3RQmvh)`,
+			path:    "/tasks/6X7rM8997g3RQmvh/close",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.action, func(t *testing.T) {
+			api, client := newFakeTodoist(t)
+			manager, _ := newTestManager(t, client)
+			ctx := context.Background()
+
+			proposed, err := manager.Propose(ctx, tt.action, json.RawMessage(tt.params))
+			if err != nil {
+				t.Fatalf("Propose() error = %v", err)
+			}
+			if proposed.Summary != tt.summary {
+				t.Errorf("summary = %q, want %q", proposed.Summary, tt.summary)
+			}
+			if len(api.writes()) != 0 {
+				t.Fatalf("proposing wrote %v before confirmation", api.writes())
+			}
+
+			action, err := manager.Confirm(ctx, proposed.ID)
+			if err != nil {
+				t.Fatalf("Confirm() error = %v", err)
+			}
+			if action.Status != StatusExecuted || action.Result != tt.result {
+				t.Errorf("action = {Status: %q, Result: %q, Error: %q}, want {Status: %q, Result: %q}",
+					action.Status, action.Result, action.Error, StatusExecuted, tt.result)
+			}
+
+			writes := api.writes()
+			if len(writes) != 1 {
+				t.Fatalf("writes = %v, want one request", writes)
+			}
+			if writes[0].Path != tt.path {
+				t.Errorf("path = %q, want %q", writes[0].Path, tt.path)
+			}
+			if writes[0].Auth != "Bearer "+testToken {
+				t.Errorf("Authorization = %q, want the bearer token", writes[0].Auth)
+			}
+			if len(writes[0].Body) != len(tt.body) {
+				t.Errorf("body = %v, want %v", writes[0].Body, tt.body)
+			}
+			for key, want := range tt.body {
+				if got := writes[0].Body[key]; got != want {
+					t.Errorf("body[%q] = %v, want %v", key, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestTodoistDescribeUnknownTask(t *testing.T) {
+	api, client := newFakeTodoist(t)
+	manager, _ := newTestManager(t, client)
+
+	_, err := manager.Propose(context.Background(), "todoist_complete_task", json.RawMessage(`{"id": "missing"}`))
+	if err == nil {
+		t.Fatal("Propose() error = nil, want an error for an unknown task")
+	}
+	if len(manager.Pending()) != 0 || len(api.writes()) != 0 {
+		t.Errorf("unknown task left %v pending and wrote %v", manager.Pending(), api.writes())
+	}
+}
+
+func TestTodoistReject(t *testing.T) {
+	api, client := newFakeTodoist(t)
+	manager, path := newTestManager(t, client)
+
+	proposed, err := manager.Propose(context.Background(), "todoist_complete_task", json.RawMessage(`{"id": "6X7rM8997g3RQmvh"}`))
+	if err != nil {
+		t.Fatalf("Propose() error = %v", err)
+	}
+
+	action, err := manager.Reject(proposed.ID)
+	if err != nil {
+		t.Fatalf("Reject() error = %v", err)
+	}
+	if action.Status != StatusRejected {
+		t.Errorf("status = %q, want %q", action.Status, StatusRejected)
+	}
+	if len(api.writes()) != 0 {
+		t.Errorf("rejected action wrote %v", api.writes())
+	}
+	if _, err := manager.Confirm(context.Background(), proposed.ID); err == nil {
+		t.Error("Confirm() after Reject() error = nil, want ErrNotFound")
+	}
+
+	records := readAuditLog(t, path)
+	if len(records) != 1 || records[0].ID != proposed.ID || records[0].Status != StatusRejected {
+		t.Errorf("audit log = %+v, want the rejected action", records)
+	}
+}
+
+func TestTodoistFailedExecution(t *testing.T) {
+	api, client := newFakeTodoist(t)
+	manager, path := newTestManager(t, client)
+
+	proposed, err := manager.Propose(context.Background(), "todoist_update_task",
+		json.RawMessage(`{"id": "6X7rM8997g3RQmvh", "priority": 2}`))
+	if err != nil {
+		t.Fatalf("Propose() error = %v", err)
+	}
+
+	api.mu.Lock()
+	api.fail = true
+	api.mu.Unlock()
+
+	action, err := manager.Confirm(context.Background(), proposed.ID)
+	if err != nil {
+		t.Fatalf("Confirm() error = %v, want the failure recorded on the action", err)
+	}
+	if action.Status != StatusFailed || action.Error == "" || action.Result != "" {
+		t.Errorf("action = {Status: %q, Result: %q, Error: %q}, want a failure",
+			action.Status, action.Result, action.Error)
+	}
+
+	records := readAuditLog(t, path)
+	if len(records) != 1 || records[0].Status != StatusFailed || records[0].Error != action.Error {
+		t.Errorf("audit log = %+v, want the failed action", records)
+	}
+}
+
+func TestTodoistAuditLog(t *testing.T) {
+	_, client := newFakeTodoist(t)
+	manager, path := newTestManager(t, client)
+	params := `{"id":"6X7rM8997g3RQmvh"}`
+
+	proposed, err := manager.Propose(context.Background(), "todoist_complete_task", json.RawMessage(params))
+	if err != nil {
+		t.Fatalf("Propose() error = %v", err)
+	}
+	if _, err := manager.Confirm(context.Background(), proposed.ID); err != nil {
+		t.Fatalf("Confirm() error = %v", err)
+	}
+
+	records := readAuditLog(t, path)
+	if len(records) != 1 {
+		t.Fatalf("audit log has %d lines, want 1", len(records))
+	}
+	got := records[0]
+	if got.ID != proposed.ID || got.Type != "todoist_complete_task" || got.Status != StatusExecuted ||
+		got.Summary != proposed.Summary || string(got.Params) != params ||
+		got.Result != `Completed task "Renew passport" (6X7rM8997g3RQmvh)` {
+		t.Errorf("audit record = %+v", got)
+	}
+	if got.ProposedAt.IsZero() || got.ResolvedAt.Before(got.ProposedAt) {
+		t.Errorf("audit times = proposed %v, resolved %v", got.ProposedAt, got.ResolvedAt)
+	}
+}
+
+func readAuditLog(t *testing.T, path string) []Action {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("failed to open audit log: %v", err)
+	}
+	defer f.Close()
+
+	var actions []Action
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var action Action
+		if err := json.Unmarshal(scanner.Bytes(), &action); err != nil {
+			t.Fatalf("audit line %q is not an action: %v", scanner.Text(), err)
+		}
+		actions = append(actions, action)
+	}
+	return actions
+}
@@ -0,0 +1,131 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/michaelgalloway/sophia/internal/actions"
+	"github.com/michaelgalloway/sophia/internal/service"
+)
+
+// AskHandler answers questions and resolves the actions the assistant proposes
+type AskHandler struct {
+	assistant *service.Assistant
+	actions   *actions.Manager
+}
+
+// NewAskHandler creates a handler for the assistant. actionManager may be nil
+// when no actions are configured.
+func NewAskHandler(assistant *service.Assistant, actionManager *actions.Manager) *AskHandler {
+	return &AskHandler{assistant: assistant, actions: actionManager}
+}
+
+// Register adds the ask and action routes to the mux
+func (h *AskHandler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("/api/v1/ask", h.handleAsk)
+	mux.HandleFunc("/api/v1/actions", h.handleActions)
+	mux.HandleFunc("/api/v1/actions/confirm", h.handleConfirm)
+	mux.HandleFunc("/api/v1/actions/reject", h.handleReject)
+}
+
+// handleAsk answers a query, returning the text and any proposed actions.
+// The query is read from a JSON body or a form field.
+//
+//	POST /api/v1/ask {"query": "remind me to renew my passport next week"}
+func (h *AskHandler) handleAsk(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	var query string
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		var body struct {
+			Query string `json:"query"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid JSON body")
+			return
+		}
+		query = body.Query
+	} else {
+		query = r.FormValue("query")
+	}
+	if strings.TrimSpace(query) == "" {
+		writeError(w, http.StatusBadRequest, "query is required")
+		return
+	}
+
+	answer, err := h.assistant.Ask(r.Context(), query)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, answer)
+}
+
+// handleActions lists actions awaiting confirmation.
+//
+//	GET /api/v1/actions
+func (h *AskHandler) handleActions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	pending := []actions.Action{}
+	if h.actions != nil {
+		pending = h.actions.Pending()
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"actions": pending,
+	})
+}
+
+// handleConfirm executes a pending action. A failed execution still returns
+// 200 with the action's status set to "failed" and the error recorded.
+//
+//	POST /api/v1/actions/confirm?id=3f2a9c0d1e4b5a6c
+func (h *AskHandler) handleConfirm(w http.ResponseWriter, r *http.Request) {
+	h.resolve(w, r, func(id string) (*actions.Action, error) {
+		return h.actions.Confirm(r.Context(), id)
+	})
+}
+
+// handleReject discards a pending action.
+//
+//	POST /api/v1/actions/reject?id=3f2a9c0d1e4b5a6c
+func (h *AskHandler) handleReject(w http.ResponseWriter, r *http.Request) {
+	h.resolve(w, r, func(id string) (*actions.Action, error) {
+		return h.actions.Reject(id)
+	})
+}
+
+func (h *AskHandler) resolve(w http.ResponseWriter, r *http.Request, fn func(id string) (*actions.Action, error)) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	if h.actions == nil {
+		writeError(w, http.StatusNotFound, "no actions are configured")
+		return
+	}
+
+	id := r.FormValue("id")
+	if id == "" {
+		writeError(w, http.StatusBadRequest, "id parameter is required")
+		return
+	}
+
+	action, err := fn(id)
+	switch {
+	case errors.Is(err, actions.ErrNotFound):
+		writeError(w, http.StatusNotFound, err.Error())
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, err.Error())
+	default:
+		writeJSON(w, http.StatusOK, action)
+	}
+}
@@ -0,0 +1,65 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/michaelgalloway/sophia/internal/briefing"
+)
+
+// BriefingHandler serves the daily briefing
+type BriefingHandler struct {
+	generator *briefing.Generator
+}
+
+// NewBriefingHandler creates a handler backed by the given generator
+func NewBriefingHandler(generator *briefing.Generator) *BriefingHandler {
+	return &BriefingHandler{generator: generator}
+}
+
+// Register adds the briefing route to the mux
+func (h *BriefingHandler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("/api/v1/briefing", h.handleBriefing)
+}
+
+// handleBriefing returns today's briefing on GET, generating it if there is none
+// yet or refresh is set. POST always generates a new one and, with deliver=true,
+// sends it to the notification sinks.
+//
+//	GET  /api/v1/briefing?refresh=true
+//	POST /api/v1/briefing?deliver=true
+func (h *BriefingHandler) handleBriefing(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+
+	switch r.Method {
+	case http.MethodGet:
+		if latest, ok := h.generator.Latest(); ok && !formBool(r, "refresh") && latest.Date == now.Format("2006-01-02") {
+			writeJSON(w, http.StatusOK, latest)
+			return
+		}
+
+		b, err := h.generator.Generate(r.Context(), now)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, b)
+
+	case http.MethodPost:
+		b, err := h.generator.Generate(r.Context(), now)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if formBool(r, "deliver") {
+			if err := h.generator.Deliver(r.Context(), b); err != nil {
+				writeError(w, http.StatusBadGateway, err.Error())
+				return
+			}
+		}
+		writeJSON(w, http.StatusOK, b)
+
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
@@ -0,0 +1,206 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/michaelgalloway/sophia/internal/database"
+	"github.com/michaelgalloway/sophia/internal/datasources"
+)
+
+// maxListLimit caps the page size of a document listing
+const maxListLimit = 200
+
+// DocumentHandler lets the index be browsed, inspected and pruned
+type DocumentHandler struct {
+	db database.VectorDB
+}
+
+// NewDocumentHandler creates a handler backed by the given database
+func NewDocumentHandler(db database.VectorDB) *DocumentHandler {
+	return &DocumentHandler{db: db}
+}
+
+// Register adds the document routes to the mux
+func (h *DocumentHandler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("/api/v1/documents", h.handleDocuments)
+	mux.HandleFunc("/api/v1/documents/stats", h.handleStats)
+}
+
+// documentSummary describes a stored document without its content
+type documentSummary struct {
+	ID         string                 `json:"id"`
+	Source     string                 `json:"source"`
+	Title      string                 `json:"title"`
+	URL        string                 `json:"url,omitempty"`
+	Timestamp  time.Time              `json:"timestamp"`
+	ChunkCount int                    `json:"chunk_count"`
+	Metadata   map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// chunkDetail describes one stored chunk of a document
+type chunkDetail struct {
+	ID          string `json:"id"`
+	Index       int    `json:"index"`
+	Length      int    `json:"length"`
+	ContentHash string `json:"content_hash"`
+	Content     string `json:"content"`
+}
+
+func summarize(doc datasources.Document) documentSummary {
+	return documentSummary{
+		ID:         doc.ParentID,
+		Source:     doc.Source,
+		Title:      doc.Title,
+		URL:        doc.URL,
+		Timestamp:  doc.Timestamp,
+		ChunkCount: doc.ChunkCount,
+		Metadata:   doc.Metadata,
+	}
+}
+
+// handleDocuments lists documents on GET, or returns one with its chunks when
+// id is given, and removes a document with all of its chunks on DELETE. Lists
+// are newest first unless oldest=true; since and until take dates or RFC 3339 times.
+//
+//	GET    /api/v1/documents?source=gmail&since=2024-07-01&until=2024-08-01&limit=50&offset=50
+//	GET    /api/v1/documents?id=18c2f4a9b1e3d7c5
+//	DELETE /api/v1/documents?id=18c2f4a9b1e3d7c5
+func (h *DocumentHandler) handleDocuments(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("id")
+
+	switch {
+	case r.Method == http.MethodGet && id != "":
+		h.getDocument(w, r, id)
+	case r.Method == http.MethodGet:
+		h.listDocuments(w, r)
+	case r.Method == http.MethodDelete:
+		if id == "" {
+			writeError(w, http.StatusBadRequest, "id parameter is required")
+			return
+		}
+		h.deleteDocument(w, r, id)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+func (h *DocumentHandler) listDocuments(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+
+	limit := queryInt(r, "limit", 50)
+	if limit == 0 {
+		limit = 50
+	}
+	if limit > maxListLimit {
+		limit = maxListLimit
+	}
+	opts := database.ListOptions{
+		Source: params.Get("source"),
+		Newest: !formBool(r, "oldest"),
+		// One extra document tells whether there is another page
+		Limit:  limit + 1,
+		Offset: queryInt(r, "offset", 0),
+	}
+
+	var err error
+	if opts.Since, err = parseTimeParam(params.Get("since")); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid since: "+err.Error())
+		return
+	}
+	if opts.Until, err = parseTimeParam(params.Get("until")); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid until: "+err.Error())
+		return
+	}
+
+	docs, err := h.db.List(r.Context(), opts)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	hasMore := len(docs) > limit
+	if hasMore {
+		docs = docs[:limit]
+	}
+	summaries := make([]documentSummary, len(docs))
+	for i, doc := range docs {
+		summaries[i] = summarize(doc)
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"documents": summaries,
+		"offset":    opts.Offset,
+		"limit":     limit,
+		"has_more":  hasMore,
+	})
+}
+
+func (h *DocumentHandler) getDocument(w http.ResponseWriter, r *http.Request, id string) {
+	chunks, err := h.db.Chunks(r.Context(), id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if len(chunks) == 0 {
+		writeError(w, http.StatusNotFound, "document not found: "+id)
+		return
+	}
+
+	details := make([]chunkDetail, len(chunks))
+	for i, chunk := range chunks {
+		details[i] = chunkDetail{
+			ID:          chunk.ID,
+			Index:       chunk.ChunkIndex,
+			Length:      len(chunk.Content),
+			ContentHash: chunk.ContentHash(),
+			Content:     chunk.Content,
+		}
+	}
+
+	// The first chunk carries the document's title, URL and metadata
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"document": summarize(chunks[0]),
+		"chunks":   details,
+	})
+}
+
+func (h *DocumentHandler) deleteDocument(w http.ResponseWriter, r *http.Request, id string) {
+	chunks, err := h.db.Chunks(r.Context(), id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if len(chunks) == 0 {
+		writeError(w, http.StatusNotFound, "document not found: "+id)
+		return
+	}
+
+	parentID := chunks[0].ParentID
+	if err := h.db.Delete(r.Context(), []string{parentID}); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"id":     parentID,
+		"chunks": len(chunks),
+		"status": "deleted",
+	})
+}
+
+// handleStats reports per-source document and chunk counts and storage size.
+//
+//	GET /api/v1/documents/stats
+func (h *DocumentHandler) handleStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	stats, err := h.db.Stats(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, stats)
+}
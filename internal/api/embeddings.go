@@ -0,0 +1,33 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/michaelgalloway/sophia/internal/embeddings"
+)
+
+// EmbeddingHandler exposes embedding cache metrics
+type EmbeddingHandler struct {
+	cache *embeddings.CachedEmbedding
+}
+
+// NewEmbeddingHandler creates a handler reporting on the given cache
+func NewEmbeddingHandler(cache *embeddings.CachedEmbedding) *EmbeddingHandler {
+	return &EmbeddingHandler{cache: cache}
+}
+
+// Register adds the embedding routes to the mux
+func (h *EmbeddingHandler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("/api/v1/embeddings/cache", h.handleCache)
+}
+
+// handleCache returns cache hit and miss counts.
+//
+//	GET /api/v1/embeddings/cache
+func (h *EmbeddingHandler) handleCache(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	writeJSON(w, http.StatusOK, h.cache.Stats())
+}
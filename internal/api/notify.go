@@ -0,0 +1,70 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/michaelgalloway/sophia/internal/notify"
+)
+
+// NotifyHandler exposes the notification delivery log and a test message
+type NotifyHandler struct {
+	notifier *notify.Notifier
+}
+
+// NewNotifyHandler creates a handler backed by the given notifier
+func NewNotifyHandler(notifier *notify.Notifier) *NotifyHandler {
+	return &NotifyHandler{notifier: notifier}
+}
+
+// Register adds the notification routes to the mux
+func (h *NotifyHandler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("/api/v1/notifications", h.handleDeliveries)
+	mux.HandleFunc("/api/v1/notifications/test", h.handleTest)
+}
+
+// handleDeliveries lists the configured sinks and recent deliveries, newest first.
+//
+//	GET /api/v1/notifications?limit=50
+func (h *NotifyHandler) handleDeliveries(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	deliveries := []notify.Delivery{}
+	if deliveryLog := h.notifier.Log(); deliveryLog != nil {
+		deliveries = deliveryLog.Recent(queryInt(r, "limit", 50))
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"sinks":      h.notifier.Sinks(),
+		"deliveries": deliveries,
+	})
+}
+
+// handleTest sends a test message to every sink, to check their settings.
+//
+//	POST /api/v1/notifications/test
+func (h *NotifyHandler) handleTest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	if !h.notifier.Enabled() {
+		writeError(w, http.StatusConflict, "no notification sinks configured")
+		return
+	}
+
+	err := h.notifier.Send(r.Context(), notify.Message{
+		Kind:  "test",
+		Title: "Sophia test notification",
+		Body:  "Notifications are working.",
+	})
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"sinks":  h.notifier.Sinks(),
+		"status": notify.StatusDelivered,
+	})
+}
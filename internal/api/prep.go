@@ -0,0 +1,70 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/michaelgalloway/sophia/internal/prep"
+)
+
+// PrepHandler serves meeting prep packs
+type PrepHandler struct {
+	generator *prep.Generator
+}
+
+// NewPrepHandler creates a handler backed by the given generator
+func NewPrepHandler(generator *prep.Generator) *PrepHandler {
+	return &PrepHandler{generator: generator}
+}
+
+// Register adds the prep route to the mux
+func (h *PrepHandler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("/api/v1/prep", h.handlePrep)
+}
+
+// handlePrep returns the prep pack for the meeting matching q: an event ID, a
+// time such as "prep for my 2pm", words from the title, or nothing for the next
+// meeting. GET reuses the pack already prepared for the meeting unless refresh
+// is set; POST always prepares a new one and, with deliver=true, sends it to the
+// notification sinks.
+//
+//	GET  /api/v1/prep?q=2pm&refresh=true
+//	POST /api/v1/prep?q=design+review&deliver=true
+func (h *PrepHandler) handlePrep(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	now := time.Now()
+	event, err := h.generator.Find(r.Context(), r.FormValue("q"), now)
+	if errors.Is(err, prep.ErrNoEvent) {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if r.Method == http.MethodGet && !formBool(r, "refresh") {
+		if p, ok := h.generator.Cached(event.ParentID); ok && p.Start.Equal(event.Timestamp) {
+			writeJSON(w, http.StatusOK, p)
+			return
+		}
+	}
+
+	p, err := h.generator.Prepare(r.Context(), event, now)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if r.Method == http.MethodPost && formBool(r, "deliver") {
+		if err := h.generator.Deliver(r.Context(), p); err != nil {
+			writeError(w, http.StatusBadGateway, err.Error())
+			return
+		}
+	}
+	writeJSON(w, http.StatusOK, p)
+}
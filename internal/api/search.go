@@ -0,0 +1,162 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/michaelgalloway/sophia/internal/database"
+	"github.com/michaelgalloway/sophia/internal/embeddings"
+)
+
+// maxSearchLimit caps the page size of a search
+const maxSearchLimit = 50
+
+// SearchHandler exposes semantic search over the index without the chat model
+type SearchHandler struct {
+	embedder embeddings.EmbeddingService
+	db       database.VectorDB
+}
+
+// NewSearchHandler creates a handler searching db with query embeddings from embedder
+func NewSearchHandler(embedder embeddings.EmbeddingService, db database.VectorDB) *SearchHandler {
+	return &SearchHandler{embedder: embedder, db: db}
+}
+
+// Register adds the search route to the mux
+func (h *SearchHandler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("/api/v1/search", h.handleSearch)
+}
+
+// searchResult is one document in a search response
+type searchResult struct {
+	ID          string                 `json:"id"`
+	ChunkID     string                 `json:"chunk_id"`
+	ChunkIndex  int                    `json:"chunk_index"`
+	Source      string                 `json:"source"`
+	Title       string                 `json:"title"`
+	URL         string                 `json:"url,omitempty"`
+	Timestamp   time.Time              `json:"timestamp"`
+	Score       float64                `json:"score"`
+	Snippet     string                 `json:"snippet"`
+	Highlighted string                 `json:"highlighted"`
+	Metadata    map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// handleSearch returns the documents most similar to q, best first, each with a
+// snippet of its best-matching chunk. highlighted is HTML-escaped with query terms
+// in <mark>. source takes a comma-separated list; since and until take RFC 3339
+// times or dates; metadata takes a JSON object matched by containment. With
+// chunks=true every matching chunk is returned instead of one per document.
+//
+//	GET /api/v1/search?q=q3+budget&source=gmail,google_docs&since=2024-07-01&limit=10&offset=10
+func (h *SearchHandler) handleSearch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	params := r.URL.Query()
+	query := strings.TrimSpace(params.Get("q"))
+	if query == "" {
+		writeError(w, http.StatusBadRequest, "q parameter is required")
+		return
+	}
+
+	limit := queryInt(r, "limit", database.DefaultSearchLimit)
+	if limit == 0 {
+		limit = database.DefaultSearchLimit
+	}
+	if limit > maxSearchLimit {
+		limit = maxSearchLimit
+	}
+	opts := database.SearchOptions{
+		// One extra result tells whether there is another page
+		Limit:    limit + 1,
+		Offset:   queryInt(r, "offset", 0),
+		Distinct: !formBool(r, "chunks"),
+	}
+
+	for _, source := range strings.Split(params.Get("source"), ",") {
+		if source = strings.TrimSpace(source); source != "" {
+			opts.Sources = append(opts.Sources, source)
+		}
+	}
+
+	var err error
+	if opts.Since, err = parseTimeParam(params.Get("since")); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid since: "+err.Error())
+		return
+	}
+	if opts.Until, err = parseTimeParam(params.Get("until")); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid until: "+err.Error())
+		return
+	}
+	if metadata := params.Get("metadata"); metadata != "" {
+		if err := json.Unmarshal([]byte(metadata), &opts.Metadata); err != nil {
+			writeError(w, http.StatusBadRequest, "metadata must be a JSON object")
+			return
+		}
+	}
+
+	vector, err := h.embedder.QueryEmbedding(r.Context(), query)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	results, err := h.db.Search(r.Context(), vector, opts)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	hasMore := len(results) > limit
+	if hasMore {
+		results = results[:limit]
+	}
+
+	highlighter := newHighlighter(query)
+	response := make([]searchResult, len(results))
+	for i, result := range results {
+		doc := result.Document
+		snippet, highlighted := highlighter.snippet(doc.Content)
+		response[i] = searchResult{
+			ID:          doc.ParentID,
+			ChunkID:     doc.ID,
+			ChunkIndex:  doc.ChunkIndex,
+			Source:      doc.Source,
+			Title:       doc.Title,
+			URL:         doc.URL,
+			Timestamp:   doc.Timestamp,
+			Score:       result.Score,
+			Snippet:     snippet,
+			Highlighted: highlighted,
+			Metadata:    doc.Metadata,
+		}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"query":    query,
+		"results":  response,
+		"offset":   opts.Offset,
+		"limit":    limit,
+		"has_more": hasMore,
+	})
+}
+
+// parseTimeParam parses an RFC 3339 time or a date in local time. An empty value is the zero time.
+func parseTimeParam(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", value, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("expected a date or RFC 3339 time, got %q", value)
+	}
+	return t, nil
+}
@@ -0,0 +1,131 @@
+package api
+
+import (
+	"html"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// snippetLength is the approximate length of a result snippet, in bytes
+	snippetLength = 240
+
+	// snippetLead is how much text is kept before the first highlighted term
+	snippetLead = 40
+)
+
+// snippetStopWords are query words too common to be worth highlighting
+var snippetStopWords = map[string]bool{
+	"the": true, "and": true, "for": true, "with": true, "from": true, "that": true,
+	"this": true, "what": true, "when": true, "where": true, "who": true, "about": true,
+	"are": true, "was": true, "were": true, "has": true, "have": true, "any": true,
+}
+
+// highlighter finds the query's terms in result text
+type highlighter struct {
+	terms *regexp.Regexp
+}
+
+// newHighlighter builds a highlighter for the words of query. It matches nothing
+// when the query has no words worth highlighting.
+func newHighlighter(query string) *highlighter {
+	seen := make(map[string]bool)
+	var words []string
+	for _, word := range strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r > 127)
+	}) {
+		// Short words are kept only with a digit, as in "Q3"
+		if len(word) < 2 || len(word) < 3 && !strings.ContainsAny(word, "0123456789") ||
+			snippetStopWords[word] || seen[word] {
+			continue
+		}
+		seen[word] = true
+		words = append(words, regexp.QuoteMeta(word))
+	}
+	if len(words) == 0 {
+		return &highlighter{}
+	}
+	return &highlighter{terms: regexp.MustCompile(`(?i)\b(?:` + strings.Join(words, "|") + `)`)}
+}
+
+// snippet picks the part of content with the most distinct query terms and
+// returns it as plain text and as HTML with the terms wrapped in <mark>
+func (h *highlighter) snippet(content string) (plain, highlighted string) {
+	content = strings.Join(strings.Fields(content), " ")
+
+	var matches [][]int
+	if h.terms != nil {
+		matches = h.terms.FindAllStringIndex(content, -1)
+	}
+
+	// Slide a window over the matches, keeping the one covering the most distinct terms
+	start := 0
+	best := 0
+	for i, m := range matches {
+		distinct := make(map[string]bool)
+		for _, n := range matches[i:] {
+			if n[1] > m[0]+snippetLength-snippetLead {
+				break
+			}
+			distinct[strings.ToLower(content[n[0]:n[1]])] = true
+		}
+		if len(distinct) > best {
+			best = len(distinct)
+			start = m[0] - snippetLead
+		}
+	}
+	if start < 0 {
+		start = 0
+	}
+	end := start + snippetLength
+	if end >= len(content) {
+		end = len(content)
+		start = end - snippetLength
+		if start < 0 {
+			start = 0
+		}
+	}
+
+	// Snap to word boundaries, falling back to rune boundaries
+	if start > 0 {
+		if i := strings.IndexByte(content[start:], ' '); i >= 0 && i < snippetLead {
+			start += i + 1
+		}
+		for start < len(content) && !utf8.RuneStart(content[start]) {
+			start++
+		}
+	}
+	if end < len(content) {
+		if i := strings.LastIndexByte(content[start:end], ' '); i > 0 {
+			end = start + i
+		}
+		for end > start && !utf8.RuneStart(content[end]) {
+			end--
+		}
+	}
+
+	prefix, suffix := "", ""
+	if start > 0 {
+		prefix = "…"
+	}
+	if end < len(content) {
+		suffix = "…"
+	}
+	window := content[start:end]
+	plain = prefix + window + suffix
+
+	var sb strings.Builder
+	sb.WriteString(prefix)
+	last := 0
+	if h.terms != nil {
+		for _, m := range h.terms.FindAllStringIndex(window, -1) {
+			sb.WriteString(html.EscapeString(window[last:m[0]]))
+			sb.WriteString("<mark>" + html.EscapeString(window[m[0]:m[1]]) + "</mark>")
+			last = m[1]
+		}
+	}
+	sb.WriteString(html.EscapeString(window[last:]))
+	sb.WriteString(suffix)
+	return plain, sb.String()
+}
@@ -0,0 +1,84 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/michaelgalloway/sophia/internal/scheduler"
+)
+
+// SyncHandler exposes sync status, history and manual triggers for the scheduler
+type SyncHandler struct {
+	scheduler *scheduler.Scheduler
+}
+
+// NewSyncHandler creates a handler backed by the given scheduler
+func NewSyncHandler(sched *scheduler.Scheduler) *SyncHandler {
+	return &SyncHandler{scheduler: sched}
+}
+
+// Register adds the sync routes to the mux
+func (h *SyncHandler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("/api/v1/sync", h.handleSync)
+	mux.HandleFunc("/api/v1/sync/history", h.handleHistory)
+}
+
+// handleSync lists per-source status on GET and triggers a sync on POST.
+//
+//	GET  /api/v1/sync
+//	POST /api/v1/sync?source=gmail&full=true
+func (h *SyncHandler) handleSync(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"sources": h.scheduler.Status(),
+		})
+
+	case http.MethodPost:
+		source := r.FormValue("source")
+		if source == "" {
+			writeError(w, http.StatusBadRequest, "source parameter is required")
+			return
+		}
+		full := formBool(r, "full")
+
+		err := h.scheduler.TriggerSync(source, full)
+		switch {
+		case errors.Is(err, scheduler.ErrUnknownSource):
+			writeError(w, http.StatusNotFound, err.Error())
+		case errors.Is(err, scheduler.ErrSyncInProgress):
+			writeError(w, http.StatusConflict, err.Error())
+		case err != nil:
+			writeError(w, http.StatusInternalServerError, err.Error())
+		default:
+			writeJSON(w, http.StatusAccepted, map[string]interface{}{
+				"source": source,
+				"full":   full,
+				"status": "started",
+			})
+		}
+
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// handleHistory returns recorded runs, optionally filtered by source.
+//
+//	GET /api/v1/sync/history?source=slack&limit=20
+func (h *SyncHandler) handleHistory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	runs, err := h.scheduler.History(r.URL.Query().Get("source"), queryInt(r, "limit", 50))
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"runs": runs,
+	})
+}
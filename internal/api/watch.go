@@ -0,0 +1,82 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/michaelgalloway/sophia/internal/watch"
+)
+
+// WatchHandler manages saved queries that notify about new matching documents
+type WatchHandler struct {
+	watcher *watch.Watcher
+}
+
+// NewWatchHandler creates a handler backed by the given watcher
+func NewWatchHandler(watcher *watch.Watcher) *WatchHandler {
+	return &WatchHandler{watcher: watcher}
+}
+
+// Register adds the watch routes to the mux
+func (h *WatchHandler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("/api/v1/watches", h.handleWatches)
+}
+
+// handleWatches lists watches with their recent matches on GET, creates one from
+// a JSON body on POST and removes one on DELETE.
+//
+//	GET    /api/v1/watches
+//	POST   /api/v1/watches {"name": "Q3 budget", "query": "anything mentioning the Q3 budget", "threshold": 0.45}
+//	POST   /api/v1/watches {"name": "Legal", "sources": ["gmail"], "metadata": {"from": "legal@example.com"}}
+//	DELETE /api/v1/watches?id=3f2a9c1b7d4e8f60
+func (h *WatchHandler) handleWatches(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"watches": h.watcher.List(),
+		})
+
+	case http.MethodPost:
+		var body watch.Watch
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid JSON body")
+			return
+		}
+
+		created, err := h.watcher.Create(r.Context(), body)
+		if errors.Is(err, watch.ErrInvalid) {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusCreated, created)
+
+	case http.MethodDelete:
+		id := r.URL.Query().Get("id")
+		if id == "" {
+			writeError(w, http.StatusBadRequest, "id parameter is required")
+			return
+		}
+
+		err := h.watcher.Delete(id)
+		if errors.Is(err, watch.ErrNotFound) {
+			writeError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"id":     id,
+			"status": "deleted",
+		})
+
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
@@ -0,0 +1,236 @@
+package briefing
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/michaelgalloway/sophia/internal/database"
+	"github.com/michaelgalloway/sophia/internal/notify"
+)
+
+const (
+	defaultEmailLookback = 7 * 24 * time.Hour
+	defaultSlackLookback = 24 * time.Hour
+
+	// maxItems caps each section so the summary prompt stays small
+	maxItems = 20
+
+	// maxExcerpt bounds how much of each document is given to the model
+	maxExcerpt = 800
+)
+
+// instructions tell the chat model how to write the briefing
+const instructions = `You write a short morning briefing for the user from the items below.
+Start with one or two sentences on the shape of the day. Then cover the meetings in time order,
+noting overlaps and back-to-back stretches, the tasks that are overdue or due today with the most
+urgent first, emails that likely need a reply, and Slack threads where the user was mentioned.
+Skip empty sections. Be concise and use plain text with short bullet lists.`
+
+// Summarizer turns the assembled items into prose
+type Summarizer interface {
+	Summarize(ctx context.Context, instructions, content string) (string, error)
+}
+
+// Config controls what a briefing includes
+type Config struct {
+	// SlackUserID is the user whose Slack mentions are included; none are when empty
+	SlackUserID string
+	// EmailLookback limits unread important email to threads active this recently
+	EmailLookback time.Duration
+	// SlackLookback limits mentions to threads active this recently
+	SlackLookback time.Duration
+}
+
+// Item is one calendar event, task, email thread or Slack thread in a briefing
+type Item struct {
+	Title  string    `json:"title"`
+	Detail string    `json:"detail,omitempty"`
+	URL    string    `json:"url,omitempty"`
+	Time   time.Time `json:"time"`
+
+	// excerpt is the start of the document, given to the model for context
+	excerpt string
+}
+
+// Briefing summarizes one day
+type Briefing struct {
+	Date        string    `json:"date"`
+	GeneratedAt time.Time `json:"generated_at"`
+	Summary     string    `json:"summary"`
+	Events      []Item    `json:"events"`
+	Tasks       []Item    `json:"tasks"`
+	Emails      []Item    `json:"emails"`
+	Mentions    []Item    `json:"mentions"`
+}
+
+// Generator assembles briefings from the index and delivers them
+type Generator struct {
+	db         database.VectorDB
+	summarizer Summarizer
+	notifier   *notify.Notifier
+	config     Config
+
+	mu     sync.Mutex
+	latest *Briefing
+}
+
+// NewGenerator creates a briefing generator. notifier may be nil when briefings
+// are only read through the API.
+func NewGenerator(db database.VectorDB, summarizer Summarizer, notifier *notify.Notifier, config Config) *Generator {
+	if config.EmailLookback <= 0 {
+		config.EmailLookback = defaultEmailLookback
+	}
+	if config.SlackLookback <= 0 {
+		config.SlackLookback = defaultSlackLookback
+	}
+	return &Generator{
+		db:         db,
+		summarizer: summarizer,
+		notifier:   notifier,
+		config:     config,
+	}
+}
+
+// Generate builds the briefing for the day containing now
+func (g *Generator) Generate(ctx context.Context, now time.Time) (*Briefing, error) {
+	dayStart := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	dayEnd := dayStart.AddDate(0, 0, 1)
+
+	b := &Briefing{
+		Date:        dayStart.Format("2006-01-02"),
+		GeneratedAt: now,
+	}
+
+	var err error
+	if b.Events, err = g.events(ctx, dayStart, dayEnd); err != nil {
+		return nil, err
+	}
+	if b.Tasks, err = g.tasks(ctx, dayStart); err != nil {
+		return nil, err
+	}
+	if b.Emails, err = g.emails(ctx, now); err != nil {
+		return nil, err
+	}
+	if b.Mentions, err = g.mentions(ctx, now); err != nil {
+		return nil, err
+	}
+
+	if len(b.Events)+len(b.Tasks)+len(b.Emails)+len(b.Mentions) == 0 {
+		b.Summary = "Nothing on your calendar, no tasks due, no unread important email and no new Slack mentions today."
+	} else {
+		b.Summary, err = g.summarizer.Summarize(ctx, instructions, b.prompt(now))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	g.mu.Lock()
+	g.latest = b
+	g.mu.Unlock()
+	return b, nil
+}
+
+// Latest returns the most recently generated briefing, if any
+func (g *Generator) Latest() (*Briefing, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.latest, g.latest != nil
+}
+
+// Deliver sends a briefing to every notification sink
+func (g *Generator) Deliver(ctx context.Context, b *Briefing) error {
+	if g.notifier == nil || !g.notifier.Enabled() {
+		return nil
+	}
+	return g.notifier.Send(ctx, notify.Message{
+		Kind:  "briefing",
+		Title: "Your briefing for " + b.day(),
+		Body:  b.Text(),
+		Data:  b,
+	})
+}
+
+// Run generates today's briefing and delivers it. It is the scheduled job.
+func (g *Generator) Run(ctx context.Context) error {
+	b, err := g.Generate(ctx, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to generate briefing: %w", err)
+	}
+	return g.Deliver(ctx, b)
+}
+
+// Text renders the briefing as a notification: the summary followed by links
+func (b *Briefing) Text() string {
+	var sb strings.Builder
+	sb.WriteString(strings.TrimSpace(b.Summary))
+
+	sections := []struct {
+		name  string
+		items []Item
+	}{
+		{"Meetings", b.Events},
+		{"Tasks", b.Tasks},
+		{"Email", b.Emails},
+		{"Slack", b.Mentions},
+	}
+	for _, section := range sections {
+		if len(section.items) == 0 {
+			continue
+		}
+		sb.WriteString("\n\n" + section.name + ":")
+		for _, item := range section.items {
+			line := item.Title
+			if item.Detail != "" {
+				line += " (" + item.Detail + ")"
+			}
+			if item.URL != "" {
+				line += " " + item.URL
+			}
+			sb.WriteString("\n- " + line)
+		}
+	}
+	return sb.String()
+}
+
+// prompt lays out the items for the model
+func (b *Briefing) prompt(now time.Time) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Today is %s. The time is %s.\n", b.day(), now.Format("15:04"))
+
+	sections := []struct {
+		name  string
+		items []Item
+	}{
+		{"Calendar events today", b.Events},
+		{"Open tasks overdue or due today", b.Tasks},
+		{"Unread important email threads", b.Emails},
+		{"Slack threads mentioning the user", b.Mentions},
+	}
+	for _, section := range sections {
+		if len(section.items) == 0 {
+			continue
+		}
+		fmt.Fprintf(&sb, "\n## %s\n", section.name)
+		for _, item := range section.items {
+			fmt.Fprintf(&sb, "\n### %s\n", item.Title)
+			if item.Detail != "" {
+				sb.WriteString(item.Detail + "\n")
+			}
+			if item.excerpt != "" {
+				sb.WriteString(item.excerpt + "\n")
+			}
+		}
+	}
+	return sb.String()
+}
+
+func (b *Briefing) day() string {
+	date, err := time.Parse("2006-01-02", b.Date)
+	if err != nil {
+		return b.Date
+	}
+	return date.Format("Monday, January 2")
+}
@@ -0,0 +1,243 @@
+package briefing
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/michaelgalloway/sophia/internal/database"
+	"github.com/michaelgalloway/sophia/internal/datasources"
+)
+
+// multiDayLookback is how far back events are read to catch ones that started
+// earlier and run into today, such as conferences and vacations
+const multiDayLookback = 14 * 24 * time.Hour
+
+// priorityLabels maps Todoist API priorities, where 4 is the most urgent, to the app's labels
+var priorityLabels = map[int]string{4: "p1", 3: "p2", 2: "p3"}
+
+// events lists calendar events that overlap the day
+func (g *Generator) events(ctx context.Context, dayStart, dayEnd time.Time) ([]Item, error) {
+	docs, err := g.db.List(ctx, database.ListOptions{
+		Source: "google_calendar",
+		Since:  dayStart.Add(-multiDayLookback),
+		Until:  dayEnd,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list calendar events: %w", err)
+	}
+
+	var items []Item
+	for _, doc := range docs {
+		end, err := time.Parse(time.RFC3339, metaString(doc, "end_time"))
+		if err == nil && !end.After(dayStart) {
+			continue
+		}
+		if metaString(doc, "my_response") == "declined" {
+			continue
+		}
+
+		var detail string
+		if metaBool(doc, "all_day") {
+			detail = "all day"
+		} else {
+			detail = doc.Timestamp.In(dayStart.Location()).Format("15:04")
+			if err == nil {
+				detail += "–" + end.In(dayStart.Location()).Format("15:04")
+			}
+		}
+		if location := metaString(doc, "location"); location != "" {
+			detail += ", " + location
+		}
+
+		items = append(items, Item{
+			Title:   doc.Title,
+			Detail:  detail,
+			URL:     doc.URL,
+			Time:    doc.Timestamp,
+			excerpt: excerpt(doc.Content),
+		})
+	}
+	return limit(items), nil
+}
+
+// tasks lists open Todoist tasks that are overdue or due today, most urgent first
+func (g *Generator) tasks(ctx context.Context, dayStart time.Time) ([]Item, error) {
+	docs, err := g.db.List(ctx, database.ListOptions{
+		Source:   "todoist",
+		Metadata: map[string]interface{}{"completed": false},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tasks: %w", err)
+	}
+
+	today := dayStart.Format("2006-01-02")
+	type dueTask struct {
+		item     Item
+		due      string
+		priority int
+	}
+	var due []dueTask
+	for _, doc := range docs {
+		date := metaString(doc, "due")
+		if date == "" || date > today {
+			continue
+		}
+
+		var details []string
+		if date < today {
+			details = append(details, "overdue since "+date)
+		} else if datetime, ok := dueTime(metaString(doc, "due_datetime"), dayStart.Location()); ok {
+			details = append(details, "due "+datetime.Format("15:04"))
+		} else {
+			details = append(details, "due today")
+		}
+		priority := metaInt(doc, "priority")
+		if label, ok := priorityLabels[priority]; ok {
+			details = append(details, label)
+		}
+		if project := metaString(doc, "project_name"); project != "" {
+			details = append(details, project)
+		}
+
+		due = append(due, dueTask{
+			item: Item{
+				Title:   doc.Title,
+				Detail:  strings.Join(details, ", "),
+				URL:     doc.URL,
+				Time:    doc.Timestamp,
+				excerpt: excerpt(doc.Content),
+			},
+			due:      date,
+			priority: priority,
+		})
+	}
+
+	sort.SliceStable(due, func(i, j int) bool {
+		if due[i].priority != due[j].priority {
+			return due[i].priority > due[j].priority
+		}
+		return due[i].due < due[j].due
+	})
+
+	items := make([]Item, len(due))
+	for i, task := range due {
+		items[i] = task.item
+	}
+	return limit(items), nil
+}
+
+// emails lists recent unread threads Gmail marked as important
+func (g *Generator) emails(ctx context.Context, now time.Time) ([]Item, error) {
+	docs, err := g.db.List(ctx, database.ListOptions{
+		Source: "gmail",
+		Since:  now.Add(-g.config.EmailLookback),
+		Metadata: map[string]interface{}{
+			"unread": true,
+			"labels": []string{"IMPORTANT"},
+		},
+		Newest: true,
+		Limit:  maxItems,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list email threads: %w", err)
+	}
+
+	var items []Item
+	for _, doc := range docs {
+		items = append(items, Item{
+			Title:   doc.Title,
+			Detail:  "from " + metaString(doc, "from"),
+			URL:     doc.URL,
+			Time:    doc.Timestamp,
+			excerpt: excerpt(doc.Content),
+		})
+	}
+	return items, nil
+}
+
+// mentions lists Slack threads active recently in which the user was mentioned
+func (g *Generator) mentions(ctx context.Context, now time.Time) ([]Item, error) {
+	if g.config.SlackUserID == "" {
+		return nil, nil
+	}
+
+	docs, err := g.db.List(ctx, database.ListOptions{
+		Source:   "slack",
+		Since:    now.Add(-g.config.SlackLookback),
+		Metadata: map[string]interface{}{"mentions": []string{g.config.SlackUserID}},
+		Newest:   true,
+		Limit:    maxItems,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list Slack threads: %w", err)
+	}
+
+	var items []Item
+	for _, doc := range docs {
+		items = append(items, Item{
+			Title:   doc.Title,
+			Detail:  metaString(doc, "channel"),
+			URL:     doc.URL,
+			Time:    doc.Timestamp,
+			excerpt: excerpt(doc.Content),
+		})
+	}
+	return items, nil
+}
+
+func limit(items []Item) []Item {
+	if len(items) > maxItems {
+		return items[:maxItems]
+	}
+	return items
+}
+
+// excerpt returns the start of a document's content for the prompt
+func excerpt(content string) string {
+	content = strings.TrimSpace(content)
+	if len(content) <= maxExcerpt {
+		return content
+	}
+	cut := maxExcerpt
+	for cut > 0 && !utf8.RuneStart(content[cut]) {
+		cut--
+	}
+	return content[:cut] + "…"
+}
+
+// dueTime parses a Todoist due time into loc. Times without a zone float with
+// the user, so they are read as local to loc.
+func dueTime(s string, loc *time.Location) (time.Time, bool) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t.In(loc), true
+	}
+	if t, err := time.ParseInLocation("2006-01-02T15:04:05", s, loc); err == nil {
+		return t, true
+	}
+	return time.Time{}, false
+}
+
+func metaString(doc datasources.Document, key string) string {
+	s, _ := doc.Metadata[key].(string)
+	return s
+}
+
+func metaBool(doc datasources.Document, key string) bool {
+	b, _ := doc.Metadata[key].(bool)
+	return b
+}
+
+// metaInt reads a number from metadata, which decodes from JSON as float64
+func metaInt(doc datasources.Document, key string) int {
+	switch n := doc.Metadata[key].(type) {
+	case float64:
+		return int(n)
+	case int:
+		return n
+	}
+	return 0
+}
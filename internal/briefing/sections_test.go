@@ -0,0 +1,63 @@
+package briefing
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/michaelgalloway/sophia/internal/database"
+	"github.com/michaelgalloway/sophia/internal/datasources"
+)
+
+// listDB returns fixed documents from List
+type listDB struct {
+	database.VectorDB
+	docs []datasources.Document
+}
+
+func (db *listDB) List(ctx context.Context, opts database.ListOptions) ([]datasources.Document, error) {
+	return db.docs, nil
+}
+
+func TestTasksDue(t *testing.T) {
+	lisbon, err := time.LoadLocation("Europe/Lisbon")
+	if err != nil {
+		t.Skipf("time zone data unavailable: %v", err)
+	}
+	dayStart := time.Date(2026, 10, 19, 0, 0, 0, 0, lisbon)
+
+	task := func(title, due, datetime string) datasources.Document {
+		return datasources.Document{
+			Title:    title,
+			Metadata: map[string]interface{}{"due": due, "due_datetime": datetime, "priority": 1.0},
+		}
+	}
+	db := &listDB{docs: []datasources.Document{
+		task("All day", "2026-10-19", ""),
+		task("Floating", "2026-10-19", "2026-10-19T12:00:00"),
+		task("Fixed", "2026-10-19", "2026-10-19T10:00:00Z"),
+		task("Overdue", "2026-10-17", "2026-10-17T09:00:00"),
+		task("Tomorrow", "2026-10-20", "2026-10-20T09:00:00"),
+		task("No date", "", ""),
+	}}
+
+	items, err := (&Generator{db: db}).tasks(context.Background(), dayStart)
+	if err != nil {
+		t.Fatalf("tasks() error = %v", err)
+	}
+
+	want := map[string]string{
+		"All day":  "due today",
+		"Floating": "due 12:00",
+		"Fixed":    "due 11:00",
+		"Overdue":  "overdue since 2026-10-17",
+	}
+	if len(items) != len(want) {
+		t.Fatalf("tasks() = %+v, want %d items", items, len(want))
+	}
+	for _, item := range items {
+		if detail, ok := want[item.Title]; !ok || item.Detail != detail {
+			t.Errorf("%q detail = %q, want %q", item.Title, item.Detail, detail)
+		}
+	}
+}
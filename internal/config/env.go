@@ -0,0 +1,75 @@
+package config
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// GetEnv returns the value of an environment variable or def when it is unset
+func GetEnv(key, def string) string {
+	if v, ok := os.LookupEnv(key); ok && strings.TrimSpace(v) != "" {
+		return strings.TrimSpace(v)
+	}
+	return def
+}
+
+// GetEnvInt returns an integer environment variable or def when it is unset or invalid
+func GetEnvInt(key string, def int) int {
+	v := GetEnv(key, "")
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		log.Printf("Warning: invalid integer for %s: %q, using %d", key, v, def)
+		return def
+	}
+	return n
+}
+
+// GetEnvBool returns a boolean environment variable or def when it is unset or invalid
+func GetEnvBool(key string, def bool) bool {
+	v := GetEnv(key, "")
+	if v == "" {
+		return def
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		log.Printf("Warning: invalid boolean for %s: %q, using %t", key, v, def)
+		return def
+	}
+	return b
+}
+
+// GetEnvDuration returns a duration environment variable (e.g. "90s", "15m") or def when it is unset or invalid
+func GetEnvDuration(key string, def time.Duration) time.Duration {
+	v := GetEnv(key, "")
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		log.Printf("Warning: invalid duration for %s: %q, using %v", key, v, def)
+		return def
+	}
+	return d
+}
+
+// GetEnvList splits a comma-separated environment variable, dropping empty entries
+func GetEnvList(key string) []string {
+	var out []string
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// EnvKey converts a name such as "google_calendar" into an environment variable suffix like "GOOGLE_CALENDAR"
+func EnvKey(name string) string {
+	return strings.ToUpper(strings.NewReplacer("-", "_", " ", "_", ".", "_").Replace(name))
+}
@@ -24,9 +24,9 @@ func DefaultConfig() DataSourceConfig {
 func DisableAll() DataSourceConfig {
 	return DataSourceConfig{
 		GoogleCalendar: false,
-		Gmail:         false,
-		GoogleDocs:    false,
-		Slack:         false,
-		Todoist:       false,
+		Gmail:          false,
+		GoogleDocs:     false,
+		Slack:          false,
+		Todoist:        false,
 	}
 }
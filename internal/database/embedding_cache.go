@@ -0,0 +1,79 @@
+package database
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/lib/pq"
+	"github.com/pgvector/pgvector-go"
+
+	"github.com/michaelgalloway/sophia/internal/embeddings"
+)
+
+// EmbeddingCache stores embeddings in Postgres keyed by model and text hash.
+// It implements embeddings.Cache.
+type EmbeddingCache struct {
+	vectorDB *PGVectorDB
+}
+
+// EmbeddingCache returns a cache backed by the same database. The table is
+// created by Initialize.
+func (p *PGVectorDB) EmbeddingCache() *EmbeddingCache {
+	return &EmbeddingCache{vectorDB: p}
+}
+
+func (c *EmbeddingCache) Get(ctx context.Context, model string, hashes []string) (map[string]embeddings.Vector, error) {
+	vectors := make(map[string]embeddings.Vector, len(hashes))
+	if len(hashes) == 0 {
+		return vectors, nil
+	}
+
+	rows, err := c.vectorDB.db.QueryContext(ctx, `
+		SELECT hash, embedding FROM embedding_cache
+		WHERE model = $1 AND hash = ANY($2)
+	`, model, pq.Array(hashes))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query embedding cache: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var hash string
+		var vec pgvector.Vector
+		if err := rows.Scan(&hash, &vec); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		vectors[hash] = vec.Slice()
+	}
+	return vectors, rows.Err()
+}
+
+func (c *EmbeddingCache) Put(ctx context.Context, model string, vectors map[string]embeddings.Vector) error {
+	if len(vectors) == 0 {
+		return nil
+	}
+
+	tx, err := c.vectorDB.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO embedding_cache (model, hash, embedding)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (model, hash) DO NOTHING
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare statement: %w", err)
+	}
+	defer stmt.Close()
+
+	for hash, vec := range vectors {
+		if _, err := stmt.ExecContext(ctx, model, hash, pgvector.NewVector(vec)); err != nil {
+			return fmt.Errorf("failed to insert cached embedding: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
@@ -5,8 +5,11 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"log"
+	"strings"
+	"time"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 	"github.com/pgvector/pgvector-go"
 
 	"github.com/michaelgalloway/sophia/internal/datasources"
@@ -58,6 +61,53 @@ func (p *PGVectorDB) Initialize(ctx context.Context) error {
 		return fmt.Errorf("failed to create documents table: %w", err)
 	}
 
+	// Add columns introduced after the initial schema
+	_, err = p.db.ExecContext(ctx, `
+		ALTER TABLE documents
+			ADD COLUMN IF NOT EXISTS title TEXT NOT NULL DEFAULT '',
+			ADD COLUMN IF NOT EXISTS url TEXT NOT NULL DEFAULT '',
+			ADD COLUMN IF NOT EXISTS parent_id TEXT,
+			ADD COLUMN IF NOT EXISTS chunk_index INTEGER NOT NULL DEFAULT 0,
+			ADD COLUMN IF NOT EXISTS chunk_count INTEGER NOT NULL DEFAULT 1,
+			ADD COLUMN IF NOT EXISTS content_hash TEXT
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to migrate documents table: %w", err)
+	}
+
+	// Rows without a content hash predate it and may be stored under IDs that
+	// sources no longer produce, such as per-message Gmail and Slack IDs, so they
+	// would never be replaced. Every store sets the hash, so this runs only once
+	// after upgrading; the startup sync stores the documents again.
+	result, err := p.db.ExecContext(ctx, "DELETE FROM documents WHERE content_hash IS NULL")
+	if err != nil {
+		return fmt.Errorf("failed to purge legacy documents: %w", err)
+	}
+	if purged, err := result.RowsAffected(); err == nil && purged > 0 {
+		log.Printf("Removed %d documents stored by an earlier version; they will be synced again", purged)
+	}
+
+	_, err = p.db.ExecContext(ctx, `
+		CREATE INDEX IF NOT EXISTS documents_parent_id_idx ON documents (parent_id)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create parent index: %w", err)
+	}
+
+	// Create the embedding cache table, shared by all sources and queries
+	_, err = p.db.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS embedding_cache (
+			model TEXT NOT NULL,
+			hash TEXT NOT NULL,
+			embedding vector NOT NULL,
+			created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+			PRIMARY KEY (model, hash)
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create embedding cache table: %w", err)
+	}
+
 	// Create an index for faster similarity search
 	_, err = p.db.ExecContext(ctx, `
 		CREATE INDEX IF NOT EXISTS documents_embedding_idx ON documents 
@@ -79,19 +129,32 @@ func (p *PGVectorDB) Store(ctx context.Context, docs []datasources.Document, vec
 	defer tx.Rollback()
 
 	stmt, err := tx.PrepareContext(ctx, `
-		INSERT INTO documents (id, content, metadata, source, timestamp, embedding)
-		VALUES ($1, $2, $3, $4, $5, $6)
+		INSERT INTO documents (id, content, metadata, source, timestamp, embedding,
+			title, url, parent_id, chunk_index, chunk_count, content_hash)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
 		ON CONFLICT (id) DO UPDATE SET
 			content = EXCLUDED.content,
 			metadata = EXCLUDED.metadata,
 			timestamp = EXCLUDED.timestamp,
-			embedding = EXCLUDED.embedding
+			embedding = EXCLUDED.embedding,
+			title = EXCLUDED.title,
+			url = EXCLUDED.url,
+			parent_id = EXCLUDED.parent_id,
+			chunk_index = EXCLUDED.chunk_index,
+			chunk_count = EXCLUDED.chunk_count,
+			content_hash = EXCLUDED.content_hash
 	`)
 	if err != nil {
 		return fmt.Errorf("failed to prepare statement: %w", err)
 	}
 	defer stmt.Close()
 
+	staleStmt, err := prepareStaleChunks(ctx, tx)
+	if err != nil {
+		return err
+	}
+	defer staleStmt.Close()
+
 	for i, doc := range docs {
 		metadata, err := json.Marshal(doc.Metadata)
 		if err != nil {
@@ -100,25 +163,176 @@ func (p *PGVectorDB) Store(ctx context.Context, docs []datasources.Document, vec
 
 		vec := pgvector.NewVector(vectors[i])
 
-		_, err = stmt.ExecContext(ctx, doc.ID, doc.Content, metadata, doc.Source, doc.Timestamp, vec)
+		parentID, chunkCount := chunkInfo(doc)
+
+		_, err = stmt.ExecContext(ctx, doc.ID, doc.Content, metadata, doc.Source, doc.Timestamp, vec,
+			doc.Title, doc.URL, parentID, doc.ChunkIndex, chunkCount, doc.ContentHash())
 		if err != nil {
 			return fmt.Errorf("failed to insert document: %w", err)
 		}
+
+		if err := removeStaleChunks(ctx, staleStmt, doc); err != nil {
+			return err
+		}
 	}
 
 	return tx.Commit()
 }
 
-func (p *PGVectorDB) Search(ctx context.Context, queryVector embeddings.Vector, limit int) ([]SearchResult, error) {
-	vec := pgvector.NewVector(queryVector)
+func (p *PGVectorDB) ContentHashes(ctx context.Context, ids []string) (map[string]string, error) {
+	hashes := make(map[string]string, len(ids))
+	if len(ids) == 0 {
+		return hashes, nil
+	}
 
 	rows, err := p.db.QueryContext(ctx, `
-		SELECT id, content, metadata, source, timestamp, 
-			1 - (embedding <=> $1) as similarity
-		FROM documents
-		ORDER BY embedding <=> $1
-		LIMIT $2
-	`, vec, limit)
+		SELECT id, content_hash FROM documents
+		WHERE id = ANY($1) AND content_hash IS NOT NULL
+	`, pq.Array(ids))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query content hashes: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id, hash string
+		if err := rows.Scan(&id, &hash); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		hashes[id] = hash
+	}
+	return hashes, rows.Err()
+}
+
+func (p *PGVectorDB) UpdateMetadata(ctx context.Context, docs []datasources.Document) error {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, `
+		UPDATE documents SET
+			metadata = $2,
+			timestamp = $3,
+			title = $4,
+			url = $5,
+			chunk_count = $6
+		WHERE id = $1
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare statement: %w", err)
+	}
+	defer stmt.Close()
+
+	staleStmt, err := prepareStaleChunks(ctx, tx)
+	if err != nil {
+		return err
+	}
+	defer staleStmt.Close()
+
+	for _, doc := range docs {
+		metadata, err := json.Marshal(doc.Metadata)
+		if err != nil {
+			return fmt.Errorf("failed to marshal metadata: %w", err)
+		}
+
+		_, chunkCount := chunkInfo(doc)
+		_, err = stmt.ExecContext(ctx, doc.ID, metadata, doc.Timestamp, doc.Title, doc.URL, chunkCount)
+		if err != nil {
+			return fmt.Errorf("failed to update document: %w", err)
+		}
+
+		if err := removeStaleChunks(ctx, staleStmt, doc); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// chunkInfo returns the parent ID and chunk count to store for a document
+func chunkInfo(doc datasources.Document) (string, int) {
+	parentID := doc.ParentID
+	if parentID == "" {
+		parentID = doc.ID
+	}
+	chunkCount := doc.ChunkCount
+	if chunkCount < 1 {
+		chunkCount = 1
+	}
+	return parentID, chunkCount
+}
+
+// prepareStaleChunks prepares the statement that removes chunks left over from a
+// previous, longer version of a document
+func prepareStaleChunks(ctx context.Context, tx *sql.Tx) (*sql.Stmt, error) {
+	stmt, err := tx.PrepareContext(ctx, `
+		DELETE FROM documents WHERE parent_id = $1 AND chunk_index >= $2
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare statement: %w", err)
+	}
+	return stmt, nil
+}
+
+// removeStaleChunks deletes extra chunks once the first chunk of a document is written
+func removeStaleChunks(ctx context.Context, stmt *sql.Stmt, doc datasources.Document) error {
+	if doc.ChunkIndex != 0 {
+		return nil
+	}
+	parentID, chunkCount := chunkInfo(doc)
+	if _, err := stmt.ExecContext(ctx, parentID, chunkCount); err != nil {
+		return fmt.Errorf("failed to remove stale chunks: %w", err)
+	}
+	return nil
+}
+
+func (p *PGVectorDB) Search(ctx context.Context, queryVector embeddings.Vector, opts SearchOptions) ([]SearchResult, error) {
+	var where filter
+	vec := where.arg(pgvector.NewVector(queryVector))
+	if err := where.add(opts.Sources, opts.Since, opts.Until, opts.Metadata); err != nil {
+		return nil, err
+	}
+
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = DefaultSearchLimit
+	}
+
+	var query string
+	if opts.Distinct {
+		// Exact rather than index-assisted search, since every chunk must be
+		// ranked to find each document's best one
+		query = fmt.Sprintf(`
+			SELECT id, content, metadata, source, timestamp,
+				title, url, parent, chunk_index, chunk_count, similarity
+			FROM (
+				SELECT DISTINCT ON (COALESCE(parent_id, id)) id, content, metadata, source, timestamp,
+					title, url, COALESCE(parent_id, id) AS parent, chunk_index, chunk_count,
+					1 - (embedding <=> %[1]s) AS similarity
+				FROM documents
+				%[2]s
+				ORDER BY COALESCE(parent_id, id), embedding <=> %[1]s
+			) best
+			ORDER BY similarity DESC, parent
+		`, vec, where.clause())
+	} else {
+		query = fmt.Sprintf(`
+			SELECT id, content, metadata, source, timestamp,
+				title, url, COALESCE(parent_id, id), chunk_index, chunk_count,
+				1 - (embedding <=> %[1]s) as similarity
+			FROM documents
+			%[2]s
+			ORDER BY embedding <=> %[1]s
+		`, vec, where.clause())
+	}
+	query += " LIMIT " + where.arg(limit)
+	if opts.Offset > 0 {
+		query += " OFFSET " + where.arg(opts.Offset)
+	}
+
+	rows, err := p.db.QueryContext(ctx, query, where.args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to execute search query: %w", err)
 	}
@@ -130,7 +344,8 @@ func (p *PGVectorDB) Search(ctx context.Context, queryVector embeddings.Vector,
 		var metadataJSON []byte
 		var similarity float64
 
-		err := rows.Scan(&doc.ID, &doc.Content, &metadataJSON, &doc.Source, &doc.Timestamp, &similarity)
+		err := rows.Scan(&doc.ID, &doc.Content, &metadataJSON, &doc.Source, &doc.Timestamp,
+			&doc.Title, &doc.URL, &doc.ParentID, &doc.ChunkIndex, &doc.ChunkCount, &similarity)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan row: %w", err)
 		}
@@ -146,7 +361,184 @@ func (p *PGVectorDB) Search(ctx context.Context, queryVector embeddings.Vector,
 		})
 	}
 
-	return results, nil
+	return results, rows.Err()
+}
+
+func (p *PGVectorDB) List(ctx context.Context, opts ListOptions) ([]datasources.Document, error) {
+	where := filter{conditions: []string{"chunk_index = 0"}}
+	var sources []string
+	if opts.Source != "" {
+		sources = []string{opts.Source}
+	}
+	if err := where.add(sources, opts.Since, opts.Until, opts.Metadata); err != nil {
+		return nil, err
+	}
+
+	order := "ASC"
+	if opts.Newest {
+		order = "DESC"
+	}
+	query := fmt.Sprintf(`
+		SELECT id, content, metadata, source, timestamp,
+			title, url, COALESCE(parent_id, id), chunk_index, chunk_count
+		FROM documents
+		%s
+		ORDER BY timestamp %s, id
+	`, where.clause(), order)
+	if opts.Limit > 0 {
+		query += " LIMIT " + where.arg(opts.Limit)
+	}
+	if opts.Offset > 0 {
+		query += " OFFSET " + where.arg(opts.Offset)
+	}
+
+	rows, err := p.db.QueryContext(ctx, query, where.args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list documents: %w", err)
+	}
+	defer rows.Close()
+
+	var docs []datasources.Document
+	for rows.Next() {
+		var doc datasources.Document
+		var metadataJSON []byte
+
+		err := rows.Scan(&doc.ID, &doc.Content, &metadataJSON, &doc.Source, &doc.Timestamp,
+			&doc.Title, &doc.URL, &doc.ParentID, &doc.ChunkIndex, &doc.ChunkCount)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+
+		if err := json.Unmarshal(metadataJSON, &doc.Metadata); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal metadata: %w", err)
+		}
+		docs = append(docs, doc)
+	}
+	return docs, rows.Err()
+}
+
+// filter builds a WHERE clause and its positional arguments
+type filter struct {
+	conditions []string
+	args       []interface{}
+}
+
+// arg adds a query argument and returns its placeholder
+func (f *filter) arg(v interface{}) string {
+	f.args = append(f.args, v)
+	return fmt.Sprintf("$%d", len(f.args))
+}
+
+// add appends the conditions shared by List and Search
+func (f *filter) add(sources []string, since, until time.Time, metadata map[string]interface{}) error {
+	if len(sources) == 1 {
+		f.conditions = append(f.conditions, "source = "+f.arg(sources[0]))
+	} else if len(sources) > 1 {
+		f.conditions = append(f.conditions, "source = ANY("+f.arg(pq.Array(sources))+")")
+	}
+	if !since.IsZero() {
+		f.conditions = append(f.conditions, "timestamp >= "+f.arg(since))
+	}
+	if !until.IsZero() {
+		f.conditions = append(f.conditions, "timestamp < "+f.arg(until))
+	}
+	if len(metadata) > 0 {
+		encoded, err := json.Marshal(metadata)
+		if err != nil {
+			return fmt.Errorf("failed to marshal metadata filter: %w", err)
+		}
+		f.conditions = append(f.conditions, "metadata @> "+f.arg(string(encoded))+"::jsonb")
+	}
+	return nil
+}
+
+// clause returns the WHERE clause, or nothing when there are no conditions
+func (f *filter) clause() string {
+	if len(f.conditions) == 0 {
+		return ""
+	}
+	return "WHERE " + strings.Join(f.conditions, " AND ")
+}
+
+func (p *PGVectorDB) Chunks(ctx context.Context, id string) ([]datasources.Document, error) {
+	rows, err := p.db.QueryContext(ctx, `
+		WITH target AS (
+			SELECT COALESCE(parent_id, id) AS id FROM documents WHERE id = $1
+		)
+		SELECT d.id, d.content, d.metadata, d.source, d.timestamp,
+			d.title, d.url, COALESCE(d.parent_id, d.id), d.chunk_index, d.chunk_count
+		FROM documents d, target
+		WHERE d.id = target.id OR d.parent_id = target.id
+		ORDER BY d.chunk_index
+	`, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query chunks: %w", err)
+	}
+	defer rows.Close()
+
+	var chunks []datasources.Document
+	for rows.Next() {
+		var doc datasources.Document
+		var metadataJSON []byte
+
+		err := rows.Scan(&doc.ID, &doc.Content, &metadataJSON, &doc.Source, &doc.Timestamp,
+			&doc.Title, &doc.URL, &doc.ParentID, &doc.ChunkIndex, &doc.ChunkCount)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+
+		if err := json.Unmarshal(metadataJSON, &doc.Metadata); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal metadata: %w", err)
+		}
+		chunks = append(chunks, doc)
+	}
+	return chunks, rows.Err()
+}
+
+func (p *PGVectorDB) Stats(ctx context.Context) (Stats, error) {
+	var stats Stats
+
+	rows, err := p.db.QueryContext(ctx, `
+		SELECT source,
+			COUNT(*) FILTER (WHERE chunk_index = 0),
+			COUNT(*),
+			COALESCE(SUM(pg_column_size(documents.*)), 0),
+			MIN(timestamp),
+			MAX(timestamp)
+		FROM documents
+		GROUP BY source
+		ORDER BY source
+	`)
+	if err != nil {
+		return stats, fmt.Errorf("failed to query source stats: %w", err)
+	}
+	defer rows.Close()
+
+	stats.Sources = []SourceStats{}
+	for rows.Next() {
+		var s SourceStats
+		if err := rows.Scan(&s.Source, &s.Documents, &s.Chunks, &s.Bytes, &s.Oldest, &s.Newest); err != nil {
+			return stats, fmt.Errorf("failed to scan row: %w", err)
+		}
+		stats.Sources = append(stats.Sources, s)
+	}
+	if err := rows.Err(); err != nil {
+		return stats, err
+	}
+
+	err = p.db.QueryRowContext(ctx, "SELECT pg_total_relation_size('documents')").Scan(&stats.TotalBytes)
+	if err != nil {
+		return stats, fmt.Errorf("failed to query table size: %w", err)
+	}
+	return stats, nil
+}
+
+func (p *PGVectorDB) Delete(ctx context.Context, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	_, err := p.db.ExecContext(ctx, "DELETE FROM documents WHERE id = ANY($1) OR parent_id = ANY($1)", pq.Array(ids))
+	return err
 }
 
 func (p *PGVectorDB) DeleteBySource(ctx context.Context, source string) error {
@@ -154,6 +546,27 @@ func (p *PGVectorDB) DeleteBySource(ctx context.Context, source string) error {
 	return err
 }
 
+func (p *PGVectorDB) DeleteMissing(ctx context.Context, source string, keep []string) (int, error) {
+	if keep == nil {
+		// A NULL array would match nothing and remove nothing
+		keep = []string{}
+	}
+
+	var removed int
+	err := p.db.QueryRowContext(ctx, `
+		WITH removed AS (
+			DELETE FROM documents
+			WHERE source = $1 AND NOT (COALESCE(parent_id, id) = ANY($2))
+			RETURNING COALESCE(parent_id, id) AS parent_id
+		)
+		SELECT COUNT(DISTINCT parent_id) FROM removed`,
+		source, pq.Array(keep)).Scan(&removed)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete missing documents: %w", err)
+	}
+	return removed, nil
+}
+
 func (p *PGVectorDB) DeleteAll(ctx context.Context) error {
 	_, err := p.db.ExecContext(ctx, "DELETE FROM documents")
 	return err
@@ -2,6 +2,7 @@ package database
 
 import (
 	"context"
+	"time"
 
 	"github.com/michaelgalloway/sophia/internal/datasources"
 	"github.com/michaelgalloway/sophia/internal/embeddings"
@@ -18,12 +19,36 @@ type VectorDB interface {
 	// Store saves documents and their embeddings
 	Store(ctx context.Context, docs []datasources.Document, vectors []embeddings.Vector) error
 
-	// Search finds similar documents based on a query vector
-	Search(ctx context.Context, queryVector embeddings.Vector, limit int) ([]SearchResult, error)
+	// ContentHashes returns the stored content hash for each of the given IDs that exists
+	ContentHashes(ctx context.Context, ids []string) (map[string]string, error)
+
+	// UpdateMetadata refreshes everything but the content and embedding of stored documents
+	UpdateMetadata(ctx context.Context, docs []datasources.Document) error
+
+	// Search finds the chunks most similar to a query vector, best first
+	Search(ctx context.Context, queryVector embeddings.Vector, opts SearchOptions) ([]SearchResult, error)
+
+	// List returns stored documents matching the options, one per document: only
+	// the first chunk of a chunked document is returned
+	List(ctx context.Context, opts ListOptions) ([]datasources.Document, error)
+
+	// Chunks returns every stored chunk of the document with the given ID, or of
+	// the document a chunk ID belongs to, in order. It is empty for unknown IDs.
+	Chunks(ctx context.Context, id string) ([]datasources.Document, error)
+
+	// Stats reports how many documents and chunks each source has stored and how much space they take
+	Stats(ctx context.Context) (Stats, error)
+
+	// Delete removes documents, and all of their chunks, by ID
+	Delete(ctx context.Context, ids []string) error
 
 	// DeleteBySource removes all documents from a specific source
 	DeleteBySource(ctx context.Context, source string) error
 
+	// DeleteMissing removes the documents of a source, and all of their chunks,
+	// whose IDs are not in keep, and returns how many documents were removed
+	DeleteMissing(ctx context.Context, source string, keep []string) (int, error)
+
 	// DeleteAll removes all documents
 	DeleteAll(ctx context.Context) error
 
@@ -31,6 +56,59 @@ type VectorDB interface {
 	Initialize(ctx context.Context) error
 }
 
+// ListOptions filters and orders the documents returned by List. Zero values
+// leave a filter unset.
+type ListOptions struct {
+	Source string
+	// Since and Until bound the document timestamp to [Since, Until)
+	Since time.Time
+	Until time.Time
+	// Metadata matches documents whose metadata contains these values, following
+	// JSONB containment: an array value matches arrays holding all of its elements
+	Metadata map[string]interface{}
+	// Newest orders by descending timestamp instead of ascending
+	Newest bool
+	Limit  int
+	Offset int
+}
+
+// SearchOptions filters and pages the results of Search. Zero values leave a
+// filter unset; Limit defaults to DefaultSearchLimit.
+type SearchOptions struct {
+	Limit  int
+	Offset int
+	// Sources limits results to these data sources
+	Sources []string
+	// Since and Until bound the document timestamp to [Since, Until)
+	Since time.Time
+	Until time.Time
+	// Metadata follows the same JSONB containment rules as in ListOptions
+	Metadata map[string]interface{}
+	// Distinct returns each document once, represented by its best-matching chunk
+	Distinct bool
+}
+
+// SourceStats describes what one source has stored
+type SourceStats struct {
+	Source    string `json:"source"`
+	Documents int    `json:"documents"`
+	Chunks    int    `json:"chunks"`
+	// Bytes is the stored size of the source's rows, including embeddings
+	Bytes  int64     `json:"bytes"`
+	Oldest time.Time `json:"oldest"`
+	Newest time.Time `json:"newest"`
+}
+
+// Stats describes the contents of the index
+type Stats struct {
+	Sources []SourceStats `json:"sources"`
+	// TotalBytes is the size of the documents table on disk, including its indexes
+	TotalBytes int64 `json:"total_bytes"`
+}
+
+// DefaultSearchLimit is the number of results Search returns when no limit is set
+const DefaultSearchLimit = 10
+
 // Config holds configuration for the vector database
 type Config struct {
 	Host     string
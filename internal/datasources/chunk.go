@@ -0,0 +1,88 @@
+package datasources
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// DefaultChunkOverlap is the number of characters repeated between consecutive chunks
+const DefaultChunkOverlap = 200
+
+// Chunk splits a document into pieces of at most size characters so each can be embedded
+// on its own. The first chunk keeps the document's ID; later chunks are suffixed with "#n".
+// Every chunk after the first repeats the document title so it stands alone in search results.
+func Chunk(doc Document, size, overlap int) []Document {
+	if size <= 0 {
+		size = MaxContentLength
+	}
+	if overlap < 0 || overlap >= size/2 {
+		overlap = 0
+	}
+
+	pieces := splitText(doc.Content, size, overlap)
+	chunks := make([]Document, len(pieces))
+	for i, piece := range pieces {
+		chunk := doc
+		chunk.ParentID = doc.ID
+		chunk.ChunkIndex = i
+		chunk.ChunkCount = len(pieces)
+		chunk.Content = piece
+
+		if i > 0 {
+			chunk.ID = fmt.Sprintf("%s#%d", doc.ID, i)
+			if doc.Title != "" {
+				chunk.Content = fmt.Sprintf("%s (part %d of %d)\n\n%s", doc.Title, i+1, len(pieces), piece)
+			}
+		}
+		chunks[i] = chunk
+	}
+	return chunks
+}
+
+// splitText breaks text into pieces no longer than size, preferring paragraph,
+// line and word boundaries, with overlap characters carried into the next piece
+func splitText(text string, size, overlap int) []string {
+	if len(text) <= size {
+		return []string{text}
+	}
+
+	var pieces []string
+	for len(text) > size {
+		cut := breakPoint(text, size)
+		pieces = append(pieces, strings.TrimSpace(text[:cut]))
+
+		next := cut - overlap
+		if next <= 0 {
+			next = cut
+		}
+		for next < cut && !utf8.RuneStart(text[next]) {
+			next++
+		}
+		text = text[next:]
+	}
+	if rest := strings.TrimSpace(text); rest != "" {
+		pieces = append(pieces, rest)
+	}
+	return pieces
+}
+
+// breakPoint finds where to end a piece of at most size bytes
+func breakPoint(text string, size int) int {
+	window := text[:size]
+	for _, sep := range []string{"\n\n", "\n", ". ", " "} {
+		if i := strings.LastIndex(window, sep); i > size/2 {
+			return i + len(sep)
+		}
+	}
+
+	// No natural boundary: cut on a rune boundary
+	cut := size
+	for cut > 0 && !utf8.RuneStart(text[cut]) {
+		cut--
+	}
+	if cut == 0 {
+		cut = size
+	}
+	return cut
+}
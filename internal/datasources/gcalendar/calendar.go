@@ -2,22 +2,54 @@ package gcalendar
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log"
+	"net/http"
 	"time"
 
 	"golang.org/x/oauth2/google"
 	"google.golang.org/api/calendar/v3"
+	"google.golang.org/api/googleapi"
 	"google.golang.org/api/option"
 
 	"github.com/michaelgalloway/sophia/internal/auth"
 	"github.com/michaelgalloway/sophia/internal/datasources"
 )
 
+const (
+	defaultWindowPast   = 30 * 24 * time.Hour
+	defaultWindowFuture = 90 * 24 * time.Hour
+
+	// windowRefresh is how often the rolling window is re-listed in full so that
+	// events entering it are picked up; sync tokens only report changes
+	windowRefresh = 24 * time.Hour
+)
+
 type GoogleCalendarSource struct {
-	service     *calendar.Service
-	creds       []byte
-	tokenDir    string
-	tokenMgr    *auth.TokenManager
+	service  *calendar.Service
+	creds    []byte
+	tokenDir string
+	tokenMgr *auth.TokenManager
+
+	calendarIDs  []string
+	windowPast   time.Duration
+	windowFuture time.Duration
+
+	// allowWrites requests the events scope so actions can create and change events
+	allowWrites bool
+
+	// syncState tracks the incremental sync position of each calendar
+	syncState map[string]*calendarSync
+}
+
+// calendarSync is the incremental sync position of one calendar
+type calendarSync struct {
+	token    string
+	listedAt time.Time
+	// ids are the documents returned for the calendar and not since deleted, so
+	// a re-list can delete the ones it no longer returns
+	ids map[string]bool
 }
 
 type Config struct {
@@ -36,10 +68,31 @@ func New(config map[string]interface{}) (datasources.DataSource, error) {
 		return nil, fmt.Errorf("token_dir not provided in config")
 	}
 
+	calendarIDs, _ := config["calendar_ids"].([]string)
+	if len(calendarIDs) == 0 {
+		calendarIDs = []string{"primary"}
+	}
+
+	windowPast, _ := config["window_past"].(time.Duration)
+	if windowPast <= 0 {
+		windowPast = defaultWindowPast
+	}
+	windowFuture, _ := config["window_future"].(time.Duration)
+	if windowFuture <= 0 {
+		windowFuture = defaultWindowFuture
+	}
+
+	allowWrites, _ := config["allow_writes"].(bool)
+
 	return &GoogleCalendarSource{
-		creds:    []byte(credentials),
-		tokenDir: tokenDir,
-		tokenMgr: auth.NewTokenManager(tokenDir),
+		creds:        []byte(credentials),
+		tokenDir:     tokenDir,
+		tokenMgr:     auth.NewTokenManager(tokenDir),
+		calendarIDs:  calendarIDs,
+		windowPast:   windowPast,
+		windowFuture: windowFuture,
+		allowWrites:  allowWrites,
+		syncState:    make(map[string]*calendarSync),
 	}, nil
 }
 
@@ -48,14 +101,23 @@ func (g *GoogleCalendarSource) Name() string {
 }
 
 func (g *GoogleCalendarSource) Initialize(ctx context.Context) error {
+	// Writing events needs a broader grant, kept under its own token so enabling
+	// it prompts for consent instead of reusing the read-only token
+	scopes := []string{calendar.CalendarReadonlyScope}
+	tokenName := "calendar"
+	if g.allowWrites {
+		scopes = append(scopes, calendar.CalendarEventsScope)
+		tokenName = "calendar_events"
+	}
+
 	// Create OAuth2 config from credentials
-	config, err := google.ConfigFromJSON(g.creds, calendar.CalendarReadonlyScope)
+	config, err := google.ConfigFromJSON(g.creds, scopes...)
 	if err != nil {
 		return fmt.Errorf("failed to parse client secret file to config: %w", err)
 	}
 
 	// Get OAuth2 token
-	token, err := g.tokenMgr.GetToken(ctx, config, "calendar")
+	token, err := g.tokenMgr.GetToken(ctx, config, tokenName)
 	if err != nil {
 		return fmt.Errorf("failed to get token: %w", err)
 	}
@@ -73,63 +135,167 @@ func (g *GoogleCalendarSource) Initialize(ctx context.Context) error {
 	return nil
 }
 
+// Service returns the authenticated Calendar client. It can change events only
+// when the source was configured with allow_writes.
+func (g *GoogleCalendarSource) Service() *calendar.Service {
+	return g.service
+}
+
+// AllowsWrites reports whether the source was granted the events scope
+func (g *GoogleCalendarSource) AllowsWrites() bool {
+	return g.allowWrites
+}
+
+// FetchData returns events from every configured calendar. Each calendar is listed in
+// full across the rolling window on the first sync, on full syncs and once a day;
+// in between, its sync token returns only changed and cancelled events.
 func (g *GoogleCalendarSource) FetchData(ctx context.Context, since time.Time) ([]datasources.Document, error) {
 	if g.service == nil {
 		return nil, fmt.Errorf("calendar service not initialized")
 	}
 
-	timeMin := time.Now().Format(time.RFC3339)
-	timeMax := time.Date(2025, time.January, 10, 23, 0, 0, 0, time.UTC).Format(time.RFC3339)
+	calendars, err := g.calendars(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var docs []datasources.Document
+	for _, cal := range calendars {
+		state := g.syncState[cal.Id]
+		full := since.IsZero() || state == nil || time.Since(state.listedAt) > windowRefresh
+
+		var calDocs []datasources.Document
+		if !full {
+			calDocs, err = g.syncChanges(ctx, cal, state)
+			if isGone(err) {
+				log.Printf("Calendar %s sync token expired, listing in full", cal.Summary)
+				full = true
+			}
+		}
+		if full {
+			calDocs, err = g.listWindow(ctx, cal, state)
+		}
+		if err != nil {
+			return nil, err
+		}
 
-	events, err := g.service.Events.List("primary").
-		TimeMin(timeMin).
-		TimeMax(timeMax).
-		OrderBy("startTime").
+		docs = append(docs, calDocs...)
+	}
+
+	return docs, nil
+}
+
+// calendars resolves the configured calendar IDs. "all" selects every calendar in
+// the user's list, including shared ones.
+func (g *GoogleCalendarSource) calendars(ctx context.Context) ([]*calendar.CalendarListEntry, error) {
+	var entries []*calendar.CalendarListEntry
+	err := g.service.CalendarList.List().Pages(ctx, func(list *calendar.CalendarList) error {
+		entries = append(entries, list.Items...)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list calendars: %w", err)
+	}
+
+	var selected []*calendar.CalendarListEntry
+	for _, id := range g.calendarIDs {
+		if id == "all" {
+			return entries, nil
+		}
+
+		found := false
+		for _, entry := range entries {
+			if entry.Id == id || (id == "primary" && entry.Primary) {
+				selected = append(selected, entry)
+				found = true
+				break
+			}
+		}
+		if !found {
+			log.Printf("Warning: calendar %q not found", id)
+		}
+	}
+	return selected, nil
+}
+
+// listWindow lists every event in the rolling window and stores the resulting sync
+// token. Events returned for the calendar before but missing now, because they
+// were cancelled while the sync token was invalid or slid out of the window, are
+// returned as deleted.
+func (g *GoogleCalendarSource) listWindow(ctx context.Context, cal *calendar.CalendarListEntry, previous *calendarSync) ([]datasources.Document, error) {
+	now := time.Now()
+	recurrence := g.newRecurrenceLookup(cal.Id)
+	var docs []datasources.Document
+	var token string
+
+	err := g.service.Events.List(cal.Id).
+		TimeMin(now.Add(-g.windowPast).Format(time.RFC3339)).
+		TimeMax(now.Add(g.windowFuture).Format(time.RFC3339)).
 		SingleEvents(true).
-		Do()
+		Pages(ctx, func(events *calendar.Events) error {
+			for _, event := range events.Items {
+				docs = append(docs, g.eventDocument(cal, event, recurrence.rules(ctx, event)))
+			}
+			token = events.NextSyncToken
+			return nil
+		})
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch calendar events: %w", err)
 	}
 
-	var docs []datasources.Document
-	for _, event := range events.Items {
-		content := fmt.Sprintf("Event: %s\nDescription: %s\nStart: %s\nEnd: %s\nAttendees: %s",
-			event.Summary,
-			event.Description,
-			event.Start.DateTime,
-			event.End.DateTime,
-			formatAttendees(event.Attendees),
-		)
-
-		doc := datasources.Document{
-			ID:      event.Id,
-			Content: content,
-			Metadata: map[string]interface{}{
-				"summary":    event.Summary,
-				"start_time": event.Start.DateTime,
-				"end_time":   event.End.DateTime,
-				"location":   event.Location,
-			},
-			Source:    g.Name(),
-			Timestamp: time.Now(),
+	state := &calendarSync{token: token, listedAt: now, ids: make(map[string]bool)}
+	state.track(docs)
+	if previous != nil {
+		for id := range previous.ids {
+			if !state.ids[id] {
+				docs = append(docs, datasources.Document{ID: id, Source: g.Name(), Deleted: true})
+			}
 		}
-		docs = append(docs, doc)
 	}
 
+	g.syncState[cal.Id] = state
 	return docs, nil
 }
 
-func formatAttendees(attendees []*calendar.EventAttendee) string {
-	if len(attendees) == 0 {
-		return "No attendees"
+// syncChanges returns events changed since the calendar's last sync token
+func (g *GoogleCalendarSource) syncChanges(ctx context.Context, cal *calendar.CalendarListEntry, state *calendarSync) ([]datasources.Document, error) {
+	recurrence := g.newRecurrenceLookup(cal.Id)
+	var docs []datasources.Document
+	token := state.token
+
+	err := g.service.Events.List(cal.Id).
+		SyncToken(state.token).
+		SingleEvents(true).
+		Pages(ctx, func(events *calendar.Events) error {
+			for _, event := range events.Items {
+				docs = append(docs, g.eventDocument(cal, event, recurrence.rules(ctx, event)))
+			}
+			if events.NextSyncToken != "" {
+				token = events.NextSyncToken
+			}
+			return nil
+		})
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch calendar changes: %w", err)
 	}
 
-	result := ""
-	for i, attendee := range attendees {
-		if i > 0 {
-			result += ", "
+	state.token = token
+	state.track(docs)
+	return docs, nil
+}
+
+// track records which documents are stored for the calendar after docs are processed
+func (s *calendarSync) track(docs []datasources.Document) {
+	for _, doc := range docs {
+		if doc.Deleted {
+			delete(s.ids, doc.ID)
+		} else {
+			s.ids[doc.ID] = true
 		}
-		result += attendee.Email
 	}
-	return result
+}
+
+func isGone(err error) bool {
+	var apiErr *googleapi.Error
+	return errors.As(err, &apiErr) && apiErr.Code == http.StatusGone
 }
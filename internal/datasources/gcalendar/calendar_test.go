@@ -0,0 +1,151 @@
+package gcalendar
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/api/calendar/v3"
+	"google.golang.org/api/option"
+)
+
+// fakeCalendar serves one calendar. Listing the window returns events; a sync
+// token request returns changes, or 410 Gone once expired is set.
+type fakeCalendar struct {
+	mu      sync.Mutex
+	events  []*calendar.Event
+	changes []*calendar.Event
+	expired bool
+}
+
+func newTestSource(t *testing.T) (*GoogleCalendarSource, *fakeCalendar) {
+	api := &fakeCalendar{}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/calendar/v3/users/me/calendarList", func(w http.ResponseWriter, r *http.Request) {
+		writeCalendarJSON(w, &calendar.CalendarList{Items: []*calendar.CalendarListEntry{
+			{Id: "ana@example.com", Summary: "Ana", Primary: true},
+		}})
+	})
+	mux.HandleFunc("/calendar/v3/calendars/ana@example.com/events", func(w http.ResponseWriter, r *http.Request) {
+		api.mu.Lock()
+		defer api.mu.Unlock()
+
+		if r.URL.Query().Get("syncToken") == "" {
+			writeCalendarJSON(w, &calendar.Events{Items: api.events, NextSyncToken: "token-1"})
+			return
+		}
+		if api.expired {
+			http.Error(w, `{"error": {"code": 410, "message": "Sync token is no longer valid"}}`, http.StatusGone)
+			return
+		}
+		writeCalendarJSON(w, &calendar.Events{Items: api.changes, NextSyncToken: "token-2"})
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	service, err := calendar.NewService(context.Background(),
+		option.WithHTTPClient(server.Client()), option.WithEndpoint(server.URL+"/calendar/v3/"))
+	if err != nil {
+		t.Fatalf("failed to create calendar service: %v", err)
+	}
+
+	source, err := New(map[string]interface{}{"credentials": "{}", "token_dir": t.TempDir()})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	g := source.(*GoogleCalendarSource)
+	g.service = service
+	return g, api
+}
+
+func testEvent(id string) *calendar.Event {
+	start := time.Now().Add(24 * time.Hour)
+	return &calendar.Event{
+		Id:      id,
+		Status:  "confirmed",
+		Summary: "Planning " + id,
+		Start:   &calendar.EventDateTime{DateTime: start.Format(time.RFC3339)},
+		End:     &calendar.EventDateTime{DateTime: start.Add(time.Hour).Format(time.RFC3339)},
+	}
+}
+
+func writeCalendarJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// fetchStates maps each returned document ID to whether it is a deletion
+func fetchStates(t *testing.T, g *GoogleCalendarSource, since time.Time) map[string]bool {
+	t.Helper()
+	docs, err := g.FetchData(context.Background(), since)
+	if err != nil {
+		t.Fatalf("FetchData() error = %v", err)
+	}
+	states := make(map[string]bool, len(docs))
+	for _, doc := range docs {
+		states[doc.ID] = doc.Deleted
+	}
+	return states
+}
+
+func TestExpiredTokenDeletesMissingEvents(t *testing.T) {
+	g, api := newTestSource(t)
+	api.events = []*calendar.Event{testEvent("a"), testEvent("b"), testEvent("c")}
+
+	if got := fetchStates(t, g, time.Time{}); len(got) != 3 {
+		t.Fatalf("first sync = %v, want three events", got)
+	}
+
+	// d is added by a change; later c is cancelled while the token is invalid
+	api.changes = []*calendar.Event{testEvent("d")}
+	if got := fetchStates(t, g, time.Now()); len(got) != 1 || got["ana@example.com/d"] {
+		t.Fatalf("incremental sync = %v, want d", got)
+	}
+
+	api.mu.Lock()
+	api.expired = true
+	api.events = []*calendar.Event{testEvent("a"), testEvent("b"), testEvent("d")}
+	api.mu.Unlock()
+
+	got := fetchStates(t, g, time.Now())
+	want := map[string]bool{
+		"ana@example.com/a": false,
+		"ana@example.com/b": false,
+		"ana@example.com/d": false,
+		"ana@example.com/c": true,
+	}
+	if len(got) != len(want) {
+		t.Fatalf("sync after 410 = %v, want %v", got, want)
+	}
+	for id, deleted := range want {
+		if got[id] != deleted {
+			t.Errorf("%s deleted = %v, want %v", id, got[id], deleted)
+		}
+	}
+}
+
+func TestWindowRefreshDeletesEventsOutsideWindow(t *testing.T) {
+	g, api := newTestSource(t)
+	api.events = []*calendar.Event{testEvent("a"), testEvent("b")}
+	fetchStates(t, g, time.Time{})
+
+	// A day later, a has slid out of the window
+	g.syncState["ana@example.com"].listedAt = time.Now().Add(-windowRefresh - time.Minute)
+	api.events = []*calendar.Event{testEvent("b")}
+
+	got := fetchStates(t, g, time.Now())
+	if len(got) != 2 || !got["ana@example.com/a"] || got["ana@example.com/b"] {
+		t.Errorf("refresh = %v, want a deleted and b kept", got)
+	}
+
+	// Deleted once, a is not reported again
+	g.syncState["ana@example.com"].listedAt = time.Now().Add(-windowRefresh - time.Minute)
+	if got := fetchStates(t, g, time.Now()); len(got) != 1 {
+		t.Errorf("second refresh = %v, want only b", got)
+	}
+}
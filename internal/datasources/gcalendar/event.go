@@ -0,0 +1,266 @@
+package gcalendar
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"google.golang.org/api/calendar/v3"
+
+	"github.com/michaelgalloway/sophia/internal/datasources"
+)
+
+// responseLabels renders attendee response statuses for the document content
+var responseLabels = map[string]string{
+	"accepted":    "accepted",
+	"declined":    "declined",
+	"tentative":   "tentative",
+	"needsAction": "not responded",
+}
+
+// eventDocument converts an event into a document, or a deletion if it was cancelled.
+// Organizer, attendee responses, conferencing, recurrence and attachments are kept
+// as structured metadata so they can be filtered on as well as searched.
+func (g *GoogleCalendarSource) eventDocument(cal *calendar.CalendarListEntry, event *calendar.Event, recurrence []string) datasources.Document {
+	id := fmt.Sprintf("%s/%s", cal.Id, event.Id)
+	if event.Status == "cancelled" {
+		return datasources.Document{ID: id, Source: g.Name(), Deleted: true}
+	}
+
+	start, allDay := eventTime(event.Start)
+	end, _ := eventTime(event.End)
+	updated, _ := time.Parse(time.RFC3339, event.Updated)
+
+	organizer := map[string]interface{}{}
+	if event.Organizer != nil {
+		organizer["email"] = event.Organizer.Email
+		organizer["name"] = event.Organizer.DisplayName
+		organizer["self"] = event.Organizer.Self
+	}
+
+	myResponse := ""
+	attendees := make([]map[string]interface{}, 0, len(event.Attendees))
+	for _, a := range event.Attendees {
+		if a.Resource {
+			continue
+		}
+		if a.Self {
+			myResponse = a.ResponseStatus
+		}
+		attendees = append(attendees, map[string]interface{}{
+			"email":    a.Email,
+			"name":     a.DisplayName,
+			"response": a.ResponseStatus,
+			"optional": a.Optional,
+			"self":     a.Self,
+		})
+	}
+	// Events you own without a guest list count as accepted
+	if myResponse == "" && event.Organizer != nil && event.Organizer.Self {
+		myResponse = "accepted"
+	}
+
+	conferenceLinks := conferenceLinks(event)
+
+	attachments := make([]map[string]interface{}, 0, len(event.Attachments))
+	for _, a := range event.Attachments {
+		attachments = append(attachments, map[string]interface{}{
+			"title":     a.Title,
+			"url":       a.FileUrl,
+			"mime_type": a.MimeType,
+		})
+	}
+
+	var content strings.Builder
+	fmt.Fprintf(&content, "Event: %s\n", event.Summary)
+	fmt.Fprintf(&content, "Calendar: %s\n", cal.Summary)
+	fmt.Fprintf(&content, "When: %s\n", formatWhen(start, end, allDay))
+	if len(recurrence) > 0 {
+		fmt.Fprintf(&content, "Repeats: %s\n", strings.Join(recurrence, "; "))
+	}
+	if event.Location != "" {
+		fmt.Fprintf(&content, "Location: %s\n", event.Location)
+	}
+	if len(conferenceLinks) > 0 {
+		fmt.Fprintf(&content, "Join: %s\n", strings.Join(conferenceLinks, ", "))
+	}
+	if event.Organizer != nil {
+		fmt.Fprintf(&content, "Organizer: %s\n", formatPerson(event.Organizer.DisplayName, event.Organizer.Email))
+	}
+	if myResponse != "" {
+		fmt.Fprintf(&content, "Your response: %s\n", responseLabel(myResponse))
+	}
+	fmt.Fprintf(&content, "Attendees: %s\n", formatAttendees(event.Attendees))
+	if len(event.Attachments) > 0 {
+		var titles []string
+		for _, a := range event.Attachments {
+			titles = append(titles, a.Title)
+		}
+		fmt.Fprintf(&content, "Attachments: %s\n", strings.Join(titles, ", "))
+	}
+	if event.Description != "" {
+		fmt.Fprintf(&content, "Description: %s\n", event.Description)
+	}
+
+	return datasources.Document{
+		ID:      id,
+		Content: strings.TrimSpace(content.String()),
+		Title:   event.Summary,
+		URL:     event.HtmlLink,
+		Metadata: map[string]interface{}{
+			"summary":            event.Summary,
+			"start_time":         start.Format(time.RFC3339),
+			"end_time":           end.Format(time.RFC3339),
+			"all_day":            allDay,
+			"location":           event.Location,
+			"calendar_id":        cal.Id,
+			"calendar":           cal.Summary,
+			"status":             event.Status,
+			"organizer":          organizer,
+			"attendees":          attendees,
+			"my_response":        myResponse,
+			"conference_links":   conferenceLinks,
+			"recurrence":         recurrence,
+			"recurring_event_id": event.RecurringEventId,
+			"attachments":        attachments,
+			"updated":            updated.Format(time.RFC3339),
+		},
+		Source:    g.Name(),
+		Timestamp: start,
+	}
+}
+
+// conferenceLinks collects video and phone links for joining the event
+func conferenceLinks(event *calendar.Event) []string {
+	var links []string
+	seen := make(map[string]bool)
+	add := func(uri string) {
+		if uri != "" && !seen[uri] {
+			seen[uri] = true
+			links = append(links, uri)
+		}
+	}
+
+	if event.ConferenceData != nil {
+		for _, ep := range event.ConferenceData.EntryPoints {
+			if ep.EntryPointType == "video" || ep.EntryPointType == "phone" {
+				add(ep.Uri)
+			}
+		}
+	}
+	add(event.HangoutLink)
+	return links
+}
+
+// recurrenceLookup fetches the recurrence rules of recurring series. With SingleEvents
+// set, instances only reference their series, so each series is fetched once per sync.
+type recurrenceLookup struct {
+	service    *calendar.Service
+	calendarID string
+	rulesByID  map[string][]string
+}
+
+func (g *GoogleCalendarSource) newRecurrenceLookup(calendarID string) *recurrenceLookup {
+	return &recurrenceLookup{
+		service:    g.service,
+		calendarID: calendarID,
+		rulesByID:  make(map[string][]string),
+	}
+}
+
+// rules returns the RRULE/EXDATE lines for an event's series, if it belongs to one
+func (r *recurrenceLookup) rules(ctx context.Context, event *calendar.Event) []string {
+	if len(event.Recurrence) > 0 {
+		return event.Recurrence
+	}
+	if event.RecurringEventId == "" {
+		return nil
+	}
+
+	if rules, ok := r.rulesByID[event.RecurringEventId]; ok {
+		return rules
+	}
+
+	var rules []string
+	series, err := r.service.Events.Get(r.calendarID, event.RecurringEventId).Context(ctx).Do()
+	if err == nil {
+		rules = series.Recurrence
+	}
+	r.rulesByID[event.RecurringEventId] = rules
+	return rules
+}
+
+// eventTime parses an event boundary. All-day events only carry a date, which is
+// interpreted as midnight in the local time zone.
+func eventTime(t *calendar.EventDateTime) (time.Time, bool) {
+	if t == nil {
+		return time.Time{}, false
+	}
+	if t.DateTime != "" {
+		parsed, _ := time.Parse(time.RFC3339, t.DateTime)
+		return parsed, false
+	}
+	parsed, _ := time.ParseInLocation("2006-01-02", t.Date, time.Local)
+	return parsed, true
+}
+
+// formatWhen renders an event's time range for the document content
+func formatWhen(start, end time.Time, allDay bool) string {
+	if allDay {
+		// The end date of an all-day event is exclusive
+		last := end.AddDate(0, 0, -1)
+		if !last.After(start) {
+			return start.Format("Mon Jan 2, 2006") + " (all day)"
+		}
+		return fmt.Sprintf("%s - %s (all day)", start.Format("Mon Jan 2, 2006"), last.Format("Mon Jan 2, 2006"))
+	}
+	if start.Format("2006-01-02") == end.Format("2006-01-02") {
+		return fmt.Sprintf("%s - %s", start.Format("Mon Jan 2, 2006 15:04"), end.Format("15:04 MST"))
+	}
+	return fmt.Sprintf("%s - %s", start.Format("Mon Jan 2, 2006 15:04"), end.Format("Mon Jan 2, 2006 15:04 MST"))
+}
+
+func formatAttendees(attendees []*calendar.EventAttendee) string {
+	if len(attendees) == 0 {
+		return "No attendees"
+	}
+
+	result := ""
+	for _, attendee := range attendees {
+		if attendee.Resource {
+			continue
+		}
+		if result != "" {
+			result += ", "
+		}
+		result += formatPerson(attendee.DisplayName, attendee.Email)
+
+		status := responseLabel(attendee.ResponseStatus)
+		if attendee.Optional {
+			status += ", optional"
+		}
+		if attendee.Organizer {
+			status += ", organizer"
+		}
+		result += " (" + status + ")"
+	}
+	return result
+}
+
+func formatPerson(name, email string) string {
+	if name == "" {
+		return email
+	}
+	return fmt.Sprintf("%s <%s>", name, email)
+}
+
+func responseLabel(status string) string {
+	if label, ok := responseLabels[status]; ok {
+		return label
+	}
+	if status == "" {
+		return "unknown"
+	}
+	return status
+}
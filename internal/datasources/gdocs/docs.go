@@ -2,24 +2,50 @@ package gdocs
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
 	"time"
 
 	"golang.org/x/oauth2/google"
 	"google.golang.org/api/docs/v1"
 	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/googleapi"
 	"google.golang.org/api/option"
+	"google.golang.org/api/sheets/v4"
+	"google.golang.org/api/slides/v1"
 
 	"github.com/michaelgalloway/sophia/internal/auth"
 	"github.com/michaelgalloway/sophia/internal/datasources"
+	"github.com/michaelgalloway/sophia/internal/extract"
 )
 
+// Drive MIME types of the native Google formats this source indexes
+const (
+	mimeDocument     = "application/vnd.google-apps.document"
+	mimeSpreadsheet  = "application/vnd.google-apps.spreadsheet"
+	mimePresentation = "application/vnd.google-apps.presentation"
+)
+
+// defaultMaxFileSize is the largest uploaded file downloaded for text extraction
+const defaultMaxFileSize = 20 << 20
+
 type GoogleDocsSource struct {
-	docsService  *docs.Service
-	driveService *drive.Service
-	creds        []byte
-	tokenDir     string
-	tokenMgr     *auth.TokenManager
+	httpClient    *http.Client
+	driveService  *drive.Service
+	sheetsService *sheets.Service
+	slidesService *slides.Service
+	creds         []byte
+	tokenDir      string
+	tokenMgr      *auth.TokenManager
+	maxFileSize   int64
+	fileTypes     []string
+
+	// pageToken is the Drive change log position reached by the last sync
+	pageToken string
 }
 
 func New(config map[string]interface{}) (datasources.DataSource, error) {
@@ -33,10 +59,29 @@ func New(config map[string]interface{}) (datasources.DataSource, error) {
 		return nil, fmt.Errorf("token_dir not provided in config")
 	}
 
+	maxFileSize := int64(defaultMaxFileSize)
+	if size, ok := config["max_file_size"].(int); ok && size > 0 {
+		maxFileSize = int64(size)
+	}
+
+	// Uploaded files are limited to the types the extractor understands
+	fileTypes := extract.SupportedTypes()
+	if types, ok := config["mime_types"].([]string); ok && len(types) > 0 {
+		fileTypes = nil
+		for _, mimeType := range types {
+			if !extract.Supported(mimeType) {
+				return nil, fmt.Errorf("no text extractor for MIME type %s", mimeType)
+			}
+			fileTypes = append(fileTypes, mimeType)
+		}
+	}
+
 	return &GoogleDocsSource{
-		creds:    []byte(credentials),
-		tokenDir: tokenDir,
-		tokenMgr: auth.NewTokenManager(tokenDir),
+		creds:       []byte(credentials),
+		tokenDir:    tokenDir,
+		tokenMgr:    auth.NewTokenManager(tokenDir),
+		maxFileSize: maxFileSize,
+		fileTypes:   fileTypes,
 	}, nil
 }
 
@@ -45,7 +90,8 @@ func (g *GoogleDocsSource) Name() string {
 }
 
 func (g *GoogleDocsSource) Initialize(ctx context.Context) error {
-	// Create OAuth2 config from credentials
+	// Create OAuth2 config from credentials. Read-only Drive access also
+	// covers reading Docs, Sheets and Slides content.
 	config, err := google.ConfigFromJSON(g.creds,
 		docs.DriveReadonlyScope,
 		drive.DriveReadonlyScope,
@@ -61,87 +107,269 @@ func (g *GoogleDocsSource) Initialize(ctx context.Context) error {
 	}
 
 	// Create HTTP client with token
-	client := config.Client(ctx, token)
+	g.httpClient = config.Client(ctx, token)
 
-	// Create the Docs service
-	g.docsService, err = docs.NewService(ctx, option.WithHTTPClient(client))
+	// Create the Drive service
+	g.driveService, err = drive.NewService(ctx, option.WithHTTPClient(g.httpClient))
 	if err != nil {
-		return fmt.Errorf("failed to create Docs service: %w", err)
+		return fmt.Errorf("failed to create Drive service: %w", err)
 	}
 
-	// Create the Drive service
-	g.driveService, err = drive.NewService(ctx, option.WithHTTPClient(client))
+	// Create the Sheets service
+	g.sheetsService, err = sheets.NewService(ctx, option.WithHTTPClient(g.httpClient))
 	if err != nil {
-		return fmt.Errorf("failed to create Drive service: %w", err)
+		return fmt.Errorf("failed to create Sheets service: %w", err)
+	}
+
+	// Create the Slides service
+	g.slidesService, err = slides.NewService(ctx, option.WithHTTPClient(g.httpClient))
+	if err != nil {
+		return fmt.Errorf("failed to create Slides service: %w", err)
 	}
 
 	return nil
 }
 
+// fileFields are the Drive file fields fileDocument needs
+const fileFields = "id, name, mimeType, size, modifiedTime, webViewLink, trashed, owners(displayName, emailAddress)"
+
+// FetchData returns every Doc, Sheet, Slides deck and supported uploaded file
+// modified after since, or all of them when since is zero. Later syncs read the
+// Drive change log instead, which also reports files that were trashed, deleted
+// or unshared; those are returned as deleted documents.
 func (g *GoogleDocsSource) FetchData(ctx context.Context, since time.Time) ([]datasources.Document, error) {
-	//query := fmt.Sprintf("mimeType='application/vnd.google-apps.document' and modifiedTime > '%s'", since.Format(time.RFC3339))
+	if since.IsZero() || g.pageToken == "" {
+		return g.fullSync(ctx, since)
+	}
+
+	docs, err := g.incrementalSync(ctx)
+	if isInvalidToken(err) {
+		log.Printf("Drive change token is no longer valid, falling back to a full listing")
+		return g.fullSync(ctx, since)
+	}
+	return docs, err
+}
+
+// fullSync lists the supported files modified after since, plus every trashed one
+// so files trashed while Sophia wasn't running are removed
+func (g *GoogleDocsSource) fullSync(ctx context.Context, since time.Time) ([]datasources.Document, error) {
+	// Record the change log position first so changes made while listing are picked up next time
+	start, err := g.driveService.Changes.GetStartPageToken().Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch change token: %w", err)
+	}
+
+	var documents []datasources.Document
+	err = g.driveService.Files.List().
+		Q(g.listQuery(since, false)).
+		Fields("nextPageToken, files("+fileFields+")").
+		PageSize(100).
+		Pages(ctx, func(fileList *drive.FileList) error {
+			for _, file := range fileList.Files {
+				doc, ok, err := g.document(ctx, file)
+				if err != nil {
+					return err
+				}
+				if ok {
+					documents = append(documents, doc)
+				}
+			}
+			return nil
+		})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list documents: %w", err)
+	}
+
+	err = g.driveService.Files.List().
+		Q(g.listQuery(time.Time{}, true)).
+		Fields("nextPageToken, files(id)").
+		PageSize(1000).
+		Pages(ctx, func(fileList *drive.FileList) error {
+			for _, file := range fileList.Files {
+				documents = append(documents, g.deletedDocument(file.Id))
+			}
+			return nil
+		})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list trashed documents: %w", err)
+	}
 
-	sinceTime := time.Date(2024, time.June, 10, 23, 0, 0, 0, time.UTC).Format(time.RFC3339)
-	query := fmt.Sprintf("mimeType='application/vnd.google-apps.document' and modifiedTime > '%s'", sinceTime)
+	g.pageToken = start.StartPageToken
+	return documents, nil
+}
 
-	var docs []datasources.Document
-	pageToken := ""
+// incrementalSync reads the Drive change log from the last recorded position
+func (g *GoogleDocsSource) incrementalSync(ctx context.Context) ([]datasources.Document, error) {
+	var documents []datasources.Document
+	supported := make(map[string]bool)
+	for _, mimeType := range g.types() {
+		supported[mimeType] = true
+	}
 
+	token := g.pageToken
 	for {
-		fileList, err := g.driveService.Files.List().
-			Q(query).
-			Fields("files(id, name, modifiedTime, owners)").
-			PageToken(pageToken).
+		changes, err := g.driveService.Changes.List(token).
+			IncludeRemoved(true).
+			Fields("nextPageToken, newStartPageToken, changes(fileId, removed, file(" + fileFields + "))").
+			PageSize(100).
+			Context(ctx).
 			Do()
 		if err != nil {
-			return nil, fmt.Errorf("failed to list documents: %w", err)
+			return nil, fmt.Errorf("failed to list changes: %w", err)
 		}
 
-		for _, file := range fileList.Files {
-			doc, err := g.docsService.Documents.Get(file.Id).Do()
-			if err != nil {
+		for _, change := range changes.Changes {
+			// Removed means deleted or no longer shared with the user
+			if change.Removed || change.File == nil || change.File.Trashed {
+				documents = append(documents, g.deletedDocument(change.FileId))
 				continue
 			}
-
-			content := extractContent(doc)
-			modTime, _ := time.Parse(time.RFC3339, file.ModifiedTime)
-
-			document := datasources.Document{
-				ID:        file.Id,
-				Content:   datasources.TruncateContent(content),
-				Title:     file.Name,
-				URL:       fmt.Sprintf("https://docs.google.com/document/d/%s", file.Id),
-				Source:    g.Name(),
-				Timestamp: modTime,
-				Metadata: map[string]interface{}{
-					"owners": file.Owners,
-				},
+			if !supported[change.File.MimeType] {
+				continue
 			}
 
-			docs = append(docs, document)
+			doc, ok, err := g.document(ctx, change.File)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				documents = append(documents, doc)
+			}
 		}
 
-		pageToken = fileList.NextPageToken
-		if pageToken == "" {
+		if changes.NewStartPageToken != "" {
+			token = changes.NewStartPageToken
 			break
 		}
+		token = changes.NextPageToken
 	}
 
-	return docs, nil
+	g.pageToken = token
+	return documents, nil
 }
 
-func extractContent(doc *docs.Document) string {
-	var content string
+// document extracts a file, logging and skipping files that can't be read. The
+// error is only set when ctx is done.
+func (g *GoogleDocsSource) document(ctx context.Context, file *drive.File) (datasources.Document, bool, error) {
+	doc, err := g.fileDocument(ctx, file)
+	if err != nil {
+		if ctx.Err() != nil {
+			return datasources.Document{}, false, ctx.Err()
+		}
+		log.Printf("Skipping %s (%s): %v", file.Name, file.Id, err)
+		return datasources.Document{}, false, nil
+	}
+	return doc, true, nil
+}
 
-	for _, elem := range doc.Body.Content {
-		if elem.Paragraph != nil {
-			for _, pe := range elem.Paragraph.Elements {
-				if pe.TextRun != nil {
-					content += pe.TextRun.Content
-				}
-			}
+// deletedDocument marks a file that was trashed, deleted or unshared for removal
+func (g *GoogleDocsSource) deletedDocument(id string) datasources.Document {
+	return datasources.Document{ID: id, Source: g.Name(), Deleted: true}
+}
+
+// types returns the MIME types this source indexes
+func (g *GoogleDocsSource) types() []string {
+	return append([]string{mimeDocument, mimeSpreadsheet, mimePresentation}, g.fileTypes...)
+}
+
+// listQuery builds the Drive search for the supported file types, either those
+// in the trash or those outside it
+func (g *GoogleDocsSource) listQuery(since time.Time, trashed bool) string {
+	types := g.types()
+	clauses := make([]string, len(types))
+	for i, mimeType := range types {
+		clauses[i] = fmt.Sprintf("mimeType='%s'", mimeType)
+	}
+
+	query := fmt.Sprintf("(%s) and trashed = %t", strings.Join(clauses, " or "), trashed)
+	if !since.IsZero() {
+		query += fmt.Sprintf(" and modifiedTime > '%s'", since.UTC().Format(time.RFC3339))
+	}
+	return query
+}
+
+// isInvalidToken reports whether Drive rejected a change log token, such as one
+// that has expired
+func isInvalidToken(err error) bool {
+	var apiErr *googleapi.Error
+	return errors.As(err, &apiErr) &&
+		(apiErr.Code == http.StatusBadRequest || apiErr.Code == http.StatusNotFound)
+}
+
+// fileDocument extracts a Drive file as Markdown, or as plain text for uploaded files
+func (g *GoogleDocsSource) fileDocument(ctx context.Context, file *drive.File) (datasources.Document, error) {
+	var content, kind string
+	var err error
+
+	switch file.MimeType {
+	case mimeDocument:
+		kind = "document"
+		content, err = g.documentContent(ctx, file.Id)
+	case mimeSpreadsheet:
+		kind = "spreadsheet"
+		content, err = g.spreadsheetContent(ctx, file.Id)
+	case mimePresentation:
+		kind = "presentation"
+		content, err = g.presentationContent(ctx, file.Id)
+	default:
+		kind = "file"
+		content, err = g.fileContent(ctx, file)
+	}
+	if err != nil {
+		return datasources.Document{}, err
+	}
+
+	modTime, _ := time.Parse(time.RFC3339, file.ModifiedTime)
+
+	url := file.WebViewLink
+	if url == "" {
+		url = fmt.Sprintf("https://docs.google.com/document/d/%s", file.Id)
+	}
+
+	var owners []string
+	for _, owner := range file.Owners {
+		if owner.EmailAddress != "" {
+			owners = append(owners, owner.EmailAddress)
+		} else {
+			owners = append(owners, owner.DisplayName)
 		}
 	}
 
-	return content
+	return datasources.Document{
+		ID:        file.Id,
+		Content:   content,
+		Title:     file.Name,
+		URL:       url,
+		Source:    g.Name(),
+		Timestamp: modTime,
+		Metadata: map[string]interface{}{
+			"owners":    owners,
+			"type":      kind,
+			"mime_type": file.MimeType,
+			"modified":  file.ModifiedTime,
+		},
+	}, nil
+}
+
+// fileContent downloads an uploaded file and extracts its text
+func (g *GoogleDocsSource) fileContent(ctx context.Context, file *drive.File) (string, error) {
+	if file.Size > g.maxFileSize {
+		return "", fmt.Errorf("file is %d bytes, larger than the %d byte limit", file.Size, g.maxFileSize)
+	}
+
+	resp, err := g.driveService.Files.Get(file.Id).Context(ctx).Download()
+	if err != nil {
+		return "", fmt.Errorf("failed to download file: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, g.maxFileSize+1))
+	if err != nil {
+		return "", fmt.Errorf("failed to download file: %w", err)
+	}
+	if int64(len(data)) > g.maxFileSize {
+		return "", fmt.Errorf("file is larger than the %d byte limit", g.maxFileSize)
+	}
+
+	return extract.Text(file.MimeType, data)
 }
@@ -0,0 +1,307 @@
+package gdocs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"google.golang.org/api/docs/v1"
+
+	"github.com/michaelgalloway/sophia/internal/extract"
+	"github.com/michaelgalloway/sophia/internal/retry"
+)
+
+// documentsEndpoint is the Docs API used to fetch documents with all their tabs.
+// The generated client predates tabs, so documents are requested directly.
+const documentsEndpoint = "https://docs.googleapis.com/v1/documents/"
+
+// tabbedDocument is a Docs API document fetched with includeTabsContent
+type tabbedDocument struct {
+	DocumentID string `json:"documentId"`
+	Title      string `json:"title"`
+	Tabs       []*tab `json:"tabs"`
+}
+
+type tab struct {
+	TabProperties struct {
+		Title        string `json:"title"`
+		NestingLevel int    `json:"nestingLevel"`
+	} `json:"tabProperties"`
+	DocumentTab *documentTab `json:"documentTab"`
+	ChildTabs   []*tab       `json:"childTabs"`
+}
+
+// documentTab holds the content of one tab, in the same shape as an untabbed document
+type documentTab struct {
+	Body      *docs.Body               `json:"body"`
+	Headers   map[string]docs.Header   `json:"headers"`
+	Footers   map[string]docs.Footer   `json:"footers"`
+	Footnotes map[string]docs.Footnote `json:"footnotes"`
+	Lists     map[string]docs.List     `json:"lists"`
+}
+
+// documentContent fetches a Doc and renders every tab as Markdown
+func (g *GoogleDocsSource) documentContent(ctx context.Context, id string) (string, error) {
+	doc, err := g.getDocument(ctx, id)
+	if err != nil {
+		return "", err
+	}
+
+	tabs := flattenTabs(doc.Tabs)
+	var sections []string
+	for _, t := range tabs {
+		if t.DocumentTab == nil {
+			continue
+		}
+		content := renderDocument(t.DocumentTab)
+		if len(tabs) > 1 {
+			content = fmt.Sprintf("%s %s\n\n%s", strings.Repeat("#", t.TabProperties.NestingLevel+1), t.TabProperties.Title, content)
+		}
+		if strings.TrimSpace(content) != "" {
+			sections = append(sections, content)
+		}
+	}
+	return strings.Join(sections, "\n\n"), nil
+}
+
+// getDocument requests a document including the content of all its tabs
+func (g *GoogleDocsSource) getDocument(ctx context.Context, id string) (*tabbedDocument, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet,
+		documentsEndpoint+url.PathEscape(id)+"?includeTabsContent=true", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := g.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get document: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, retry.NewStatusError(resp)
+	}
+
+	var doc tabbedDocument
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("failed to decode document: %w", err)
+	}
+	return &doc, nil
+}
+
+// flattenTabs lists tabs depth first, parents before their children
+func flattenTabs(tabs []*tab) []*tab {
+	var out []*tab
+	for _, t := range tabs {
+		out = append(out, t)
+		out = append(out, flattenTabs(t.ChildTabs)...)
+	}
+	return out
+}
+
+// renderDocument converts a document tab to Markdown. Headers and footers are
+// listed once after the body and footnotes at the end.
+func renderDocument(tab *documentTab) string {
+	w := &docWriter{lists: tab.Lists}
+
+	if tab.Body != nil {
+		w.elements(tab.Body.Content)
+	}
+
+	for _, id := range sortedKeys(tab.Headers) {
+		if text := w.plain(tab.Headers[id].Content); text != "" {
+			w.block("Header: " + text)
+		}
+	}
+	for _, id := range sortedKeys(tab.Footers) {
+		if text := w.plain(tab.Footers[id].Content); text != "" {
+			w.block("Footer: " + text)
+		}
+	}
+
+	for _, ref := range w.footnotes {
+		if note, ok := tab.Footnotes[ref.FootnoteId]; ok {
+			w.block(fmt.Sprintf("[^%s]: %s", ref.FootnoteNumber, w.plain(note.Content)))
+		}
+	}
+
+	return strings.TrimSpace(w.sb.String())
+}
+
+// docWriter accumulates Markdown for a document
+type docWriter struct {
+	sb        strings.Builder
+	lists     map[string]docs.List
+	footnotes []*docs.FootnoteReference
+	inList    bool
+}
+
+// block writes a paragraph-level element separated by a blank line
+func (w *docWriter) block(s string) {
+	if w.sb.Len() > 0 {
+		w.sb.WriteString("\n\n")
+	}
+	w.sb.WriteString(s)
+	w.inList = false
+}
+
+// listItem writes a list item directly below the previous one
+func (w *docWriter) listItem(s string) {
+	if w.inList {
+		w.sb.WriteString("\n")
+	} else if w.sb.Len() > 0 {
+		w.sb.WriteString("\n\n")
+	}
+	w.sb.WriteString(s)
+	w.inList = true
+}
+
+func (w *docWriter) elements(content []*docs.StructuralElement) {
+	for _, elem := range content {
+		switch {
+		case elem.Paragraph != nil:
+			w.paragraph(elem.Paragraph)
+		case elem.Table != nil:
+			if table := w.table(elem.Table); table != "" {
+				w.block(strings.TrimRight(table, "\n"))
+			}
+		}
+	}
+}
+
+func (w *docWriter) paragraph(p *docs.Paragraph) {
+	text := strings.TrimSpace(w.text(p.Elements, true))
+	if text == "" {
+		return
+	}
+
+	if p.Bullet != nil {
+		level := int(p.Bullet.NestingLevel)
+		marker := "-"
+		if w.ordered(p.Bullet.ListId, level) {
+			marker = "1."
+		}
+		w.listItem(strings.Repeat("  ", level) + marker + " " + text)
+		return
+	}
+
+	if p.ParagraphStyle != nil {
+		if level := headingLevel(p.ParagraphStyle.NamedStyleType); level > 0 {
+			w.block(strings.Repeat("#", level) + " " + text)
+			return
+		}
+	}
+	w.block(text)
+}
+
+// text joins the runs of a paragraph. With markup, links are kept as Markdown
+// links and footnote references are collected for the end of the document.
+func (w *docWriter) text(elements []*docs.ParagraphElement, markup bool) string {
+	var sb strings.Builder
+	for _, pe := range elements {
+		switch {
+		case pe.TextRun != nil:
+			content := pe.TextRun.Content
+			style := pe.TextRun.TextStyle
+			if markup && style != nil && style.Link != nil && style.Link.Url != "" && strings.TrimSpace(content) != "" {
+				trimmed := strings.TrimRight(content, "\n")
+				sb.WriteString(fmt.Sprintf("[%s](%s)", trimmed, style.Link.Url))
+				sb.WriteString(content[len(trimmed):])
+			} else {
+				sb.WriteString(content)
+			}
+		case pe.Person != nil && pe.Person.PersonProperties != nil:
+			if name := pe.Person.PersonProperties.Name; name != "" {
+				sb.WriteString(name)
+			} else {
+				sb.WriteString(pe.Person.PersonProperties.Email)
+			}
+		case pe.RichLink != nil && pe.RichLink.RichLinkProperties != nil:
+			props := pe.RichLink.RichLinkProperties
+			if markup {
+				sb.WriteString(fmt.Sprintf("[%s](%s)", props.Title, props.Uri))
+			} else {
+				sb.WriteString(props.Title)
+			}
+		case pe.FootnoteReference != nil && markup:
+			w.footnotes = append(w.footnotes, pe.FootnoteReference)
+			sb.WriteString(fmt.Sprintf("[^%s]", pe.FootnoteReference.FootnoteNumber))
+		}
+	}
+	return sb.String()
+}
+
+// plain flattens structural elements to a single line of text
+func (w *docWriter) plain(content []*docs.StructuralElement) string {
+	var parts []string
+	for _, elem := range content {
+		switch {
+		case elem.Paragraph != nil:
+			if text := strings.TrimSpace(w.text(elem.Paragraph.Elements, false)); text != "" {
+				parts = append(parts, text)
+			}
+		case elem.Table != nil:
+			for _, row := range elem.Table.TableRows {
+				for _, cell := range row.TableCells {
+					if text := w.plain(cell.Content); text != "" {
+						parts = append(parts, text)
+					}
+				}
+			}
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+func (w *docWriter) table(t *docs.Table) string {
+	var rows [][]string
+	for _, row := range t.TableRows {
+		var cells []string
+		for _, cell := range row.TableCells {
+			cells = append(cells, w.plain(cell.Content))
+		}
+		rows = append(rows, cells)
+	}
+	return extract.MarkdownTable(rows)
+}
+
+// ordered reports whether a list level is numbered rather than bulleted
+func (w *docWriter) ordered(listID string, level int) bool {
+	list, ok := w.lists[listID]
+	if !ok || list.ListProperties == nil || level >= len(list.ListProperties.NestingLevels) {
+		return false
+	}
+	switch list.ListProperties.NestingLevels[level].GlyphType {
+	case "", "GLYPH_TYPE_UNSPECIFIED", "NONE":
+		return false
+	}
+	return true
+}
+
+// headingLevel maps a named paragraph style to a Markdown heading level, or 0
+func headingLevel(style string) int {
+	switch style {
+	case "TITLE":
+		return 1
+	case "SUBTITLE":
+		return 2
+	}
+	var level int
+	if _, err := fmt.Sscanf(style, "HEADING_%d", &level); err == nil && level >= 1 && level <= 6 {
+		return level
+	}
+	return 0
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
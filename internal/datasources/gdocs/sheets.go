@@ -0,0 +1,74 @@
+package gdocs
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/michaelgalloway/sophia/internal/extract"
+)
+
+// maxSheetRows caps how many rows of each sheet are indexed
+const maxSheetRows = 1000
+
+// spreadsheetContent renders each visible sheet as a Markdown table under its own heading
+func (g *GoogleDocsSource) spreadsheetContent(ctx context.Context, id string) (string, error) {
+	spreadsheet, err := g.sheetsService.Spreadsheets.Get(id).
+		Fields("sheets.properties(title,hidden,sheetType)").
+		Context(ctx).
+		Do()
+	if err != nil {
+		return "", fmt.Errorf("failed to get spreadsheet: %w", err)
+	}
+
+	var titles, ranges []string
+	for _, sheet := range spreadsheet.Sheets {
+		props := sheet.Properties
+		if props == nil || props.Hidden || (props.SheetType != "" && props.SheetType != "GRID") {
+			continue
+		}
+		titles = append(titles, props.Title)
+		ranges = append(ranges, fmt.Sprintf("'%s'!1:%d", strings.ReplaceAll(props.Title, "'", "''"), maxSheetRows))
+	}
+	if len(ranges) == 0 {
+		return "", nil
+	}
+
+	values, err := g.sheetsService.Spreadsheets.Values.BatchGet(id).
+		Ranges(ranges...).
+		ValueRenderOption("FORMATTED_VALUE").
+		Context(ctx).
+		Do()
+	if err != nil {
+		return "", fmt.Errorf("failed to get spreadsheet values: %w", err)
+	}
+
+	var sections []string
+	for i, valueRange := range values.ValueRanges {
+		if i >= len(titles) {
+			break
+		}
+
+		rows := make([][]string, 0, len(valueRange.Values))
+		for _, row := range valueRange.Values {
+			cells := make([]string, len(row))
+			empty := true
+			for j, value := range row {
+				cells[j] = fmt.Sprint(value)
+				if strings.TrimSpace(cells[j]) != "" {
+					empty = false
+				}
+			}
+			if !empty {
+				rows = append(rows, cells)
+			}
+		}
+		if len(rows) == 0 {
+			continue
+		}
+
+		sections = append(sections, fmt.Sprintf("## %s\n\n%s", titles[i], extract.MarkdownTable(rows)))
+	}
+
+	return strings.TrimSpace(strings.Join(sections, "\n")), nil
+}
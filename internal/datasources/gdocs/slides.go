@@ -0,0 +1,104 @@
+package gdocs
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"google.golang.org/api/slides/v1"
+
+	"github.com/michaelgalloway/sophia/internal/extract"
+)
+
+// presentationContent renders each slide's text, tables and speaker notes
+func (g *GoogleDocsSource) presentationContent(ctx context.Context, id string) (string, error) {
+	presentation, err := g.slidesService.Presentations.Get(id).Context(ctx).Do()
+	if err != nil {
+		return "", fmt.Errorf("failed to get presentation: %w", err)
+	}
+
+	var sections []string
+	for i, slide := range presentation.Slides {
+		if slide.SlideProperties != nil && slide.SlideProperties.IsSkipped {
+			continue
+		}
+
+		var parts []string
+		for _, elem := range slide.PageElements {
+			parts = append(parts, pageElementText(elem)...)
+		}
+
+		if notes := speakerNotes(slide); notes != "" {
+			parts = append(parts, "Speaker notes:\n"+notes)
+		}
+		if len(parts) == 0 {
+			continue
+		}
+
+		sections = append(sections, fmt.Sprintf("## Slide %d\n\n%s", i+1, strings.Join(parts, "\n\n")))
+	}
+
+	return strings.Join(sections, "\n\n"), nil
+}
+
+// pageElementText returns the text blocks of a shape, table or group
+func pageElementText(elem *slides.PageElement) []string {
+	switch {
+	case elem.Shape != nil:
+		if text := textContent(elem.Shape.Text); text != "" {
+			return []string{text}
+		}
+	case elem.Table != nil:
+		var rows [][]string
+		for _, row := range elem.Table.TableRows {
+			var cells []string
+			for _, cell := range row.TableCells {
+				cells = append(cells, textContent(cell.Text))
+			}
+			rows = append(rows, cells)
+		}
+		if table := extract.MarkdownTable(rows); table != "" {
+			return []string{strings.TrimRight(table, "\n")}
+		}
+	case elem.ElementGroup != nil:
+		var parts []string
+		for _, child := range elem.ElementGroup.Children {
+			parts = append(parts, pageElementText(child)...)
+		}
+		return parts
+	}
+	return nil
+}
+
+// speakerNotes returns the text of the slide's speaker notes shape
+func speakerNotes(slide *slides.Page) string {
+	if slide.SlideProperties == nil || slide.SlideProperties.NotesPage == nil {
+		return ""
+	}
+
+	notesPage := slide.SlideProperties.NotesPage
+	if notesPage.NotesProperties == nil {
+		return ""
+	}
+
+	for _, elem := range notesPage.PageElements {
+		if elem.ObjectId == notesPage.NotesProperties.SpeakerNotesObjectId && elem.Shape != nil {
+			return textContent(elem.Shape.Text)
+		}
+	}
+	return ""
+}
+
+func textContent(text *slides.TextContent) string {
+	if text == nil {
+		return ""
+	}
+
+	var sb strings.Builder
+	for _, elem := range text.TextElements {
+		if elem.TextRun != nil {
+			sb.WriteString(elem.TextRun.Content)
+		}
+	}
+	return strings.TrimSpace(sb.String())
+}
@@ -2,22 +2,42 @@ package gmail
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log"
+	"net/http"
+	"strings"
 	"time"
 
 	"golang.org/x/oauth2/google"
 	"google.golang.org/api/gmail/v1"
+	"google.golang.org/api/googleapi"
 	"google.golang.org/api/option"
 
 	"github.com/michaelgalloway/sophia/internal/auth"
 	"github.com/michaelgalloway/sophia/internal/datasources"
 )
 
+// defaultMaxThreads caps how many threads a full sync indexes
+const defaultMaxThreads = 500
+
 type GmailSource struct {
 	service  *gmail.Service
 	creds    []byte
 	tokenDir string
 	tokenMgr *auth.TokenManager
+
+	maxThreads    int
+	query         string
+	includeLabels []string
+	excludeLabels []string
+	labelIDs      map[string]string // lowercase label name -> label ID
+
+	// allowDrafts requests the compose scope so actions can save draft replies
+	allowDrafts bool
+
+	// historyID is the mailbox history position reached by the last sync
+	historyID uint64
 }
 
 func New(config map[string]interface{}) (datasources.DataSource, error) {
@@ -31,10 +51,24 @@ func New(config map[string]interface{}) (datasources.DataSource, error) {
 		return nil, fmt.Errorf("token_dir not provided in config")
 	}
 
+	maxThreads, _ := config["max_threads"].(int)
+	if maxThreads <= 0 {
+		maxThreads = defaultMaxThreads
+	}
+	query, _ := config["query"].(string)
+	includeLabels, _ := config["include_labels"].([]string)
+	excludeLabels, _ := config["exclude_labels"].([]string)
+	allowDrafts, _ := config["allow_drafts"].(bool)
+
 	return &GmailSource{
-		creds:    []byte(credentials),
-		tokenDir: tokenDir,
-		tokenMgr: auth.NewTokenManager(tokenDir),
+		creds:         []byte(credentials),
+		tokenDir:      tokenDir,
+		tokenMgr:      auth.NewTokenManager(tokenDir),
+		maxThreads:    maxThreads,
+		query:         query,
+		includeLabels: includeLabels,
+		excludeLabels: excludeLabels,
+		allowDrafts:   allowDrafts,
 	}, nil
 }
 
@@ -43,16 +77,23 @@ func (g *GmailSource) Name() string {
 }
 
 func (g *GmailSource) Initialize(ctx context.Context) error {
+	// Drafting needs a broader grant, kept under its own token so enabling it
+	// prompts for consent instead of reusing the read-only token
+	scopes := []string{gmail.GmailReadonlyScope}
+	tokenName := "gmail"
+	if g.allowDrafts {
+		scopes = append(scopes, gmail.GmailComposeScope)
+		tokenName = "gmail_compose"
+	}
+
 	// Create OAuth2 config from credentials
-	config, err := google.ConfigFromJSON(g.creds,
-		gmail.GmailReadonlyScope,
-	)
+	config, err := google.ConfigFromJSON(g.creds, scopes...)
 	if err != nil {
 		return fmt.Errorf("failed to parse client secret file to config: %w", err)
 	}
 
 	// Get OAuth2 token
-	token, err := g.tokenMgr.GetToken(ctx, config, "gmail")
+	token, err := g.tokenMgr.GetToken(ctx, config, tokenName)
 	if err != nil {
 		return fmt.Errorf("failed to get token: %w", err)
 	}
@@ -65,91 +106,412 @@ func (g *GmailSource) Initialize(ctx context.Context) error {
 	if err != nil {
 		return fmt.Errorf("failed to create Gmail service: %w", err)
 	}
+
+	// Resolve label names so label changes reported by the History API can be filtered
+	labels, err := g.service.Users.Labels.List("me").Context(ctx).Do()
+	if err != nil {
+		return fmt.Errorf("failed to list labels: %w", err)
+	}
+	g.labelIDs = make(map[string]string, len(labels.Labels))
+	for _, label := range labels.Labels {
+		g.labelIDs[strings.ToLower(label.Name)] = label.Id
+	}
+	for _, name := range append(append([]string{}, g.includeLabels...), g.excludeLabels...) {
+		if _, ok := g.labelIDs[strings.ToLower(name)]; !ok {
+			log.Printf("Warning: Gmail label %q not found", name)
+		}
+	}
+
 	return nil
 }
 
+// Service returns the authenticated Gmail client. It can save drafts only when
+// the source was configured with allow_drafts.
+func (g *GmailSource) Service() *gmail.Service {
+	return g.service
+}
+
+// AllowsDrafts reports whether the source was granted the compose scope
+func (g *GmailSource) AllowsDrafts() bool {
+	return g.allowDrafts
+}
+
+// FetchData returns threads changed since the last sync. The first sync, and any sync
+// with a zero since, lists matching threads up to the configured cap; later syncs use
+// the History API to pick up new, relabeled and deleted messages.
 func (g *GmailSource) FetchData(ctx context.Context, since time.Time) ([]datasources.Document, error) {
-	query := fmt.Sprintf("after:%s", since.Format("2024/12/25"))
+	if since.IsZero() || g.historyID == 0 {
+		return g.fullSync(ctx, since)
+	}
 
-	var docs []datasources.Document
+	docs, err := g.incrementalSync(ctx)
+	if isNotFound(err) {
+		log.Printf("Gmail history %d is no longer available, falling back to a full sync", g.historyID)
+		return g.fullSync(ctx, since)
+	}
+	return docs, err
+}
+
+// fullSync lists every thread matching the configured filters
+func (g *GmailSource) fullSync(ctx context.Context, since time.Time) ([]datasources.Document, error) {
+	// Record the history position first so changes made while listing are picked up next time
+	profile, err := g.service.Users.GetProfile("me").Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch profile: %w", err)
+	}
+
+	query := g.searchQuery(since)
+	var threadIDs []string
 	pageToken := ""
 
-	for {
-		req := g.service.Users.Messages.List("me").MaxResults(25).Q(query)
+	for len(threadIDs) < g.maxThreads {
+		req := g.service.Users.Threads.List("me").
+			MaxResults(int64(min(100, g.maxThreads-len(threadIDs)))).
+			Q(query).
+			Context(ctx)
 		if pageToken != "" {
 			req.PageToken(pageToken)
 		}
 
 		r, err := req.Do()
 		if err != nil {
-			return nil, fmt.Errorf("failed to fetch messages: %w", err)
+			return nil, fmt.Errorf("failed to fetch threads: %w", err)
+		}
+
+		for _, t := range r.Threads {
+			threadIDs = append(threadIDs, t.Id)
 		}
 
-		for _, msg := range r.Messages {
-			message, err := g.service.Users.Messages.Get("me", msg.Id).Do()
+		pageToken = r.NextPageToken
+		if pageToken == "" {
+			break
+		}
+	}
+
+	// The listing already applied the search query
+	docs, err := g.fetchThreads(ctx, threadIDs, false)
+	if err != nil {
+		return nil, err
+	}
+
+	g.historyID = profile.HistoryId
+	return docs, nil
+}
+
+// incrementalSync fetches the threads touched since the last recorded history ID
+func (g *GmailSource) incrementalSync(ctx context.Context) ([]datasources.Document, error) {
+	var threadIDs []string
+	seen := make(map[string]bool)
+	add := func(msg *gmail.Message) {
+		if msg != nil && msg.ThreadId != "" && !seen[msg.ThreadId] {
+			seen[msg.ThreadId] = true
+			threadIDs = append(threadIDs, msg.ThreadId)
+		}
+	}
+
+	latest := g.historyID
+	err := g.service.Users.History.List("me").
+		StartHistoryId(g.historyID).
+		HistoryTypes("messageAdded", "messageDeleted", "labelAdded", "labelRemoved").
+		Pages(ctx, func(r *gmail.ListHistoryResponse) error {
+			for _, h := range r.History {
+				for _, m := range h.Messages {
+					add(m)
+				}
+				for _, m := range h.MessagesAdded {
+					add(m.Message)
+				}
+				for _, m := range h.MessagesDeleted {
+					add(m.Message)
+				}
+				for _, m := range h.LabelsAdded {
+					add(m.Message)
+				}
+				for _, m := range h.LabelsRemoved {
+					add(m.Message)
+				}
+			}
+			if r.HistoryId > latest {
+				latest = r.HistoryId
+			}
+			return nil
+		})
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch history: %w", err)
+	}
+
+	docs, err := g.fetchThreads(ctx, threadIDs, true)
+	if err != nil {
+		return nil, err
+	}
+
+	g.historyID = latest
+	return docs, nil
+}
+
+// fetchThreads loads each thread, returning deleted documents for threads that no
+// longer exist or no longer match the configured filters. checkQuery also checks
+// each thread against the search query, for threads that were not found by it.
+func (g *GmailSource) fetchThreads(ctx context.Context, threadIDs []string, checkQuery bool) ([]datasources.Document, error) {
+	var docs []datasources.Document
+	for _, id := range threadIDs {
+		thread, err := g.service.Users.Threads.Get("me", id).Format("full").Context(ctx).Do()
+		if isNotFound(err) {
+			docs = append(docs, g.deletedDocument(id))
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch thread %s: %w", id, err)
+		}
+
+		if !g.inScope(thread) {
+			docs = append(docs, g.deletedDocument(id))
+			continue
+		}
+		if checkQuery {
+			matches, err := g.matchesQuery(ctx, thread)
 			if err != nil {
+				return nil, err
+			}
+			if !matches {
+				docs = append(docs, g.deletedDocument(id))
 				continue
 			}
+		}
 
-			headers := make(map[string]string)
-			for _, header := range message.Payload.Headers {
-				headers[header.Name] = header.Value
+		if doc, ok := g.threadDocument(thread); ok {
+			docs = append(docs, doc)
+		}
+	}
+	return docs, nil
+}
+
+// searchQuery builds the Gmail search used for full syncs; incremental syncs
+// check the query per thread with matchesQuery
+func (g *GmailSource) searchQuery(since time.Time) string {
+	var parts []string
+	if !since.IsZero() {
+		parts = append(parts, fmt.Sprintf("after:%d", since.Unix()))
+	}
+	if g.query != "" {
+		parts = append(parts, "("+g.query+")")
+	}
+	if len(g.includeLabels) > 0 {
+		var labels []string
+		for _, name := range g.includeLabels {
+			labels = append(labels, "label:"+labelSearchName(name))
+		}
+		parts = append(parts, "{"+strings.Join(labels, " ")+"}")
+	}
+	for _, name := range g.excludeLabels {
+		parts = append(parts, "-label:"+labelSearchName(name))
+	}
+	return strings.Join(parts, " ")
+}
+
+// matchesQuery reports whether a thread matches the configured search query. The
+// History API can't filter by query, so the query is searched for among the
+// thread's own messages, picked out by their Message-ID headers.
+func (g *GmailSource) matchesQuery(ctx context.Context, thread *gmail.Thread) (bool, error) {
+	if g.query == "" {
+		return true, nil
+	}
+
+	var messageIDs []string
+	for _, msg := range thread.Messages {
+		if msg.Payload == nil {
+			continue
+		}
+		for _, header := range msg.Payload.Headers {
+			if strings.EqualFold(header.Name, "Message-ID") {
+				if id := strings.Trim(header.Value, "<> "); id != "" {
+					messageIDs = append(messageIDs, "rfc822msgid:"+id)
+				}
 			}
+		}
+	}
+	if len(messageIDs) == 0 {
+		// Nothing to search by, so keep the thread rather than drop it unchecked
+		return true, nil
+	}
+
+	q := "(" + g.query + ") {" + strings.Join(messageIDs, " ") + "}"
+	r, err := g.service.Users.Threads.List("me").Q(q).Context(ctx).Do()
+	if err != nil {
+		return false, fmt.Errorf("failed to check thread %s against the query: %w", thread.Id, err)
+	}
+	for _, t := range r.Threads {
+		if t.Id == thread.Id {
+			return true, nil
+		}
+	}
+	return false, nil
+}
 
-			content := fmt.Sprintf("From: %s\nTo: %s\nSubject: %s\n\n%s",
-				headers["From"],
-				headers["To"],
-				headers["Subject"],
-				getMessage(message),
-			)
-
-			timestamp := time.Unix(message.InternalDate/1000, 0)
-
-			doc := datasources.Document{
-				ID:        message.Id,
-				Content:   datasources.TruncateContent(content),
-				Title:     headers["Subject"],
-				URL:       fmt.Sprintf("https://mail.google.com/mail/u/0/#inbox/%s", message.Id),
-				Source:    g.Name(),
-				Timestamp: timestamp,
-				Metadata: map[string]interface{}{
-					"from":    headers["From"],
-					"to":      headers["To"],
-					"subject": headers["Subject"],
-					"labels":  message.LabelIds,
-					"raw":     message.Raw,
-				},
+// inScope reports whether a thread should be indexed: it must not be entirely in
+// trash or spam, must carry an included label when any are configured, and must
+// carry no excluded label
+func (g *GmailSource) inScope(thread *gmail.Thread) bool {
+	labels := make(map[string]bool)
+	live := false
+	for _, msg := range thread.Messages {
+		trashed := false
+		for _, label := range msg.LabelIds {
+			labels[label] = true
+			if label == "TRASH" || label == "SPAM" {
+				trashed = true
 			}
+		}
+		if !trashed {
+			live = true
+		}
+	}
+	if !live {
+		return false
+	}
 
-			docs = append(docs, doc)
+	if len(g.includeLabels) > 0 {
+		included := false
+		for _, name := range g.includeLabels {
+			if labels[g.labelID(name)] {
+				included = true
+				break
+			}
 		}
-		break //Always break
-		pageToken = r.NextPageToken
-		if pageToken == "" {
-			break
+		if !included {
+			return false
 		}
 	}
 
-	return docs, nil
+	for _, name := range g.excludeLabels {
+		if labels[g.labelID(name)] {
+			return false
+		}
+	}
+	return true
 }
 
-func getMessage(msg *gmail.Message) string {
-	if msg.Payload == nil {
-		return ""
+// labelID resolves a label name to its ID; system labels use their name as ID
+func (g *GmailSource) labelID(name string) string {
+	if id, ok := g.labelIDs[strings.ToLower(name)]; ok {
+		return id
 	}
+	return strings.ToUpper(name)
+}
 
-	var text string
-	var walk func(*gmail.MessagePart)
-	walk = func(part *gmail.MessagePart) {
-		if part.MimeType == "text/plain" && part.Body != nil && part.Body.Data != "" {
-			text += part.Body.Data
+func (g *GmailSource) deletedDocument(threadID string) datasources.Document {
+	return datasources.Document{ID: threadID, Source: g.Name(), Deleted: true}
+}
+
+// labelSearchName converts a label name to the form used in search queries
+func labelSearchName(name string) string {
+	return strings.NewReplacer(" ", "-", "/", "-").Replace(strings.ToLower(name))
+}
+
+func isNotFound(err error) bool {
+	var apiErr *googleapi.Error
+	return errors.As(err, &apiErr) && apiErr.Code == http.StatusNotFound
+}
+
+// threadDocument builds a single document from every message in a thread, keeping
+// only the new text of each reply
+func (g *GmailSource) threadDocument(thread *gmail.Thread) (datasources.Document, bool) {
+	if len(thread.Messages) == 0 {
+		return datasources.Document{}, false
+	}
+
+	var (
+		subject      string
+		body         strings.Builder
+		participants []string
+		seen         = make(map[string]bool)
+		labels       []string
+		seenLabels   = make(map[string]bool)
+		attachments  []string
+		messageIDs   []string
+		latest       time.Time
+	)
+
+	addParticipants := func(values ...string) {
+		for _, v := range values {
+			for _, addr := range strings.Split(v, ",") {
+				addr = strings.TrimSpace(addr)
+				key := strings.ToLower(addr)
+				if addr != "" && !seen[key] {
+					seen[key] = true
+					participants = append(participants, addr)
+				}
+			}
 		}
+	}
 
-		for _, p := range part.Parts {
-			walk(p)
+	for _, message := range thread.Messages {
+		headers := make(map[string]string)
+		if message.Payload != nil {
+			for _, header := range message.Payload.Headers {
+				headers[header.Name] = header.Value
+			}
+		}
+		if subject == "" {
+			subject = headers["Subject"]
+		}
+		addParticipants(headers["From"], headers["To"], headers["Cc"])
+
+		for _, label := range message.LabelIds {
+			if !seenLabels[label] {
+				seenLabels[label] = true
+				labels = append(labels, label)
+			}
+		}
+
+		names := attachmentNames(message)
+		attachments = append(attachments, names...)
+		messageIDs = append(messageIDs, message.Id)
+
+		timestamp := time.UnixMilli(message.InternalDate)
+		if timestamp.After(latest) {
+			latest = timestamp
+		}
+
+		fmt.Fprintf(&body, "From: %s\nDate: %s\n", headers["From"], timestamp.Format(time.RFC1123))
+		if len(names) > 0 {
+			fmt.Fprintf(&body, "Attachments: %s\n", strings.Join(names, ", "))
+		}
+		fmt.Fprintf(&body, "\n%s\n\n", stripQuoted(messageBody(message)))
+	}
+
+	first := thread.Messages[0]
+	firstHeaders := make(map[string]string)
+	if first.Payload != nil {
+		for _, header := range first.Payload.Headers {
+			firstHeaders[header.Name] = header.Value
 		}
 	}
 
-	walk(msg.Payload)
-	return text
+	content := fmt.Sprintf("Subject: %s\nParticipants: %s\nMessages: %d\n\n%s",
+		subject,
+		strings.Join(participants, ", "),
+		len(thread.Messages),
+		strings.TrimSpace(body.String()),
+	)
+
+	return datasources.Document{
+		ID:        thread.Id,
+		Content:   content,
+		Title:     subject,
+		URL:       fmt.Sprintf("https://mail.google.com/mail/u/0/#all/%s", thread.Id),
+		Source:    g.Name(),
+		Timestamp: latest,
+		Metadata: map[string]interface{}{
+			"from":          firstHeaders["From"],
+			"to":            firstHeaders["To"],
+			"subject":       subject,
+			"participants":  participants,
+			"labels":        labels,
+			"message_ids":   messageIDs,
+			"message_count": len(thread.Messages),
+			"attachments":   attachments,
+			"unread":        seenLabels["UNREAD"],
+		},
+	}, true
 }
@@ -0,0 +1,146 @@
+package gmail
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"google.golang.org/api/gmail/v1"
+	"google.golang.org/api/option"
+)
+
+// fakeGmail serves the history, thread and search calls of an incremental sync.
+// Searches match threads whose Message-ID is listed in matching.
+type fakeGmail struct {
+	mu       sync.Mutex
+	threads  map[string]*gmail.Thread
+	matching map[string]bool
+	queries  []string
+}
+
+func newTestSource(t *testing.T, query string) (*GmailSource, *fakeGmail) {
+	api := &fakeGmail{
+		threads: map[string]*gmail.Thread{
+			"18c2f4a9b1e3d7c5": testThread("18c2f4a9b1e3d7c5", "<CAK1@mail.example.com>", "Q3 budget"),
+			"18c2f4a9b1e3d7c6": testThread("18c2f4a9b1e3d7c6", "<promo-42@shop.example.com>", "50% off"),
+		},
+		matching: map[string]bool{"CAK1@mail.example.com": true},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/gmail/v1/users/me/history", func(w http.ResponseWriter, r *http.Request) {
+		var history []*gmail.History
+		for id := range api.threads {
+			history = append(history, &gmail.History{
+				MessagesAdded: []*gmail.HistoryMessageAdded{{Message: &gmail.Message{Id: id, ThreadId: id}}},
+			})
+		}
+		writeGmailJSON(w, &gmail.ListHistoryResponse{History: history, HistoryId: 2000})
+	})
+	mux.HandleFunc("/gmail/v1/users/me/threads/", func(w http.ResponseWriter, r *http.Request) {
+		thread, ok := api.threads[strings.TrimPrefix(r.URL.Path, "/gmail/v1/users/me/threads/")]
+		if !ok {
+			http.Error(w, `{"error": {"code": 404, "message": "Not Found"}}`, http.StatusNotFound)
+			return
+		}
+		writeGmailJSON(w, thread)
+	})
+	mux.HandleFunc("/gmail/v1/users/me/threads", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query().Get("q")
+		api.mu.Lock()
+		api.queries = append(api.queries, q)
+		api.mu.Unlock()
+
+		resp := &gmail.ListThreadsResponse{}
+		for id, thread := range api.threads {
+			msgID := strings.Trim(thread.Messages[0].Payload.Headers[0].Value, "<>")
+			if strings.Contains(q, "rfc822msgid:"+msgID) && api.matching[msgID] {
+				resp.Threads = append(resp.Threads, &gmail.Thread{Id: id})
+			}
+		}
+		writeGmailJSON(w, resp)
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	service, err := gmail.NewService(context.Background(),
+		option.WithHTTPClient(server.Client()), option.WithEndpoint(server.URL+"/"))
+	if err != nil {
+		t.Fatalf("failed to create Gmail service: %v", err)
+	}
+	return &GmailSource{service: service, query: query, historyID: 1000}, api
+}
+
+func testThread(id, messageID, subject string) *gmail.Thread {
+	return &gmail.Thread{Id: id, Messages: []*gmail.Message{{
+		Id:           id,
+		ThreadId:     id,
+		LabelIds:     []string{"INBOX"},
+		InternalDate: 1718060400000,
+		Payload: &gmail.MessagePart{
+			MimeType: "text/plain",
+			Headers: []*gmail.MessagePartHeader{
+				{Name: "Message-ID", Value: messageID},
+				{Name: "Subject", Value: subject},
+				{Name: "From", Value: "ana@example.com"},
+			},
+			Body: &gmail.MessagePartBody{Data: base64.URLEncoding.EncodeToString([]byte("See the numbers attached."))},
+		},
+	}}}
+}
+
+func writeGmailJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func TestIncrementalSyncQuery(t *testing.T) {
+	g, api := newTestSource(t, "-category:promotions")
+
+	docs, err := g.incrementalSync(context.Background())
+	if err != nil {
+		t.Fatalf("incrementalSync() error = %v", err)
+	}
+
+	deleted := make(map[string]bool)
+	for _, doc := range docs {
+		deleted[doc.ID] = doc.Deleted
+	}
+	if len(docs) != 2 || deleted["18c2f4a9b1e3d7c5"] || !deleted["18c2f4a9b1e3d7c6"] {
+		t.Errorf("documents = %+v, want the matching thread indexed and the other deleted", docs)
+	}
+	if g.historyID != 2000 {
+		t.Errorf("historyID = %d, want 2000", g.historyID)
+	}
+
+	if len(api.queries) != 2 {
+		t.Fatalf("searched %d times, want once per thread", len(api.queries))
+	}
+	for _, q := range api.queries {
+		if !strings.HasPrefix(q, "(-category:promotions) {rfc822msgid:") {
+			t.Errorf("search q = %q, want the query limited to the thread's messages", q)
+		}
+	}
+}
+
+func TestIncrementalSyncWithoutQuery(t *testing.T) {
+	g, api := newTestSource(t, "")
+
+	docs, err := g.incrementalSync(context.Background())
+	if err != nil {
+		t.Fatalf("incrementalSync() error = %v", err)
+	}
+	for _, doc := range docs {
+		if doc.Deleted {
+			t.Errorf("thread %s deleted without a query", doc.ID)
+		}
+	}
+	if len(api.queries) != 0 {
+		t.Errorf("searched %v without a query", api.queries)
+	}
+}
@@ -0,0 +1,145 @@
+package gmail
+
+import (
+	"encoding/base64"
+	"io"
+	"mime"
+	"regexp"
+	"strings"
+
+	"golang.org/x/text/encoding/htmlindex"
+	"google.golang.org/api/gmail/v1"
+
+	"github.com/michaelgalloway/sophia/internal/extract"
+)
+
+// messageBody returns the readable text of a message, preferring text/plain parts
+// and falling back to converted text/html when a message has no plain part
+func messageBody(msg *gmail.Message) string {
+	if msg.Payload == nil {
+		return ""
+	}
+
+	var plain, htmlParts []string
+	var walk func(*gmail.MessagePart)
+	walk = func(part *gmail.MessagePart) {
+		if part.Filename == "" && part.Body != nil && part.Body.Data != "" {
+			mediaType, params := contentType(part)
+			switch mediaType {
+			case "text/plain":
+				if text, ok := decodePart(part.Body.Data, params["charset"]); ok {
+					plain = append(plain, text)
+				}
+			case "text/html":
+				if text, ok := decodePart(part.Body.Data, params["charset"]); ok {
+					htmlParts = append(htmlParts, extract.HTMLToText(text))
+				}
+			}
+		}
+
+		for _, p := range part.Parts {
+			walk(p)
+		}
+	}
+	walk(msg.Payload)
+
+	if len(plain) > 0 {
+		return strings.Join(plain, "\n")
+	}
+	return strings.Join(htmlParts, "\n")
+}
+
+// attachmentNames lists the filenames of a message's attachments
+func attachmentNames(msg *gmail.Message) []string {
+	var names []string
+	var walk func(*gmail.MessagePart)
+	walk = func(part *gmail.MessagePart) {
+		if part.Filename != "" {
+			names = append(names, part.Filename)
+		}
+		for _, p := range part.Parts {
+			walk(p)
+		}
+	}
+	if msg.Payload != nil {
+		walk(msg.Payload)
+	}
+	return names
+}
+
+// contentType parses the part's Content-Type header, falling back to its MimeType
+func contentType(part *gmail.MessagePart) (string, map[string]string) {
+	for _, h := range part.Headers {
+		if strings.EqualFold(h.Name, "Content-Type") {
+			if mediaType, params, err := mime.ParseMediaType(h.Value); err == nil {
+				return mediaType, params
+			}
+		}
+	}
+	return strings.ToLower(part.MimeType), map[string]string{}
+}
+
+// decodePart decodes a base64url body and converts it from its charset to UTF-8
+func decodePart(data, charset string) (string, bool) {
+	raw, err := base64.URLEncoding.DecodeString(data)
+	if err != nil {
+		raw, err = base64.RawURLEncoding.DecodeString(strings.TrimRight(data, "="))
+		if err != nil {
+			return "", false
+		}
+	}
+
+	charset = strings.ToLower(strings.TrimSpace(charset))
+	if charset == "" || charset == "utf-8" || charset == "us-ascii" {
+		return string(raw), true
+	}
+
+	enc, err := htmlindex.Get(charset)
+	if err != nil {
+		// Unknown charset: keep the bytes, which are usually ASCII-compatible
+		return string(raw), true
+	}
+	decoded, err := io.ReadAll(enc.NewDecoder().Reader(strings.NewReader(string(raw))))
+	if err != nil {
+		return string(raw), true
+	}
+	return string(decoded), true
+}
+
+var (
+	// wrappedReplyHeader matches an attribution line that was wrapped onto two lines
+	wrappedReplyHeader = regexp.MustCompile(`(?m)^(On .{5,200})\n(.{0,200}wrote:\s*)$`)
+
+	// replyHeader matches the attribution line that introduces a quoted reply,
+	// e.g. "On Tue, Mar 4, 2025 at 9:12 AM Jane <jane@example.com> wrote:"
+	replyHeader = regexp.MustCompile(`(?m)^On .{5,300}wrote:\s*$`)
+
+	// forwardedHeaders matches Outlook-style quoted headers and original-message markers
+	forwardedHeaders = regexp.MustCompile(`(?m)^(-{2,}\s*Original Message\s*-{2,}|_{10,}|From: .+\n(Sent|Date): .+)`)
+
+	// signatureStart matches common signature delimiters and mobile footers
+	signatureStart = regexp.MustCompile(`(?m)^(-- ?|Sent from my \w+.*|Get Outlook for \w+.*)$`)
+)
+
+// stripQuoted removes quoted replies, previous messages and signatures so each
+// message in a thread contributes only its new text
+func stripQuoted(body string) string {
+	body = strings.ReplaceAll(body, "\r\n", "\n")
+
+	body = wrappedReplyHeader.ReplaceAllString(body, "$1 $2")
+
+	for _, re := range []*regexp.Regexp{replyHeader, forwardedHeaders, signatureStart} {
+		if loc := re.FindStringIndex(body); loc != nil {
+			body = body[:loc[0]]
+		}
+	}
+
+	var kept []string
+	for _, line := range strings.Split(body, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), ">") {
+			continue
+		}
+		kept = append(kept, strings.TrimRight(line, " \t"))
+	}
+	return strings.TrimSpace(strings.Join(kept, "\n"))
+}
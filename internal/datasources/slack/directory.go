@@ -0,0 +1,187 @@
+package slack
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/slack-go/slack"
+)
+
+// channelRefresh is how long the channel list is cached before a missing
+// channel triggers a new listing
+const channelRefresh = time.Hour
+
+// directory caches user and channel lookups across syncs
+type directory struct {
+	client *slack.Client
+
+	mu       sync.Mutex
+	users    map[string]string
+	channels map[string]slack.Channel // by name
+	byID     map[string]slack.Channel
+	listedAt time.Time
+	types    []string
+}
+
+func newDirectory(client *slack.Client, types []string) *directory {
+	return &directory{
+		client:   client,
+		users:    make(map[string]string),
+		channels: make(map[string]slack.Channel),
+		byID:     make(map[string]slack.Channel),
+		types:    types,
+	}
+}
+
+// userName resolves a user ID to a display name, caching the result.
+// Unknown users fall back to their ID.
+func (d *directory) userName(ctx context.Context, id string) string {
+	if id == "" {
+		return ""
+	}
+
+	d.mu.Lock()
+	name, ok := d.users[id]
+	d.mu.Unlock()
+	if ok {
+		return name
+	}
+
+	name = id
+	user, err := d.client.GetUserInfoContext(ctx, id)
+	if err == nil {
+		switch {
+		case user.Profile.DisplayName != "":
+			name = user.Profile.DisplayName
+		case user.RealName != "":
+			name = user.RealName
+		case user.Name != "":
+			name = user.Name
+		}
+	} else if ctx.Err() != nil {
+		// Don't cache lookups that failed because the sync was cancelled
+		return name
+	}
+
+	d.mu.Lock()
+	d.users[id] = name
+	d.mu.Unlock()
+	return name
+}
+
+// channel finds a channel by name, listing all channels again only when the
+// name is unknown and the cached list is stale
+func (d *directory) channel(ctx context.Context, name string) (slack.Channel, error) {
+	d.mu.Lock()
+	channel, ok := d.channels[name]
+	stale := time.Since(d.listedAt) > channelRefresh
+	d.mu.Unlock()
+	if ok {
+		return channel, nil
+	}
+
+	if stale {
+		if err := d.refresh(ctx); err != nil {
+			return slack.Channel{}, err
+		}
+		d.mu.Lock()
+		channel, ok = d.channels[name]
+		d.mu.Unlock()
+		if ok {
+			return channel, nil
+		}
+	}
+
+	return slack.Channel{}, fmt.Errorf("channel %s not found", name)
+}
+
+// channelByID returns a cached channel by its ID
+func (d *directory) channelByID(id string) (slack.Channel, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	channel, ok := d.byID[id]
+	return channel, ok
+}
+
+// channelInfo returns a channel by ID, looking it up when it isn't cached
+func (d *directory) channelInfo(ctx context.Context, id string) (slack.Channel, error) {
+	if channel, ok := d.channelByID(id); ok {
+		return channel, nil
+	}
+
+	channel, err := d.client.GetConversationInfoContext(ctx, &slack.GetConversationInfoInput{ChannelID: id})
+	if err != nil {
+		return slack.Channel{}, fmt.Errorf("failed to get channel %s: %w", id, err)
+	}
+
+	d.mu.Lock()
+	d.byID[id] = *channel
+	if channel.Name != "" {
+		d.channels[channel.Name] = *channel
+	}
+	d.mu.Unlock()
+	return *channel, nil
+}
+
+// directMessages returns the DMs and group DMs visible to the token
+func (d *directory) directMessages(ctx context.Context) ([]slack.Channel, error) {
+	d.mu.Lock()
+	stale := time.Since(d.listedAt) > channelRefresh
+	d.mu.Unlock()
+
+	if stale {
+		if err := d.refresh(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var dms []slack.Channel
+	for _, channel := range d.byID {
+		if channel.IsIM || channel.IsMpIM {
+			dms = append(dms, channel)
+		}
+	}
+	sort.Slice(dms, func(i, j int) bool { return dms[i].ID < dms[j].ID })
+	return dms, nil
+}
+
+// refresh lists every channel visible to the token, following pagination
+func (d *directory) refresh(ctx context.Context) error {
+	channels := make(map[string]slack.Channel)
+	byID := make(map[string]slack.Channel)
+
+	params := &slack.GetConversationsParameters{
+		ExcludeArchived: true,
+		Types:           d.types,
+		Limit:           200,
+	}
+	for {
+		page, cursor, err := d.client.GetConversationsContext(ctx, params)
+		if err != nil {
+			return fmt.Errorf("failed to list channels: %w", err)
+		}
+		for _, channel := range page {
+			if channel.Name != "" {
+				channels[channel.Name] = channel
+			}
+			byID[channel.ID] = channel
+		}
+		if cursor == "" {
+			break
+		}
+		params.Cursor = cursor
+	}
+
+	d.mu.Lock()
+	d.channels = channels
+	d.byID = byID
+	d.listedAt = time.Now()
+	d.mu.Unlock()
+	return nil
+}
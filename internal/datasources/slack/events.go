@@ -0,0 +1,237 @@
+package slack
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+
+	"github.com/michaelgalloway/sophia/internal/datasources"
+)
+
+// maxEventSize bounds the body of an Events API request
+const maxEventSize = 1 << 20
+
+// eventTimeout bounds how long a single event takes to process
+const eventTimeout = 2 * time.Minute
+
+// Ingester stores documents produced outside the sync schedule
+type Ingester interface {
+	Ingest(ctx context.Context, source string, docs []datasources.Document) error
+}
+
+// EventHandler receives Slack Events API callbacks and indexes new, edited and
+// deleted messages as they happen, instead of waiting for the next sync
+type EventHandler struct {
+	source        *SlackSource
+	signingSecret string
+	ingester      Ingester
+	ctx           context.Context
+}
+
+// NewEventHandler creates an Events API handler. Requests are verified with the
+// app's signing secret. Events are processed in the background under ctx so
+// Slack gets its acknowledgement within the required three seconds.
+func NewEventHandler(ctx context.Context, source *SlackSource, signingSecret string, ingester Ingester) *EventHandler {
+	return &EventHandler{
+		source:        source,
+		signingSecret: signingSecret,
+		ingester:      ingester,
+		ctx:           ctx,
+	}
+}
+
+// ServeHTTP verifies the request signature, answers URL verification challenges
+// and acknowledges callbacks before processing them
+func (h *EventHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxEventSize))
+	if err != nil {
+		http.Error(w, "Failed to read request", http.StatusBadRequest)
+		return
+	}
+
+	verifier, err := slack.NewSecretsVerifier(r.Header, h.signingSecret)
+	if err != nil {
+		http.Error(w, "Invalid signature", http.StatusUnauthorized)
+		return
+	}
+	if _, err := verifier.Write(body); err != nil {
+		http.Error(w, "Invalid signature", http.StatusUnauthorized)
+		return
+	}
+	if err := verifier.Ensure(); err != nil {
+		http.Error(w, "Invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	event, err := slackevents.ParseEvent(json.RawMessage(body), slackevents.OptionNoVerifyToken())
+	if err != nil {
+		http.Error(w, "Invalid event", http.StatusBadRequest)
+		return
+	}
+
+	if event.Type == slackevents.URLVerification {
+		var challenge slackevents.EventsAPIURLVerificationEvent
+		if err := json.Unmarshal(body, &challenge); err != nil {
+			http.Error(w, "Invalid challenge", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte(challenge.Challenge))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+
+	go func() {
+		ctx, cancel := context.WithTimeout(h.ctx, eventTimeout)
+		defer cancel()
+		if err := h.handle(ctx, event); err != nil {
+			log.Printf("Failed to process Slack event: %v", err)
+		}
+	}()
+}
+
+// HandleEvent processes a raw Events API payload synchronously, without
+// verifying its signature. It is used to replay recorded events.
+func (h *EventHandler) HandleEvent(ctx context.Context, payload []byte) error {
+	event, err := slackevents.ParseEvent(json.RawMessage(payload), slackevents.OptionNoVerifyToken())
+	if err != nil {
+		return fmt.Errorf("failed to parse event: %w", err)
+	}
+	return h.handle(ctx, event)
+}
+
+func (h *EventHandler) handle(ctx context.Context, event slackevents.EventsAPIEvent) error {
+	if event.Type != slackevents.CallbackEvent {
+		return nil
+	}
+
+	msg, ok := event.InnerEvent.Data.(*slackevents.MessageEvent)
+	if !ok {
+		return nil
+	}
+
+	docs, err := h.source.messageEvent(ctx, msg)
+	if err != nil || len(docs) == 0 {
+		return err
+	}
+	return h.ingester.Ingest(ctx, h.source.Name(), docs)
+}
+
+// messageEvent rebuilds the document affected by a message event: the thread a
+// message was posted, edited or deleted in, or a deletion for a removed message
+func (s *SlackSource) messageEvent(ctx context.Context, ev *slackevents.MessageEvent) ([]datasources.Document, error) {
+	channel, err := s.directory.channelInfo(ctx, ev.Channel)
+	if err != nil {
+		return nil, err
+	}
+	if !s.indexes(channel) {
+		return nil, nil
+	}
+
+	var threadTS string
+	switch ev.SubType {
+	case "message_changed":
+		if ev.Message == nil {
+			return nil, nil
+		}
+		threadTS = rootTS(ev.Message.TimeStamp, ev.Message.ThreadTimeStamp)
+	case "message_deleted":
+		if ev.PreviousMessage != nil && ev.PreviousMessage.ThreadTimeStamp != "" &&
+			ev.PreviousMessage.ThreadTimeStamp != ev.DeletedTimeStamp {
+			// A reply was removed, so its thread is rebuilt without it
+			threadTS = ev.PreviousMessage.ThreadTimeStamp
+			break
+		}
+		s.forget(channel.ID, ev.DeletedTimeStamp)
+		return []datasources.Document{{
+			ID:      messageID(channel.ID, ev.DeletedTimeStamp),
+			Source:  s.Name(),
+			Deleted: true,
+		}}, nil
+	default:
+		if ignoredSubtypes[ev.SubType] {
+			return nil, nil
+		}
+		threadTS = rootTS(ev.TimeStamp, ev.ThreadTimeStamp)
+	}
+
+	root, replies, err := s.threadMessages(ctx, channel.ID, threadTS)
+	if isNotFound(err) || (err == nil && root.Timestamp == "") {
+		s.forget(channel.ID, threadTS)
+		return []datasources.Document{{
+			ID:      messageID(channel.ID, threadTS),
+			Source:  s.Name(),
+			Deleted: true,
+		}}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s.remember(channel.ID, threadTS)
+	return []datasources.Document{s.threadDocument(ctx, channel, root, replies)}, nil
+}
+
+// indexes reports whether a conversation is one this source syncs
+func (s *SlackSource) indexes(channel slack.Channel) bool {
+	if channel.IsIM || channel.IsMpIM {
+		return s.config.includeDMs
+	}
+	for _, name := range s.config.channels {
+		if strings.TrimPrefix(strings.TrimSpace(name), "#") == channel.Name {
+			return true
+		}
+	}
+	return false
+}
+
+// remember records a message delivered by an event so a later sync can notice its deletion
+func (s *SlackSource) remember(channelID, ts string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if state, ok := s.state[channelID]; ok {
+		state.messages[ts] = true
+	}
+}
+
+// forget drops a deleted message from the sync state
+func (s *SlackSource) forget(channelID, ts string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if state, ok := s.state[channelID]; ok {
+		delete(state.messages, ts)
+		delete(state.threads, ts)
+	}
+}
+
+// rootTS returns the timestamp of the thread a message belongs to
+func rootTS(ts, threadTS string) string {
+	if threadTS != "" {
+		return threadTS
+	}
+	return ts
+}
+
+// isNotFound reports whether Slack no longer has the requested message
+func isNotFound(err error) bool {
+	var slackErr slack.SlackErrorResponse
+	if errors.As(err, &slackErr) {
+		return slackErr.Err == "thread_not_found" || slackErr.Err == "message_not_found"
+	}
+	return false
+}
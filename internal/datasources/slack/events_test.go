@@ -0,0 +1,343 @@
+package slack
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/slack-go/slack"
+
+	"github.com/michaelgalloway/sophia/internal/datasources"
+)
+
+const (
+	testSecret   = "8f742231b10e8888abcd99yyyzzz85a5"
+	testThreadTS = "1718060400.000100"
+)
+
+// fakeSlackAPI serves the Web API methods the event handler and polling call
+type fakeSlackAPI struct {
+	mu      sync.Mutex
+	threads map[string][]map[string]interface{}
+	history []map[string]interface{}
+	calls   []string
+}
+
+func newFakeSlackAPI(t *testing.T) (*fakeSlackAPI, *httptest.Server) {
+	api := &fakeSlackAPI{
+		threads: map[string][]map[string]interface{}{
+			testThreadTS: {
+				{
+					"type": "message", "user": "U0001", "ts": testThreadTS, "thread_ts": testThreadTS,
+					"text":        "Deploying the release to production at 3pm (moved from 2pm)",
+					"edited":      map[string]string{"user": "U0001", "ts": "1718060800.000000"},
+					"reply_count": 1, "latest_reply": "1718060700.000200",
+				},
+				{
+					"type": "message", "user": "U0001", "ts": "1718060700.000200", "thread_ts": testThreadTS,
+					"text": "Deploy is done, <@U0002> can you check the dashboards?",
+				},
+			},
+		},
+	}
+	users := map[string]string{"U0001": "ana", "U0002": "ben"}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/conversations.info", func(w http.ResponseWriter, r *http.Request) {
+		api.record("conversations.info " + r.FormValue("channel"))
+		writeSlackJSON(w, map[string]interface{}{
+			"ok":      true,
+			"channel": map[string]interface{}{"id": r.FormValue("channel"), "name": "general", "is_channel": true},
+		})
+	})
+	mux.HandleFunc("/users.info", func(w http.ResponseWriter, r *http.Request) {
+		id := r.FormValue("user")
+		writeSlackJSON(w, map[string]interface{}{
+			"ok":   true,
+			"user": map[string]interface{}{"id": id, "name": users[id], "profile": map[string]string{"display_name": users[id]}},
+		})
+	})
+	mux.HandleFunc("/conversations.history", func(w http.ResponseWriter, r *http.Request) {
+		api.record("conversations.history " + r.FormValue("channel"))
+		api.mu.Lock()
+		messages := api.history
+		api.mu.Unlock()
+		writeSlackJSON(w, map[string]interface{}{"ok": true, "messages": messages, "has_more": false})
+	})
+	mux.HandleFunc("/conversations.replies", func(w http.ResponseWriter, r *http.Request) {
+		ts := r.FormValue("ts")
+		api.record("conversations.replies " + ts)
+
+		api.mu.Lock()
+		messages, ok := api.threads[ts]
+		api.mu.Unlock()
+		if !ok {
+			writeSlackJSON(w, map[string]interface{}{"ok": false, "error": "thread_not_found"})
+			return
+		}
+		writeSlackJSON(w, map[string]interface{}{"ok": true, "messages": messages, "has_more": false})
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return api, server
+}
+
+func (f *fakeSlackAPI) record(call string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, call)
+}
+
+func (f *fakeSlackAPI) called(prefix string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, call := range f.calls {
+		if strings.HasPrefix(call, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func writeSlackJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// fakeIngester records ingested batches
+type fakeIngester struct {
+	mu      sync.Mutex
+	batches [][]datasources.Document
+	done    chan struct{}
+}
+
+func (f *fakeIngester) Ingest(ctx context.Context, source string, docs []datasources.Document) error {
+	f.mu.Lock()
+	f.batches = append(f.batches, docs)
+	f.mu.Unlock()
+	if f.done != nil {
+		f.done <- struct{}{}
+	}
+	return nil
+}
+
+func newTestHandler(t *testing.T) (*EventHandler, *fakeSlackAPI, *fakeIngester) {
+	api, server := newFakeSlackAPI(t)
+
+	source, err := New(map[string]interface{}{
+		"token":    "xoxb-test",
+		"channels": []string{"general"},
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	s := source.(*SlackSource)
+	s.client = slack.New("xoxb-test", slack.OptionAPIURL(server.URL+"/"))
+	s.directory = newDirectory(s.client, []string{"public_channel", "private_channel"})
+	s.workspaceURL = "https://acme.slack.com/"
+
+	ingester := &fakeIngester{}
+	return NewEventHandler(context.Background(), s, testSecret, ingester), api, ingester
+}
+
+func TestHandleEventReplay(t *testing.T) {
+	tests := []struct {
+		file        string
+		wantID      string
+		wantDeleted bool
+		contains    []string
+		excludes    []string
+		// fetchesThread is whether the thread is read back from Slack
+		fetchesThread bool
+	}{
+		{
+			file:          "message.json",
+			wantID:        "C0001:" + testThreadTS,
+			contains:      []string{"Deploy is done, @ben can you check the dashboards?", "ana ["},
+			fetchesThread: true,
+		},
+		{
+			file:          "message_changed.json",
+			wantID:        "C0001:" + testThreadTS,
+			contains:      []string{"production at 3pm (moved from 2pm)"},
+			excludes:      []string{"production at 2pm"},
+			fetchesThread: true,
+		},
+		{
+			file:        "message_deleted_root.json",
+			wantID:      "C0001:1718061000.000400",
+			wantDeleted: true,
+		},
+		{
+			file:          "message_deleted_reply.json",
+			wantID:        "C0001:" + testThreadTS,
+			excludes:      []string{"Wrong thread, sorry"},
+			fetchesThread: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			handler, api, ingester := newTestHandler(t)
+
+			if err := handler.HandleEvent(context.Background(), readEvent(t, tt.file)); err != nil {
+				t.Fatalf("HandleEvent() error = %v", err)
+			}
+
+			if len(ingester.batches) != 1 || len(ingester.batches[0]) != 1 {
+				t.Fatalf("ingested %v, want one document", ingester.batches)
+			}
+			doc := ingester.batches[0][0]
+			if doc.ID != tt.wantID || doc.Deleted != tt.wantDeleted || doc.Source != "slack" {
+				t.Errorf("document = {ID: %q, Deleted: %v, Source: %q}, want {ID: %q, Deleted: %v, Source: \"slack\"}",
+					doc.ID, doc.Deleted, doc.Source, tt.wantID, tt.wantDeleted)
+			}
+			for _, want := range tt.contains {
+				if !strings.Contains(doc.Content, want) {
+					t.Errorf("content = %q, missing %q", doc.Content, want)
+				}
+			}
+			for _, exclude := range tt.excludes {
+				if strings.Contains(doc.Content, exclude) {
+					t.Errorf("content = %q, should not contain %q", doc.Content, exclude)
+				}
+			}
+			if got := api.called("conversations.replies " + testThreadTS); got != tt.fetchesThread {
+				t.Errorf("thread fetched = %v, want %v", got, tt.fetchesThread)
+			}
+		})
+	}
+}
+
+func TestHandleEventThreadGone(t *testing.T) {
+	handler, api, ingester := newTestHandler(t)
+	api.mu.Lock()
+	delete(api.threads, testThreadTS)
+	api.mu.Unlock()
+
+	if err := handler.HandleEvent(context.Background(), readEvent(t, "message_changed.json")); err != nil {
+		t.Fatalf("HandleEvent() error = %v", err)
+	}
+	if len(ingester.batches) != 1 || !ingester.batches[0][0].Deleted {
+		t.Fatalf("ingested %v, want a deletion of the thread", ingester.batches)
+	}
+}
+
+func TestHandleEventURLVerification(t *testing.T) {
+	handler, api, ingester := newTestHandler(t)
+
+	if err := handler.HandleEvent(context.Background(), readEvent(t, "url_verification.json")); err != nil {
+		t.Fatalf("HandleEvent() error = %v", err)
+	}
+	if len(ingester.batches) != 0 || len(api.calls) != 0 {
+		t.Errorf("url_verification ingested %v and called %v, want nothing", ingester.batches, api.calls)
+	}
+}
+
+func TestServeHTTPSignature(t *testing.T) {
+	challenge := readEvent(t, "url_verification.json")
+	now := time.Now()
+
+	tests := []struct {
+		name       string
+		timestamp  time.Time
+		secret     string
+		wantStatus int
+	}{
+		{name: "valid", timestamp: now, secret: testSecret, wantStatus: http.StatusOK},
+		{name: "wrong secret", timestamp: now, secret: "not-the-secret", wantStatus: http.StatusUnauthorized},
+		{name: "stale timestamp", timestamp: now.Add(-10 * time.Minute), secret: testSecret, wantStatus: http.StatusUnauthorized},
+		{name: "unsigned", wantStatus: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler, _, _ := newTestHandler(t)
+
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/slack/events", strings.NewReader(string(challenge)))
+			if tt.secret != "" {
+				signRequest(req, tt.secret, tt.timestamp, challenge)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if tt.wantStatus == http.StatusOK && rec.Body.String() != "3eZbrw1aBm2rZgRNFdxV2595E9CY3gmdALWMmHkvFXO7tYXAYM8P" {
+				t.Errorf("body = %q, want the challenge", rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestServeHTTPTamperedBody(t *testing.T) {
+	handler, _, ingester := newTestHandler(t)
+	payload := readEvent(t, "message.json")
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/slack/events",
+		strings.NewReader(strings.Replace(string(payload), "Deploy is done", "Deploy failed", 1)))
+	signRequest(req, testSecret, time.Now(), payload)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+	if len(ingester.batches) != 0 {
+		t.Errorf("ingested %v from a tampered request", ingester.batches)
+	}
+}
+
+func TestServeHTTPCallback(t *testing.T) {
+	handler, _, ingester := newTestHandler(t)
+	ingester.done = make(chan struct{}, 1)
+	payload := readEvent(t, "message.json")
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/slack/events", strings.NewReader(string(payload)))
+	signRequest(req, testSecret, time.Now(), payload)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	// Callbacks are acknowledged first and processed in the background
+	select {
+	case <-ingester.done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("event was not ingested")
+	}
+}
+
+// signRequest adds the headers Slack sends, signed with secret
+func signRequest(req *http.Request, secret string, timestamp time.Time, body []byte) {
+	ts := strconv.FormatInt(timestamp.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("v0:" + ts + ":"))
+	mac.Write(body)
+	req.Header.Set("X-Slack-Request-Timestamp", ts)
+	req.Header.Set("X-Slack-Signature", "v0="+hex.EncodeToString(mac.Sum(nil)))
+}
+
+func readEvent(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", "events", name))
+	if err != nil {
+		t.Fatalf("failed to read event: %v", err)
+	}
+	return data
+}
@@ -1,21 +1,77 @@
 package slack
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/slack-go/slack"
 
 	"github.com/michaelgalloway/sophia/internal/datasources"
+	"github.com/michaelgalloway/sophia/internal/extract"
 )
 
+// Defaults for how far back messages are read
+const (
+	defaultHistory      = 90 * 24 * time.Hour
+	defaultThreadWindow = 7 * 24 * time.Hour
+)
+
+// defaultMaxFileSize is the largest shared file downloaded for text extraction
+const defaultMaxFileSize = 10 << 20
+
+// ignoredSubtypes are channel events that carry no conversation content
+var ignoredSubtypes = map[string]bool{
+	"channel_join":    true,
+	"channel_leave":   true,
+	"group_join":      true,
+	"group_leave":     true,
+	"channel_topic":   true,
+	"channel_purpose": true,
+	"channel_name":    true,
+	"channel_archive": true,
+	"pinned_item":     true,
+	"unpinned_item":   true,
+}
+
 type SlackSource struct {
-	client *slack.Client
-	config struct {
-		token    string
-		channels []string
+	client       *slack.Client
+	directory    *directory
+	workspaceURL string
+	config       struct {
+		token        string
+		channels     []string
+		includeDMs   bool
+		indexFiles   bool
+		maxFileSize  int
+		history      time.Duration
+		threadWindow time.Duration
 	}
+
+	// state tracks, per channel, the newest message seen, the latest reply of
+	// each recent thread and which messages exist, so incremental syncs only
+	// fetch what changed and notice deletions
+	mu    sync.Mutex
+	state map[string]*channelState
+
+	// files caches text extracted from shared files, by file ID
+	files map[string]cachedFile
+}
+
+type channelState struct {
+	lastTS   string
+	threads  map[string]string // thread ts → signature of its replies and root edits
+	messages map[string]bool   // ts of every indexed thread or message inside the sync window
+}
+
+type cachedFile struct {
+	text string
+	used time.Time
 }
 
 func New(config map[string]interface{}) (datasources.DataSource, error) {
@@ -29,11 +85,43 @@ func New(config map[string]interface{}) (datasources.DataSource, error) {
 		return nil, fmt.Errorf("channels not provided in config")
 	}
 
+	includeDMs, _ := config["include_dms"].(bool)
+	types := []string{"public_channel", "private_channel"}
+	if includeDMs {
+		types = append(types, "im", "mpim")
+	}
+
+	client := slack.New(token)
 	s := &SlackSource{
-		client: slack.New(token),
+		client:    client,
+		directory: newDirectory(client, types),
+		state:     make(map[string]*channelState),
+		files:     make(map[string]cachedFile),
 	}
 	s.config.token = token
 	s.config.channels = channels
+	s.config.includeDMs = includeDMs
+
+	s.config.indexFiles = true
+	if indexFiles, ok := config["index_files"].(bool); ok {
+		s.config.indexFiles = indexFiles
+	}
+
+	s.config.maxFileSize = defaultMaxFileSize
+	if size, ok := config["max_file_size"].(int); ok && size > 0 {
+		s.config.maxFileSize = size
+	}
+
+	s.config.history = defaultHistory
+	if history, ok := config["history"].(time.Duration); ok && history > 0 {
+		s.config.history = history
+	}
+
+	// Threads started within this window are checked for new replies on every sync
+	s.config.threadWindow = defaultThreadWindow
+	if window, ok := config["thread_window"].(time.Duration); ok && window > 0 {
+		s.config.threadWindow = window
+	}
 
 	return s, nil
 }
@@ -43,132 +131,300 @@ func (s *SlackSource) Name() string {
 }
 
 func (s *SlackSource) Initialize(ctx context.Context) error {
-	_, err := s.client.AuthTest()
-	return err
+	auth, err := s.client.AuthTestContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Permalinks are built from the workspace URL, e.g. https://acme.slack.com/
+	s.workspaceURL = auth.URL
+	return nil
 }
 
+// FetchData indexes each configured channel, and DMs when enabled, one document
+// per thread or standalone message. A zero since reads the full history window;
+// otherwise only messages after the last one seen, plus recent messages that
+// were edited, deleted or received replies, are returned.
 func (s *SlackSource) FetchData(ctx context.Context, since time.Time) ([]datasources.Document, error) {
+	channels, err := s.conversations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	var docs []datasources.Document
+	for _, channel := range channels {
+		channelDocs, err := s.fetchChannel(ctx, channel, since.IsZero())
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch %s: %w", s.channelLabel(ctx, channel), err)
+		}
+		docs = append(docs, channelDocs...)
+	}
+
+	s.pruneFiles()
+	return docs, nil
+}
+
+// conversations resolves the configured channels and, when enabled, lists the DMs
+// and group DMs the token can read
+func (s *SlackSource) conversations(ctx context.Context) ([]slack.Channel, error) {
+	var channels []slack.Channel
 
 	for _, channelName := range s.config.channels {
-		channel, err := s.findChannel(channelName)
+		channelName = strings.TrimPrefix(strings.TrimSpace(channelName), "#")
+		if channelName == "" {
+			continue
+		}
+
+		channel, err := s.directory.channel(ctx, channelName)
 		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			log.Printf("Skipping Slack channel %s: %v", channelName, err)
 			continue
 		}
+		channels = append(channels, channel)
+	}
 
-		params := slack.GetConversationHistoryParameters{
-			ChannelID: channel.ID,
-			Oldest:    fmt.Sprintf("%d", time.Date(2024, time.June, 10, 23, 0, 0, 0, time.UTC).Unix()),
-			Inclusive: true,
+	if s.config.includeDMs {
+		dms, err := s.directory.directMessages(ctx)
+		if err != nil {
+			return nil, err
 		}
+		channels = append(channels, dms...)
+	}
 
-		for {
-			history, err := s.client.GetConversationHistory(&params)
-			if err != nil {
-				break
+	return channels, nil
+}
+
+// fetchChannel reads a channel's history and builds its thread and message documents
+func (s *SlackSource) fetchChannel(ctx context.Context, channel slack.Channel, full bool) ([]datasources.Document, error) {
+	s.mu.Lock()
+	state, ok := s.state[channel.ID]
+	if !ok || full {
+		state = &channelState{threads: make(map[string]string), messages: make(map[string]bool)}
+		s.state[channel.ID] = state
+	}
+	lastTS := state.lastTS
+	s.mu.Unlock()
+
+	oldest := time.Now().Add(-s.config.history)
+	if lastTS != "" {
+		oldest = slackTime(lastTS).Add(-s.config.threadWindow)
+	}
+
+	params := &slack.GetConversationHistoryParameters{
+		ChannelID: channel.ID,
+		Oldest:    slackTS(oldest),
+		Limit:     200,
+	}
+
+	var docs []datasources.Document
+	newest := lastTS
+	threads := make(map[string]string)
+	present := make(map[string]bool)
+
+	for {
+		history, err := s.client.GetConversationHistoryContext(ctx, params)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get history: %w", err)
+		}
+
+		for _, msg := range history.Messages {
+			if ignoredSubtypes[msg.SubType] {
+				continue
+			}
+			// Replies broadcast to the channel are indexed with their thread
+			if msg.ThreadTimestamp != "" && msg.ThreadTimestamp != msg.Timestamp {
+				continue
+			}
+			if tsAfter(msg.Timestamp, newest) {
+				newest = msg.Timestamp
+			}
+			present[msg.Timestamp] = true
+
+			if msg.ReplyCount == 0 {
+				docs = append(docs, s.threadDocument(ctx, channel, msg, nil))
+				continue
 			}
 
-			for _, msg := range history.Messages {
-				timestamp, err := parseSlackTimestamp(msg.Timestamp)
-				if err != nil {
-					continue
-				}
-
-				content := fmt.Sprintf("Channel: %s\nUser: %s\nMessage: %s",
-					channelName,
-					msg.User,
-					msg.Text,
-				)
-
-				// Get thread replies if they exist
-				if msg.ThreadTimestamp != "" {
-					var allReplies []slack.Message
-					params := &slack.GetConversationRepliesParameters{
-						ChannelID: channel.ID,
-						Timestamp: msg.ThreadTimestamp,
-					}
-
-					for {
-						replies, hasMore, nextCursor, err := s.client.GetConversationReplies(params)
-						if err != nil {
-							break
-						}
-
-						allReplies = append(allReplies, replies...)
-
-						if !hasMore {
-							break
-						}
-						params.Cursor = nextCursor
-					}
-
-					if len(allReplies) > 0 {
-						content += "\n\nThread Replies:\n"
-						for _, reply := range allReplies {
-							if reply.Timestamp != msg.Timestamp {
-								content += fmt.Sprintf("- %s: %s\n", reply.User, reply.Text)
-							}
-						}
-					}
-				}
-
-				doc := datasources.Document{
-					ID:      msg.Timestamp,
-					Content: content,
-					Metadata: map[string]interface{}{
-						"channel":     channelName,
-						"user":        msg.User,
-						"has_thread":  msg.ThreadTimestamp != "",
-						"reactions":   msg.Reactions,
-						"message_url": s.createMessageLink(channel.ID, msg.Timestamp),
-					},
-					Source:    s.Name(),
-					Timestamp: timestamp,
-				}
-
-				docs = append(docs, doc)
+			signature := threadSignature(msg)
+			threads[msg.Timestamp] = signature
+
+			s.mu.Lock()
+			known := state.threads[msg.Timestamp]
+			s.mu.Unlock()
+			if !full && known == signature && !tsAfter(msg.Timestamp, lastTS) {
+				continue
 			}
 
-			if !history.HasMore {
-				break
+			_, replies, err := s.threadMessages(ctx, channel.ID, msg.Timestamp)
+			if err != nil {
+				return nil, err
 			}
-			params.Cursor = history.ResponseMetaData.NextCursor
+			docs = append(docs, s.threadDocument(ctx, channel, msg, replies))
+		}
+
+		if !history.HasMore {
+			break
 		}
+		params.Cursor = history.ResponseMetaData.NextCursor
 	}
 
+	// Messages seen before inside the window that are gone now were deleted.
+	// Older ones are no longer listed, so they are forgotten but stay indexed.
+	s.mu.Lock()
+	for ts := range state.messages {
+		if slackTime(ts).Before(oldest) {
+			delete(state.messages, ts)
+			continue
+		}
+		if present[ts] {
+			continue
+		}
+		delete(state.messages, ts)
+		docs = append(docs, datasources.Document{
+			ID:      messageID(channel.ID, ts),
+			Source:  s.Name(),
+			Deleted: true,
+		})
+	}
+	for ts := range present {
+		state.messages[ts] = true
+	}
+
+	// Threads older than the window are no longer checked, so drop their state
+	state.lastTS = newest
+	state.threads = threads
+	s.mu.Unlock()
+
 	return docs, nil
 }
 
-func (s *SlackSource) findChannel(channelName string) (*slack.Channel, error) {
-	channels, _, err := s.client.GetConversations(&slack.GetConversationsParameters{
-		ExcludeArchived: true,
-		Types:           []string{"public_channel", "private_channel"},
-	})
-	if err != nil {
-		return nil, err
+// threadMessages returns the first message of a thread and its replies. For a
+// message without replies, only the message itself is returned.
+func (s *SlackSource) threadMessages(ctx context.Context, channelID, threadTS string) (slack.Message, []slack.Message, error) {
+	var root slack.Message
+	var replies []slack.Message
+	params := &slack.GetConversationRepliesParameters{
+		ChannelID: channelID,
+		Timestamp: threadTS,
+		Limit:     200,
 	}
 
-	for _, channel := range channels {
-		if channel.Name == channelName {
-			return &channel, nil
+	for {
+		page, hasMore, nextCursor, err := s.client.GetConversationRepliesContext(ctx, params)
+		if err != nil {
+			return root, nil, fmt.Errorf("failed to get thread replies: %w", err)
+		}
+
+		for _, msg := range page {
+			if msg.Timestamp == threadTS {
+				root = msg
+			} else {
+				replies = append(replies, msg)
+			}
 		}
+
+		if !hasMore {
+			break
+		}
+		params.Cursor = nextCursor
 	}
 
-	return nil, fmt.Errorf("channel %s not found", channelName)
+	return root, replies, nil
+}
+
+// threadSignature changes whenever a thread gains or loses a reply or its first
+// message is edited. Editing a reply leaves the root message untouched, so polling
+// doesn't see it; reply edits arrive through the Events API, or with a full sync.
+func threadSignature(msg slack.Message) string {
+	signature := fmt.Sprintf("%s/%d", msg.LatestReply, msg.ReplyCount)
+	if msg.Edited != nil {
+		signature += "/" + msg.Edited.Timestamp
+	}
+	return signature
+}
+
+// slackTime converts a Slack message timestamp ("1718060400.123456") to a time
+func slackTime(ts string) time.Time {
+	sec, frac, _ := strings.Cut(ts, ".")
+	s, err := strconv.ParseInt(sec, 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+
+	frac = (frac + "000000")[:6]
+	us, _ := strconv.ParseInt(frac, 10, 64)
+	return time.Unix(s, us*int64(time.Microsecond))
+}
+
+// slackTS formats a time as a Slack timestamp
+func slackTS(t time.Time) string {
+	return fmt.Sprintf("%d.%06d", t.Unix(), t.Nanosecond()/int(time.Microsecond))
+}
+
+// tsAfter reports whether Slack timestamp a is later than b. An empty b is before everything.
+func tsAfter(a, b string) bool {
+	if b == "" {
+		return a != ""
+	}
+	return slackTime(a).After(slackTime(b))
 }
 
-func parseSlackTimestamp(ts string) (time.Time, error) {
-	sec := int64(0)
-	nsec := int64(0)
+// fileText downloads a shared file or snippet and extracts its text. Results are
+// cached so files in recent messages aren't downloaded on every sync.
+func (s *SlackSource) fileText(ctx context.Context, file slack.File) string {
+	if !s.config.indexFiles || file.IsExternal || !extract.Supported(file.Mimetype) {
+		return ""
+	}
+	if file.Size > s.config.maxFileSize {
+		log.Printf("Skipping Slack file %s: %d bytes exceeds the %d byte limit", file.Name, file.Size, s.config.maxFileSize)
+		return ""
+	}
 
-	_, err := fmt.Sscanf(ts, "%d.%d", &sec, &nsec)
+	s.mu.Lock()
+	cached, ok := s.files[file.ID]
+	if ok {
+		cached.used = time.Now()
+		s.files[file.ID] = cached
+	}
+	s.mu.Unlock()
+	if ok {
+		return cached.text
+	}
+
+	url := file.URLPrivateDownload
+	if url == "" {
+		url = file.URLPrivate
+	}
+
+	var buf bytes.Buffer
+	if err := s.client.GetFileContext(ctx, url, &buf); err != nil {
+		log.Printf("Failed to download Slack file %s: %v", file.Name, err)
+		return ""
+	}
+
+	text, err := extract.Text(file.Mimetype, buf.Bytes())
 	if err != nil {
-		return time.Time{}, err
+		log.Printf("Failed to extract Slack file %s: %v", file.Name, err)
 	}
 
-	return time.Unix(sec, nsec), nil
+	s.mu.Lock()
+	s.files[file.ID] = cachedFile{text: text, used: time.Now()}
+	s.mu.Unlock()
+	return text
 }
 
-func (s *SlackSource) createMessageLink(channelID, timestamp string) string {
-	return fmt.Sprintf("https://slack.com/archives/%s/p%s", channelID, timestamp)
+// pruneFiles drops cached file text that hasn't been used within the thread window
+func (s *SlackSource) pruneFiles() {
+	cutoff := time.Now().Add(-s.config.threadWindow)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, file := range s.files {
+		if file.used.Before(cutoff) {
+			delete(s.files, id)
+		}
+	}
 }
@@ -0,0 +1,50 @@
+package slack
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/slack-go/slack"
+)
+
+func TestFetchChannelForgetsOldMessages(t *testing.T) {
+	handler, api, _ := newTestHandler(t)
+	s := handler.source
+
+	now := time.Now()
+	recent := slackTS(now.Add(-time.Hour))
+	deleted := slackTS(now.Add(-2 * time.Hour))
+	old := slackTS(now.Add(-60 * 24 * time.Hour))
+
+	api.history = []map[string]interface{}{
+		{"type": "message", "user": "U0001", "ts": recent, "text": "Standup moved to 10"},
+	}
+	s.state["C0001"] = &channelState{
+		lastTS:   recent,
+		threads:  make(map[string]string),
+		messages: map[string]bool{recent: true, deleted: true, old: true},
+	}
+
+	docs, err := s.fetchChannel(context.Background(), slack.Channel{GroupConversation: slack.GroupConversation{
+		Conversation: slack.Conversation{ID: "C0001"}, Name: "general",
+	}}, false)
+	if err != nil {
+		t.Fatalf("fetchChannel() error = %v", err)
+	}
+
+	var removed []string
+	for _, doc := range docs {
+		if doc.Deleted {
+			removed = append(removed, doc.ID)
+		}
+	}
+	if len(removed) != 1 || removed[0] != messageID("C0001", deleted) {
+		t.Errorf("deleted %v, want only the message missing inside the window", removed)
+	}
+
+	state := s.state["C0001"]
+	if len(state.messages) != 1 || !state.messages[recent] {
+		t.Errorf("messages = %v, want only %s", state.messages, recent)
+	}
+}
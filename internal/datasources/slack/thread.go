@@ -0,0 +1,227 @@
+package slack
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/slack-go/slack"
+
+	"github.com/michaelgalloway/sophia/internal/datasources"
+)
+
+// maxTitleLength bounds the message excerpt used as a document title
+const maxTitleLength = 80
+
+// slackLink matches Slack's markup for mentions, channel references and links:
+// <@U123>, <#C123|general>, <!here>, <https://example.com|label>
+var slackLink = regexp.MustCompile(`<([@#!]?)([^>|]+)(?:\|([^>]*))?>`)
+
+// threadDocument builds one document for a thread, or for a message without
+// replies, with every message attributed to its author
+func (s *SlackSource) threadDocument(ctx context.Context, channel slack.Channel, root slack.Message, replies []slack.Message) datasources.Document {
+	label := s.channelLabel(ctx, channel)
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Channel: %s\n\n", label))
+
+	var participants []string
+	var participantIDs []string
+	seen := make(map[string]bool)
+	mentions := make(map[string]bool)
+	latest := slackTime(root.Timestamp)
+
+	var files []string
+	var fileSections []string
+
+	messages := append([]slack.Message{root}, replies...)
+	for i, msg := range messages {
+		author := s.author(ctx, msg)
+		if !seen[author] {
+			seen[author] = true
+			participants = append(participants, author)
+			if msg.User != "" {
+				participantIDs = append(participantIDs, msg.User)
+			}
+		}
+
+		text := s.formatText(ctx, msg.Text, mentions)
+		when := slackTime(msg.Timestamp)
+		if when.After(latest) {
+			latest = when
+		}
+
+		prefix := ""
+		if i > 0 {
+			prefix = "  ↳ "
+		}
+		sb.WriteString(fmt.Sprintf("%s%s [%s]: %s\n", prefix, author, when.Format("2006-01-02 15:04"), text))
+
+		for _, file := range msg.Files {
+			name := file.Title
+			if name == "" {
+				name = file.Name
+			}
+			sb.WriteString(fmt.Sprintf("%s  [shared file: %s]\n", prefix, name))
+			files = append(files, name)
+
+			if fileText := s.fileText(ctx, file); fileText != "" {
+				fileSections = append(fileSections, fmt.Sprintf("File: %s\n\n%s", name, fileText))
+			}
+		}
+	}
+
+	for _, section := range fileSections {
+		sb.WriteString("\n" + section + "\n")
+	}
+
+	var mentionIDs []string
+	for id := range mentions {
+		mentionIDs = append(mentionIDs, id)
+	}
+
+	reactions := make(map[string]int)
+	for _, reaction := range root.Reactions {
+		reactions[reaction.Name] = reaction.Count
+	}
+
+	url := s.permalink(channel.ID, root.Timestamp)
+
+	return datasources.Document{
+		ID:      messageID(channel.ID, root.Timestamp),
+		Content: strings.TrimSpace(sb.String()),
+		Title:   fmt.Sprintf("%s: %s", label, excerpt(s.formatText(ctx, root.Text, nil))),
+		URL:     url,
+		Metadata: map[string]interface{}{
+			"channel":         label,
+			"channel_id":      channel.ID,
+			"channel_type":    channelType(channel),
+			"user":            s.author(ctx, root),
+			"user_id":         root.User,
+			"participants":    participants,
+			"participant_ids": participantIDs,
+			"mentions":        mentionIDs,
+			"has_thread":      len(replies) > 0,
+			"reply_count":     len(replies),
+			"thread_ts":       root.Timestamp,
+			"last_activity":   latest.Format(time.RFC3339),
+			"reactions":       reactions,
+			"files":           files,
+			"edited":          root.Edited != nil,
+			"message_url":     url,
+		},
+		Source:    s.Name(),
+		Timestamp: latest,
+	}
+}
+
+// messageID namespaces a message timestamp by its channel, since timestamps are
+// only unique within a channel
+func messageID(channelID, ts string) string {
+	return channelID + ":" + ts
+}
+
+// channelLabel names a conversation the way Slack displays it
+func (s *SlackSource) channelLabel(ctx context.Context, channel slack.Channel) string {
+	switch {
+	case channel.IsIM:
+		return "DM with " + s.directory.userName(ctx, channel.User)
+	case channel.IsMpIM:
+		// Group DMs are named like mpdm-alice--bob--carol-1
+		name := strings.TrimPrefix(channel.Name, "mpdm-")
+		if i := strings.LastIndex(name, "-"); i > 0 {
+			name = name[:i]
+		}
+		return "Group DM with " + strings.Join(strings.Split(name, "--"), ", ")
+	}
+	return "#" + channel.Name
+}
+
+// channelType reports whether a conversation is a channel, DM or group DM
+func channelType(channel slack.Channel) string {
+	switch {
+	case channel.IsIM:
+		return "im"
+	case channel.IsMpIM:
+		return "mpim"
+	case channel.IsPrivate:
+		return "private_channel"
+	}
+	return "public_channel"
+}
+
+// author returns the display name of a message's sender
+func (s *SlackSource) author(ctx context.Context, msg slack.Message) string {
+	switch {
+	case msg.User != "":
+		return s.directory.userName(ctx, msg.User)
+	case msg.BotProfile != nil && msg.BotProfile.Name != "":
+		return msg.BotProfile.Name
+	case msg.Username != "":
+		return msg.Username
+	case msg.BotID != "":
+		return msg.BotID
+	}
+	return "unknown"
+}
+
+// formatText replaces Slack markup with readable names and links. User IDs
+// mentioned in the text are added to mentions when it is non-nil.
+func (s *SlackSource) formatText(ctx context.Context, text string, mentions map[string]bool) string {
+	text = slackLink.ReplaceAllStringFunc(text, func(match string) string {
+		parts := slackLink.FindStringSubmatch(match)
+		kind, target, label := parts[1], parts[2], parts[3]
+
+		switch kind {
+		case "@":
+			if mentions != nil {
+				mentions[target] = true
+			}
+			if label != "" {
+				return "@" + label
+			}
+			return "@" + s.directory.userName(ctx, target)
+		case "#":
+			if label != "" {
+				return "#" + label
+			}
+			if channel, ok := s.directory.channelByID(target); ok {
+				return s.channelLabel(ctx, channel)
+			}
+			return "#" + target
+		case "!":
+			if label != "" {
+				return label
+			}
+			return "@" + strings.TrimPrefix(target, "subteam^")
+		default:
+			if label != "" && label != target {
+				return fmt.Sprintf("%s (%s)", label, target)
+			}
+			return strings.TrimPrefix(target, "mailto:")
+		}
+	})
+	return html.UnescapeString(text)
+}
+
+// excerpt returns the first line of text, shortened for use as a title
+func excerpt(text string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(text), "\n")
+	if len([]rune(line)) > maxTitleLength {
+		line = string([]rune(line)[:maxTitleLength-1]) + "…"
+	}
+	return line
+}
+
+// permalink links to a message in the workspace. Slack permalinks use the
+// message timestamp without its decimal point, e.g. p1718060400123456.
+func (s *SlackSource) permalink(channelID, timestamp string) string {
+	base := s.workspaceURL
+	if base == "" {
+		base = "https://slack.com/"
+	}
+	return fmt.Sprintf("%sarchives/%s/p%s", strings.TrimSuffix(base, "/")+"/", channelID, strings.Replace(timestamp, ".", "", 1))
+}
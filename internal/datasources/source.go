@@ -2,6 +2,8 @@ package datasources
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"time"
 )
 
@@ -14,6 +16,23 @@ type Document struct {
 	Metadata  map[string]interface{}
 	Source    string
 	Timestamp time.Time
+
+	// Deleted marks a document that no longer exists at the source. Only ID and
+	// Source need to be set; the document and all of its chunks are removed.
+	Deleted bool
+
+	// ParentID, ChunkIndex and ChunkCount identify a chunk of a larger document.
+	// They are set by Chunk; sources leave them empty.
+	ParentID   string
+	ChunkIndex int
+	ChunkCount int
+}
+
+// ContentHash returns a fingerprint of the text that gets embedded, used to skip
+// re-embedding documents whose content hasn't changed
+func (d Document) ContentHash() string {
+	sum := sha256.Sum256([]byte(d.Content))
+	return hex.EncodeToString(sum[:])
 }
 
 // DataSource defines the interface that all data sources must implement
@@ -21,7 +40,8 @@ type DataSource interface {
 	// Name returns the unique identifier for this data source
 	Name() string
 
-	// FetchData retrieves new data since the last sync
+	// FetchData retrieves new data since the last sync. A zero since asks for a full sync.
+	// Sources that can detect removals return them as documents with Deleted set.
 	FetchData(ctx context.Context, since time.Time) ([]Document, error)
 
 	// Initialize sets up any necessary connections or authentication
@@ -31,14 +51,6 @@ type DataSource interface {
 // SourceFactory is a function type that creates new DataSource instances
 type SourceFactory func(config map[string]interface{}) (DataSource, error)
 
-// MaxContentLength is the maximum number of characters we'll allow for document content
+// MaxContentLength is the maximum number of characters we'll embed as a single chunk
 // This is a conservative estimate to stay within OpenAI's 8192 token limit
 const MaxContentLength = 6000
-
-// TruncateContent ensures content stays within reasonable token limits
-func TruncateContent(content string) string {
-	if len(content) <= MaxContentLength {
-		return content
-	}
-	return content[:MaxContentLength] + "... (truncated)"
-}
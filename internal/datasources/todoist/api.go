@@ -0,0 +1,308 @@
+package todoist
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/michaelgalloway/sophia/internal/retry"
+)
+
+// DefaultBaseURL is the Todoist API that both the source and task actions talk to
+const DefaultBaseURL = "https://api.todoist.com/api/v1"
+
+// syncResourceTypes are the resources requested from the Sync endpoint
+var syncResourceTypes = `["items","projects","sections","labels","notes"]`
+
+// Client is a minimal Todoist API client
+type Client struct {
+	http    *http.Client
+	baseURL string
+	token   string
+}
+
+// NewClient creates a client for the API at baseURL, or DefaultBaseURL when empty
+func NewClient(baseURL, token string) *Client {
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	return &Client{
+		http:    &http.Client{Timeout: time.Minute},
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		token:   token,
+	}
+}
+
+// Task is a Todoist task, called an item by the Sync API
+type Task struct {
+	ID          string   `json:"id"`
+	Content     string   `json:"content"`
+	Description string   `json:"description"`
+	ProjectID   string   `json:"project_id"`
+	SectionID   string   `json:"section_id"`
+	ParentID    string   `json:"parent_id"`
+	Labels      []string `json:"labels"`
+	Priority    int      `json:"priority"`
+	Due         *Due     `json:"due"`
+	Checked     bool     `json:"checked"`
+	IsDeleted   bool     `json:"is_deleted"`
+	AddedAt     string   `json:"added_at"`
+	UpdatedAt   string   `json:"updated_at"`
+	CompletedAt string   `json:"completed_at"`
+}
+
+// Due describes when a task is due. Date is "2006-01-02" for all-day tasks and
+// carries the time for timed ones, ending in Z when the time is fixed to
+// Timezone rather than floating with the user. String holds the natural
+// language form, such as "every monday", for recurring tasks.
+type Due struct {
+	Date        string `json:"date"`
+	String      string `json:"string"`
+	Timezone    string `json:"timezone,omitempty"`
+	IsRecurring bool   `json:"is_recurring"`
+}
+
+// Day returns the due date without its time
+func (d *Due) Day() string {
+	day, _, _ := strings.Cut(d.Date, "T")
+	return day
+}
+
+// Datetime returns the due date and time, or "" for all-day tasks
+func (d *Due) Datetime() string {
+	if !strings.Contains(d.Date, "T") {
+		return ""
+	}
+	return d.Date
+}
+
+type Project struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	IsDeleted  bool   `json:"is_deleted"`
+	IsArchived bool   `json:"is_archived"`
+}
+
+type Section struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	ProjectID string `json:"project_id"`
+	IsDeleted bool   `json:"is_deleted"`
+}
+
+type Label struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	IsDeleted bool   `json:"is_deleted"`
+}
+
+// Note is a comment on a task
+type Note struct {
+	ID        string `json:"id"`
+	ItemID    string `json:"item_id"`
+	Content   string `json:"content"`
+	PostedAt  string `json:"posted_at"`
+	IsDeleted bool   `json:"is_deleted"`
+}
+
+// SyncResponse holds the resources changed since the previous sync token
+type SyncResponse struct {
+	SyncToken string    `json:"sync_token"`
+	FullSync  bool      `json:"full_sync"`
+	Items     []Task    `json:"items"`
+	Projects  []Project `json:"projects"`
+	Sections  []Section `json:"sections"`
+	Labels    []Label   `json:"labels"`
+	Notes     []Note    `json:"notes"`
+}
+
+// Sync returns everything changed since syncToken. Use "*" for a full sync.
+func (c *Client) Sync(ctx context.Context, syncToken string) (*SyncResponse, error) {
+	form := url.Values{
+		"sync_token":     {syncToken},
+		"resource_types": {syncResourceTypes},
+	}
+
+	var resp SyncResponse
+	if err := c.do(ctx, http.MethodPost, "/sync", form, &resp); err != nil {
+		return nil, fmt.Errorf("failed to sync: %w", err)
+	}
+	return &resp, nil
+}
+
+// Completed returns tasks completed between since and until
+func (c *Client) Completed(ctx context.Context, since, until time.Time) ([]Task, error) {
+	var tasks []Task
+	cursor := ""
+
+	for {
+		query := url.Values{
+			"since": {since.UTC().Format(time.RFC3339)},
+			"until": {until.UTC().Format(time.RFC3339)},
+			"limit": {"200"},
+		}
+		if cursor != "" {
+			query.Set("cursor", cursor)
+		}
+
+		var page struct {
+			Items      []Task `json:"items"`
+			NextCursor string `json:"next_cursor"`
+		}
+		if err := c.do(ctx, http.MethodGet, "/tasks/completed/by_completion_date?"+query.Encode(), nil, &page); err != nil {
+			return nil, fmt.Errorf("failed to list completed tasks: %w", err)
+		}
+
+		tasks = append(tasks, page.Items...)
+		if page.NextCursor == "" {
+			return tasks, nil
+		}
+		cursor = page.NextCursor
+	}
+}
+
+// TaskInput holds the fields to set when creating or updating a task. Empty
+// fields are left out, so an update only changes what is given. ProjectID is
+// only read on creation; use MoveTask for existing tasks.
+type TaskInput struct {
+	Content     string   `json:"content,omitempty"`
+	Description string   `json:"description,omitempty"`
+	ProjectID   string   `json:"project_id,omitempty"`
+	Labels      []string `json:"labels,omitempty"`
+	Priority    int      `json:"priority,omitempty"`
+	DueString   string   `json:"due_string,omitempty"`
+}
+
+// Task returns an active task by ID
+func (c *Client) Task(ctx context.Context, id string) (*Task, error) {
+	var task Task
+	if err := c.do(ctx, http.MethodGet, "/tasks/"+url.PathEscape(id), nil, &task); err != nil {
+		return nil, fmt.Errorf("failed to get task %s: %w", id, err)
+	}
+	return &task, nil
+}
+
+// CreateTask adds a task and returns it as stored
+func (c *Client) CreateTask(ctx context.Context, input TaskInput) (*Task, error) {
+	var task Task
+	if err := c.doJSON(ctx, http.MethodPost, "/tasks", input, &task); err != nil {
+		return nil, fmt.Errorf("failed to create task: %w", err)
+	}
+	return &task, nil
+}
+
+// UpdateTask changes the given fields of a task and returns it as stored
+func (c *Client) UpdateTask(ctx context.Context, id string, input TaskInput) (*Task, error) {
+	var task Task
+	if err := c.doJSON(ctx, http.MethodPost, "/tasks/"+url.PathEscape(id), input, &task); err != nil {
+		return nil, fmt.Errorf("failed to update task %s: %w", id, err)
+	}
+	return &task, nil
+}
+
+// MoveTask moves a task to another project, which updating it cannot do
+func (c *Client) MoveTask(ctx context.Context, id, projectID string) error {
+	body := map[string]string{"project_id": projectID}
+	if err := c.doJSON(ctx, http.MethodPost, "/tasks/"+url.PathEscape(id)+"/move", body, nil); err != nil {
+		return fmt.Errorf("failed to move task %s: %w", id, err)
+	}
+	return nil
+}
+
+// CloseTask completes a task. Recurring tasks move to their next occurrence.
+func (c *Client) CloseTask(ctx context.Context, id string) error {
+	if err := c.do(ctx, http.MethodPost, "/tasks/"+url.PathEscape(id)+"/close", nil, nil); err != nil {
+		return fmt.Errorf("failed to close task %s: %w", id, err)
+	}
+	return nil
+}
+
+// Projects lists the account's active projects
+func (c *Client) Projects(ctx context.Context) ([]Project, error) {
+	var projects []Project
+	cursor := ""
+
+	for {
+		path := "/projects?limit=200"
+		if cursor != "" {
+			path += "&cursor=" + url.QueryEscape(cursor)
+		}
+
+		var page struct {
+			Results    []Project `json:"results"`
+			NextCursor string    `json:"next_cursor"`
+		}
+		if err := c.do(ctx, http.MethodGet, path, nil, &page); err != nil {
+			return nil, fmt.Errorf("failed to list projects: %w", err)
+		}
+
+		projects = append(projects, page.Results...)
+		if page.NextCursor == "" {
+			return projects, nil
+		}
+		cursor = page.NextCursor
+	}
+}
+
+// doJSON sends in as a JSON request body and decodes the JSON response into out
+func (c *Client) doJSON(ctx context.Context, method, path string, in, out interface{}) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return fmt.Errorf("failed to encode request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set("Content-Type", "application/json")
+
+	return c.send(req, out)
+}
+
+// do sends a request with an optional form body and decodes the JSON response into out
+func (c *Client) do(ctx context.Context, method, path string, form url.Values, out interface{}) error {
+	var body io.Reader
+	if form != nil {
+		body = strings.NewReader(form.Encode())
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	if form != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+
+	return c.send(req, out)
+}
+
+// send executes a request and decodes a successful JSON response into out, if non-nil
+func (c *Client) send(req *http.Request, out interface{}) error {
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return retry.NewStatusError(resp)
+	}
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
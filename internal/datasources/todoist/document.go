@@ -0,0 +1,128 @@
+package todoist
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/michaelgalloway/sophia/internal/datasources"
+)
+
+// priorityLabels maps API priorities, where 4 is the most urgent, to the labels shown in the app
+var priorityLabels = map[int]string{
+	4: "P1 (urgent)",
+	3: "P2 (high)",
+	2: "P3 (medium)",
+	1: "P4 (normal)",
+}
+
+// taskDocument renders a task with its project, labels, schedule and comments
+func (t *TodoistSource) taskDocument(task Task) datasources.Document {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Task: %s\n", task.Content))
+	if task.Description != "" {
+		sb.WriteString(fmt.Sprintf("Description: %s\n", task.Description))
+	}
+
+	project := t.projectBy[task.ProjectID].Name
+	section := t.sectionBy[task.SectionID].Name
+	if project != "" {
+		sb.WriteString(fmt.Sprintf("Project: %s\n", project))
+	}
+	if section != "" {
+		sb.WriteString(fmt.Sprintf("Section: %s\n", section))
+	}
+	if parent, ok := t.tasks[task.ParentID]; ok {
+		sb.WriteString(fmt.Sprintf("Subtask of: %s\n", parent.Content))
+	}
+	if len(task.Labels) > 0 {
+		sb.WriteString(fmt.Sprintf("Labels: %s\n", strings.Join(task.Labels, ", ")))
+	}
+	if label, ok := priorityLabels[task.Priority]; ok && task.Priority > 1 {
+		sb.WriteString(fmt.Sprintf("Priority: %s\n", label))
+	}
+
+	if task.Due != nil {
+		due := task.Due.Date
+		if task.Due.IsRecurring && task.Due.String != "" {
+			sb.WriteString(fmt.Sprintf("Due: %s (repeats %s)\n", due, task.Due.String))
+		} else {
+			sb.WriteString(fmt.Sprintf("Due: %s\n", due))
+		}
+	}
+
+	if task.Checked {
+		if task.CompletedAt != "" {
+			sb.WriteString(fmt.Sprintf("Status: completed on %s\n", task.CompletedAt))
+		} else {
+			sb.WriteString("Status: completed\n")
+		}
+	} else {
+		sb.WriteString("Status: open\n")
+	}
+
+	notes := t.taskNotes(task.ID)
+	if len(notes) > 0 {
+		sb.WriteString("\nComments:\n")
+		for _, note := range notes {
+			sb.WriteString(fmt.Sprintf("- [%s] %s\n", note.PostedAt, note.Content))
+		}
+	}
+
+	metadata := map[string]interface{}{
+		"project_id":    task.ProjectID,
+		"project_name":  project,
+		"section_name":  section,
+		"labels":        task.Labels,
+		"priority":      task.Priority,
+		"completed":     task.Checked,
+		"completed_at":  task.CompletedAt,
+		"added_at":      task.AddedAt,
+		"parent_id":     task.ParentID,
+		"comment_count": len(notes),
+	}
+	if task.Due != nil {
+		metadata["due"] = task.Due.Day()
+		metadata["due_datetime"] = task.Due.Datetime()
+		metadata["is_recurring"] = task.Due.IsRecurring
+		if task.Due.IsRecurring {
+			metadata["recurrence"] = task.Due.String
+		}
+	}
+
+	return datasources.Document{
+		ID:        task.ID,
+		Content:   strings.TrimSpace(sb.String()),
+		Title:     task.Content,
+		URL:       fmt.Sprintf("https://app.todoist.com/app/task/%s", task.ID),
+		Source:    t.Name(),
+		Metadata:  metadata,
+		Timestamp: taskTime(task),
+	}
+}
+
+// taskNotes returns a task's comments, oldest first
+func (t *TodoistSource) taskNotes(taskID string) []Note {
+	var notes []Note
+	for _, note := range t.notes[taskID] {
+		notes = append(notes, note)
+	}
+	sort.Slice(notes, func(i, j int) bool {
+		return notes[i].PostedAt < notes[j].PostedAt
+	})
+	return notes
+}
+
+// taskTime dates a task by its completion, or its latest update for open tasks
+func taskTime(task Task) time.Time {
+	for _, s := range []string{task.CompletedAt, task.UpdatedAt, task.AddedAt} {
+		if s == "" {
+			continue
+		}
+		if ts, err := time.Parse(time.RFC3339, s); err == nil {
+			return ts
+		}
+	}
+	return time.Time{}
+}
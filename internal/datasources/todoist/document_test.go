@@ -0,0 +1,47 @@
+package todoist
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestTaskDocumentDue(t *testing.T) {
+	tests := []struct {
+		name         string
+		due          string
+		wantDue      string
+		wantDatetime string
+	}{
+		{name: "all day", due: `{"date": "2026-10-19", "string": "today"}`, wantDue: "2026-10-19"},
+		{
+			name:         "floating time",
+			due:          `{"date": "2026-10-19T12:00:00", "string": "today at 12"}`,
+			wantDue:      "2026-10-19",
+			wantDatetime: "2026-10-19T12:00:00",
+		},
+		{
+			name:         "fixed time",
+			due:          `{"date": "2026-10-19T10:00:00Z", "timezone": "Europe/Lisbon", "string": "today at 11"}`,
+			wantDue:      "2026-10-19",
+			wantDatetime: "2026-10-19T10:00:00Z",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var due Due
+			if err := json.Unmarshal([]byte(tt.due), &due); err != nil {
+				t.Fatalf("failed to decode due: %v", err)
+			}
+
+			source := &TodoistSource{}
+			doc := source.taskDocument(Task{ID: "6X7rM8997g3RQmvh", Content: "Call the bank", Due: &due})
+			if got := doc.Metadata["due"]; got != tt.wantDue {
+				t.Errorf("due = %v, want %q", got, tt.wantDue)
+			}
+			if got := doc.Metadata["due_datetime"]; got != tt.wantDatetime {
+				t.Errorf("due_datetime = %v, want %q", got, tt.wantDatetime)
+			}
+		})
+	}
+}
@@ -2,37 +2,33 @@ package todoist
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
-	"io"
-	"net/http"
-	"net/url"
+	"sync"
 	"time"
 
 	"github.com/michaelgalloway/sophia/internal/datasources"
 )
 
-type TodoistSource struct {
-	client *http.Client
-	token  string
-	filter string
-}
+// maxCompletedRange is the longest period the completed tasks endpoint accepts per request
+const maxCompletedRange = 90 * 24 * time.Hour
 
-type TodoistTask struct {
-	ID          string    `json:"id"`
-	Content     string    `json:"content"`
-	Description string    `json:"description"`
-	ProjectID   string    `json:"project_id"`
-	ProjectName string    `json:"project_name"`
-	Priority    int32     `json:"priority"`
-	Due         *DueDate  `json:"due"`
-	URL         string    `json:"url"`
-	CreatedAt   time.Time `json:"created_at"`
-}
+// defaultCompletedHistory is how far back completed tasks are read on a full sync
+const defaultCompletedHistory = 90 * 24 * time.Hour
 
-type DueDate struct {
-	Date     string `json:"date"`
-	Datetime string `json:"datetime"`
+// TodoistSource indexes tasks through the Sync API. The first sync loads every
+// active task and the recently completed ones; later syncs use the sync token to
+// fetch only what changed, including completions and deletions.
+type TodoistSource struct {
+	client           *Client
+	projects         map[string]bool // project names to index; empty means all
+	completedHistory time.Duration
+
+	mu        sync.Mutex
+	syncToken string
+	tasks     map[string]Task
+	projectBy map[string]Project
+	sectionBy map[string]Section
+	notes     map[string]map[string]Note // task ID → note ID → note
 }
 
 func New(config map[string]interface{}) (datasources.DataSource, error) {
@@ -41,16 +37,27 @@ func New(config map[string]interface{}) (datasources.DataSource, error) {
 		return nil, fmt.Errorf("token not provided in config")
 	}
 
-	filter, ok := config["filter"].(string)
-	if !ok {
-		return nil, fmt.Errorf("filter not provided in config")
+	baseURL, _ := config["base_url"].(string)
+
+	projects := make(map[string]bool)
+	if names, ok := config["projects"].([]string); ok {
+		for _, name := range names {
+			projects[name] = true
+		}
+	}
+
+	completedHistory := defaultCompletedHistory
+	if history, ok := config["completed_history"].(time.Duration); ok && history > 0 {
+		completedHistory = history
 	}
 
-	return &TodoistSource{
-		client: &http.Client{},
-		token:  token,
-		filter: filter,
-	}, nil
+	t := &TodoistSource{
+		client:           NewClient(baseURL, token),
+		projects:         projects,
+		completedHistory: completedHistory,
+	}
+	t.reset()
+	return t, nil
 }
 
 func (t *TodoistSource) Name() string {
@@ -61,72 +68,155 @@ func (t *TodoistSource) Initialize(ctx context.Context) error {
 	return nil
 }
 
-func (t *TodoistSource) FetchData(ctx context.Context, since time.Time) ([]datasources.Document, error) {
+// Client returns the API client, so actions can write to the same account
+func (t *TodoistSource) Client() *Client {
+	return t.client
+}
 
-	taskUrl := fmt.Sprintf("https://api.todoist.com/rest/v2/tasks?filter=%s", url.QueryEscape(t.filter))
-	req, err := http.NewRequestWithContext(ctx, "GET", taskUrl, nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+// FetchData returns tasks changed since the last sync. A zero since, or no sync
+// token yet, performs a full sync that also loads recently completed tasks.
+func (t *TodoistSource) FetchData(ctx context.Context, since time.Time) ([]datasources.Document, error) {
+	t.mu.Lock()
+	full := since.IsZero() || t.syncToken == ""
+	token := t.syncToken
+	t.mu.Unlock()
+	if full {
+		token = "*"
 	}
 
-	req.Header.Set("Authorization", "Bearer "+t.token)
-
-	resp, err := t.client.Do(req)
+	resp, err := t.client.Sync(ctx, token)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch tasks: %w", err)
+		return nil, err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("failed to fetch tasks: %s: %s", resp.Status, string(body))
+	var completed []Task
+	if full || resp.FullSync {
+		completed, err = t.completed(ctx, time.Now().Add(-t.completedHistory), time.Now())
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	var tasks []TodoistTask
-	if err := json.NewDecoder(resp.Body).Decode(&tasks); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if full || resp.FullSync {
+		t.reset()
 	}
+	changed := t.apply(resp)
+	for _, task := range completed {
+		task.Checked = true
+		t.tasks[task.ID] = task
+		changed[task.ID] = true
+	}
+	t.syncToken = resp.SyncToken
 
 	var docs []datasources.Document
-	for _, task := range tasks {
-		content := fmt.Sprintf("Task: %s\n", task.Content)
-		if task.Description != "" {
-			content += fmt.Sprintf("Description: %s\n", task.Description)
+	for id := range changed {
+		task, ok := t.tasks[id]
+		switch {
+		case !ok:
+			docs = append(docs, datasources.Document{ID: id, Source: t.Name(), Deleted: true})
+		case t.included(task):
+			docs = append(docs, t.taskDocument(task))
+		case !full:
+			// Moved out of the indexed projects
+			docs = append(docs, datasources.Document{ID: id, Source: t.Name(), Deleted: true})
 		}
-		if task.Priority != 0 {
-			content += fmt.Sprintf("Priority (higher is more important): %d\n", task.Priority)
+	}
+
+	return docs, nil
+}
+
+// completed lists completed tasks in a window, split into ranges the API accepts
+func (t *TodoistSource) completed(ctx context.Context, since, until time.Time) ([]Task, error) {
+	var tasks []Task
+	for start := since; start.Before(until); start = start.Add(maxCompletedRange) {
+		end := start.Add(maxCompletedRange)
+		if end.After(until) {
+			end = until
 		}
-		if task.Due != nil {
-			if task.Due.Datetime != "" {
-				content += fmt.Sprintf("Due: %s\n", task.Due.Datetime)
-			} else {
-				content += fmt.Sprintf("Due: %s\n", task.Due.Date)
-			}
+
+		page, err := t.client.Completed(ctx, start, end)
+		if err != nil {
+			return nil, err
 		}
-		if task.Due != nil {
-			if task.Due.Datetime != "" {
-				content += fmt.Sprintf("Due: %s\n", task.Due.Datetime)
-			} else {
-				content += fmt.Sprintf("Due: %s\n", task.Due.Date)
-			}
+		tasks = append(tasks, page...)
+	}
+	return tasks, nil
+}
+
+// reset clears the cached account state before a full sync
+func (t *TodoistSource) reset() {
+	t.syncToken = ""
+	t.tasks = make(map[string]Task)
+	t.projectBy = make(map[string]Project)
+	t.sectionBy = make(map[string]Section)
+	t.notes = make(map[string]map[string]Note)
+}
+
+// apply merges a sync response into the cache and returns the IDs of tasks
+// whose documents need rebuilding
+func (t *TodoistSource) apply(resp *SyncResponse) map[string]bool {
+	changed := make(map[string]bool)
+
+	renamedProjects := make(map[string]bool)
+	for _, project := range resp.Projects {
+		if project.IsDeleted {
+			delete(t.projectBy, project.ID)
+		} else {
+			t.projectBy[project.ID] = project
 		}
+		renamedProjects[project.ID] = true
+	}
 
-		doc := datasources.Document{
-			ID:      task.ID,
-			Content: datasources.TruncateContent(content),
-			Title:   task.Content,
-			URL:     task.URL,
-			Source:  t.Name(),
-			Metadata: map[string]interface{}{
-				"project_id":   task.ProjectID,
-				"project_name": task.ProjectName,
-				"due":          task.Due,
-			},
-			Timestamp: task.CreatedAt,
+	renamedSections := make(map[string]bool)
+	for _, section := range resp.Sections {
+		if section.IsDeleted {
+			delete(t.sectionBy, section.ID)
+		} else {
+			t.sectionBy[section.ID] = section
 		}
+		renamedSections[section.ID] = true
+	}
 
-		docs = append(docs, doc)
+	for _, task := range resp.Items {
+		if task.IsDeleted {
+			delete(t.tasks, task.ID)
+			delete(t.notes, task.ID)
+		} else {
+			t.tasks[task.ID] = task
+		}
+		changed[task.ID] = true
 	}
 
-	return docs, nil
+	for _, note := range resp.Notes {
+		if t.notes[note.ItemID] == nil {
+			t.notes[note.ItemID] = make(map[string]Note)
+		}
+		if note.IsDeleted {
+			delete(t.notes[note.ItemID], note.ID)
+		} else {
+			t.notes[note.ItemID][note.ID] = note
+		}
+		changed[note.ItemID] = true
+	}
+
+	// Tasks show the names of their project and section
+	for id, task := range t.tasks {
+		if renamedProjects[task.ProjectID] || renamedSections[task.SectionID] {
+			changed[id] = true
+		}
+	}
+
+	return changed
+}
+
+// included reports whether a task belongs to one of the indexed projects
+func (t *TodoistSource) included(task Task) bool {
+	if len(t.projects) == 0 {
+		return true
+	}
+	project, ok := t.projectBy[task.ProjectID]
+	return ok && t.projects[project.Name]
 }
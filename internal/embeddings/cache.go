@@ -0,0 +1,267 @@
+package embeddings
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"sync/atomic"
+
+	"github.com/michaelgalloway/sophia/internal/datasources"
+)
+
+// Cache persists embeddings keyed by model and a hash of the embedded text
+type Cache interface {
+	// Get returns the cached vectors for whichever of the hashes are present
+	Get(ctx context.Context, model string, hashes []string) (map[string]Vector, error)
+
+	// Put stores vectors by text hash
+	Put(ctx context.Context, model string, vectors map[string]Vector) error
+}
+
+// CacheStats reports cache effectiveness since startup
+type CacheStats struct {
+	Hits          int64 `json:"hits"`
+	Misses        int64 `json:"misses"`
+	QueryHits     int64 `json:"query_hits"`
+	QueryMisses   int64 `json:"query_misses"`
+	QueryEntries  int   `json:"query_entries"`
+	ProviderCalls int64 `json:"provider_calls"`
+}
+
+// CachedEmbedding is an EmbeddingService that checks a cache before calling the
+// wrapped service. Query embeddings are also kept in an in-memory LRU so repeated
+// questions are answered without touching the database or the provider.
+type CachedEmbedding struct {
+	next    EmbeddingService
+	cache   Cache
+	model   string
+	queries *lru
+
+	hits          atomic.Int64
+	misses        atomic.Int64
+	queryHits     atomic.Int64
+	queryMisses   atomic.Int64
+	providerCalls atomic.Int64
+}
+
+// NewCachedEmbedding wraps next with a persistent cache and a query LRU of queryCacheSize entries
+func NewCachedEmbedding(next EmbeddingService, cache Cache, model string, queryCacheSize int) *CachedEmbedding {
+	return &CachedEmbedding{
+		next:    next,
+		cache:   cache,
+		model:   model,
+		queries: newLRU(queryCacheSize),
+	}
+}
+
+// Stats returns hit and miss counts
+func (c *CachedEmbedding) Stats() CacheStats {
+	return CacheStats{
+		Hits:          c.hits.Load(),
+		Misses:        c.misses.Load(),
+		QueryHits:     c.queryHits.Load(),
+		QueryMisses:   c.queryMisses.Load(),
+		QueryEntries:  c.queries.len(),
+		ProviderCalls: c.providerCalls.Load(),
+	}
+}
+
+func (c *CachedEmbedding) CreateEmbedding(ctx context.Context, text string) (Vector, error) {
+	hash := textHash(text)
+	if vec, ok := c.lookup(ctx, hash); ok {
+		c.hits.Add(1)
+		return vec, nil
+	}
+	c.misses.Add(1)
+
+	c.providerCalls.Add(1)
+	vec, err := c.next.CreateEmbedding(ctx, text)
+	if err != nil {
+		return nil, err
+	}
+
+	c.store(ctx, map[string]Vector{hash: vec})
+	return vec, nil
+}
+
+func (c *CachedEmbedding) CreateEmbeddings(ctx context.Context, docs []datasources.Document) ([]Vector, error) {
+	hashes := make([]string, len(docs))
+	for i, doc := range docs {
+		hashes[i] = doc.ContentHash()
+	}
+
+	cached, err := c.cache.Get(ctx, c.model, hashes)
+	if err != nil {
+		log.Printf("Embedding cache lookup failed: %v", err)
+		cached = map[string]Vector{}
+	}
+
+	// Only send each distinct uncached text to the provider once
+	var missing []datasources.Document
+	pending := make(map[string]bool)
+	for i, doc := range docs {
+		if _, ok := cached[hashes[i]]; ok {
+			c.hits.Add(1)
+			continue
+		}
+		c.misses.Add(1)
+		if !pending[hashes[i]] {
+			pending[hashes[i]] = true
+			missing = append(missing, doc)
+		}
+	}
+
+	failedHashes := make(map[string]error)
+	if len(missing) > 0 {
+		c.providerCalls.Add(1)
+		vectors, err := c.next.CreateEmbeddings(ctx, missing)
+
+		var partial *PartialError
+		if err != nil && !errors.As(err, &partial) {
+			return nil, err
+		}
+		if partial != nil {
+			for i, failErr := range partial.Failed {
+				failedHashes[missing[i].ContentHash()] = failErr
+			}
+		}
+
+		fresh := make(map[string]Vector, len(vectors))
+		for i, vec := range vectors {
+			if vec == nil {
+				continue
+			}
+			hash := missing[i].ContentHash()
+			fresh[hash] = vec
+			cached[hash] = vec
+		}
+		c.store(ctx, fresh)
+	}
+
+	result := make([]Vector, len(docs))
+	failed := make(map[int]error)
+	for i := range docs {
+		if err, ok := failedHashes[hashes[i]]; ok {
+			failed[i] = err
+			continue
+		}
+		result[i] = cached[hashes[i]]
+	}
+
+	if len(failed) > 0 {
+		return result, &PartialError{Failed: failed}
+	}
+	return result, nil
+}
+
+// QueryEmbedding counts only in the query stats: a hit is a query answered from
+// the LRU or the persistent cache, a miss one that needed the provider
+func (c *CachedEmbedding) QueryEmbedding(ctx context.Context, query string) (Vector, error) {
+	hash := textHash(query)
+	if vec, ok := c.queries.get(hash); ok {
+		c.queryHits.Add(1)
+		return vec, nil
+	}
+
+	vec, ok := c.lookup(ctx, hash)
+	if ok {
+		c.queryHits.Add(1)
+	} else {
+		c.queryMisses.Add(1)
+		var err error
+		c.providerCalls.Add(1)
+		vec, err = c.next.QueryEmbedding(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+		c.store(ctx, map[string]Vector{hash: vec})
+	}
+
+	c.queries.add(hash, vec)
+	return vec, nil
+}
+
+// lookup reads a single vector from the persistent cache
+func (c *CachedEmbedding) lookup(ctx context.Context, hash string) (Vector, bool) {
+	cached, err := c.cache.Get(ctx, c.model, []string{hash})
+	if err != nil {
+		log.Printf("Embedding cache lookup failed: %v", err)
+	}
+	vec, ok := cached[hash]
+	return vec, ok
+}
+
+// store writes vectors to the persistent cache; failures only cost a future cache miss
+func (c *CachedEmbedding) store(ctx context.Context, vectors map[string]Vector) {
+	if err := c.cache.Put(ctx, c.model, vectors); err != nil {
+		log.Printf("Embedding cache write failed: %v", err)
+	}
+}
+
+// textHash returns the cache key for a piece of text, the same hash stored with documents
+func textHash(text string) string {
+	return datasources.Document{Content: text}.ContentHash()
+}
+
+// lru is a fixed-size, thread-safe least-recently-used vector cache
+type lru struct {
+	mu      sync.Mutex
+	size    int
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type lruEntry struct {
+	key string
+	vec Vector
+}
+
+func newLRU(size int) *lru {
+	return &lru{
+		size:    size,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+func (l *lru) get(key string) (Vector, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	elem, ok := l.entries[key]
+	if !ok {
+		return nil, false
+	}
+	l.order.MoveToFront(elem)
+	return elem.Value.(*lruEntry).vec, true
+}
+
+func (l *lru) add(key string, vec Vector) {
+	if l.size <= 0 {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if elem, ok := l.entries[key]; ok {
+		elem.Value.(*lruEntry).vec = vec
+		l.order.MoveToFront(elem)
+		return
+	}
+
+	l.entries[key] = l.order.PushFront(&lruEntry{key: key, vec: vec})
+	if l.order.Len() > l.size {
+		oldest := l.order.Back()
+		l.order.Remove(oldest)
+		delete(l.entries, oldest.Value.(*lruEntry).key)
+	}
+}
+
+func (l *lru) len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.order.Len()
+}
@@ -6,87 +6,187 @@ import (
 	"log"
 
 	"github.com/michaelgalloway/sophia/internal/datasources"
+	"github.com/michaelgalloway/sophia/internal/retry"
 	"github.com/sashabaranov/go-openai"
 )
 
+const (
+	// defaultMaxBatchTokens stays well under OpenAI's 300k tokens per embedding request
+	defaultMaxBatchTokens = 200000
+)
+
 type OpenAIEmbedding struct {
 	client    *openai.Client
 	modelName string
 	config    Config
+	retry     retry.Policy
+	limiter   *rateLimiter
 }
 
 func NewOpenAIEmbedding(config Config) *OpenAIEmbedding {
 	client := openai.NewClient(config.OpenAIKey)
+
+	modelName := config.ModelName
+	if modelName == "" {
+		modelName = string(openai.SmallEmbedding3)
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = 100
+	}
+	if config.MaxBatchTokens <= 0 {
+		config.MaxBatchTokens = defaultMaxBatchTokens
+	}
+
 	return &OpenAIEmbedding{
 		client:    client,
-		modelName: config.ModelName,
+		modelName: modelName,
 		config:    config,
+		retry:     config.retryPolicy(),
+		limiter:   newRateLimiter(config.RequestsPerMinute, config.TokensPerMinute),
 	}
 }
 
+// Model returns the name of the embedding model in use
+func (o *OpenAIEmbedding) Model() string {
+	return o.modelName
+}
+
 func (o *OpenAIEmbedding) CreateEmbedding(ctx context.Context, text string) (Vector, error) {
-	resp, err := o.client.CreateEmbeddings(ctx, openai.EmbeddingRequest{
-		Input: []string{text},
-		Model: openai.SmallEmbedding3,
-	})
+	vectors, err := o.request(ctx, []string{text})
 	if err != nil {
 		return nil, fmt.Errorf("failed to create embedding: %w", err)
 	}
-
-	if len(resp.Data) == 0 {
-		return nil, fmt.Errorf("no embedding data received")
-	}
-
-	// Convert []float32 to our Vector type
-	embedding := make(Vector, len(resp.Data[0].Embedding))
-	for i, v := range resp.Data[0].Embedding {
-		embedding[i] = float32(v)
-	}
-
-	return embedding, nil
+	return vectors[0], nil
 }
 
+// CreateEmbeddings embeds docs in batches bounded by both item count and estimated tokens.
+// A failed batch doesn't abort the others; its documents are reported in a *PartialError.
 func (o *OpenAIEmbedding) CreateEmbeddings(ctx context.Context, docs []datasources.Document) ([]Vector, error) {
-	var texts []string
-	for _, doc := range docs {
-		texts = append(texts, doc.Content)
+	texts := make([]string, len(docs))
+	for i, doc := range docs {
+		texts[i] = doc.Content
 	}
 
-	// Process in batches to respect API limits
-	batchSize := o.config.BatchSize
-	if batchSize == 0 {
-		batchSize = 100
-	}
+	vectors := make([]Vector, len(texts))
+	failed := make(map[int]error)
 
-	var allEmbeddings []Vector
-	for i := 0; i < len(texts); i += batchSize {
-		end := i + batchSize
-		if end > len(texts) {
-			end = len(texts)
+	for n, b := range o.batches(texts) {
+		batchVectors, err := o.embedBatch(ctx, texts[b.start:b.end])
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
 		}
-
-		batch := texts[i:end]
-		resp, err := o.client.CreateEmbeddings(ctx, openai.EmbeddingRequest{
-			Input: batch,
-			Model: openai.SmallEmbedding3,
-		})
 		if err != nil {
-			log.Printf("failed to create embeddings batch %d: %w", i/batchSize, err)
-			return nil, fmt.Errorf("failed to create embeddings batch %d: %w", i/batchSize, err)
+			log.Printf("failed to create embeddings batch %d: %v", n, err)
 		}
 
-		for _, data := range resp.Data {
-			embedding := make(Vector, len(data.Embedding))
-			for j, v := range data.Embedding {
-				embedding[j] = float32(v)
+		for i, vec := range batchVectors {
+			if vec == nil {
+				if err == nil {
+					err = fmt.Errorf("no embedding returned")
+				}
+				failed[b.start+i] = err
+				continue
 			}
-			allEmbeddings = append(allEmbeddings, embedding)
+			vectors[b.start+i] = vec
 		}
 	}
 
-	return allEmbeddings, nil
+	if len(failed) == len(texts) {
+		return nil, fmt.Errorf("failed to create embeddings: %w", (&PartialError{Failed: failed}).first())
+	}
+	if len(failed) > 0 {
+		return vectors, &PartialError{Failed: failed}
+	}
+	return vectors, nil
 }
 
 func (o *OpenAIEmbedding) QueryEmbedding(ctx context.Context, query string) (Vector, error) {
 	return o.CreateEmbedding(ctx, query)
 }
+
+// span is a half-open range of inputs sent in one request
+type span struct {
+	start, end int
+}
+
+// batches groups consecutive texts so that no request exceeds BatchSize items or MaxBatchTokens
+func (o *OpenAIEmbedding) batches(texts []string) []span {
+	var spans []span
+	start, tokens := 0, 0
+	for i, text := range texts {
+		t := EstimateTokens(text)
+		if i > start && (i-start >= o.config.BatchSize || tokens+t > o.config.MaxBatchTokens) {
+			spans = append(spans, span{start, i})
+			start, tokens = i, 0
+		}
+		tokens += t
+	}
+	if start < len(texts) {
+		spans = append(spans, span{start, len(texts)})
+	}
+	return spans
+}
+
+// embedBatch embeds texts, returning nil entries for inputs that failed. When the provider
+// rejects a whole batch outright, it is split in half to isolate the offending inputs.
+func (o *OpenAIEmbedding) embedBatch(ctx context.Context, texts []string) ([]Vector, error) {
+	vectors, err := o.request(ctx, texts)
+	if err == nil {
+		return vectors, nil
+	}
+	if len(texts) == 1 || retry.IsRetryable(err) || ctx.Err() != nil {
+		return make([]Vector, len(texts)), err
+	}
+
+	mid := len(texts) / 2
+	left, leftErr := o.embedBatch(ctx, texts[:mid])
+	right, rightErr := o.embedBatch(ctx, texts[mid:])
+	if leftErr == nil {
+		leftErr = rightErr
+	}
+	return append(left, right...), leftErr
+}
+
+// request sends one rate-limited, retried embedding request
+func (o *OpenAIEmbedding) request(ctx context.Context, texts []string) ([]Vector, error) {
+	tokens := 0
+	for _, text := range texts {
+		tokens += EstimateTokens(text)
+	}
+
+	var resp openai.EmbeddingResponse
+	err := retry.Do(ctx, o.retry, "create embeddings", func(ctx context.Context) error {
+		if err := o.limiter.Wait(ctx, tokens); err != nil {
+			return err
+		}
+		var err error
+		resp, err = o.client.CreateEmbeddings(ctx, openai.EmbeddingRequest{
+			Input: texts,
+			Model: openai.EmbeddingModel(o.modelName),
+		})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(resp.Data) != len(texts) {
+		return nil, fmt.Errorf("received %d embeddings for %d inputs", len(resp.Data), len(texts))
+	}
+
+	vectors := make([]Vector, len(texts))
+	for _, data := range resp.Data {
+		if data.Index < 0 || data.Index >= len(texts) {
+			return nil, fmt.Errorf("embedding index %d out of range", data.Index)
+		}
+		vectors[data.Index] = Vector(data.Embedding)
+	}
+	return vectors, nil
+}
+
+// EstimateTokens approximates the token count of text without a tokenizer. It assumes
+// about three characters per token, which overestimates typical English text and
+// leaves headroom for code, URLs and non-Latin scripts.
+func EstimateTokens(text string) int {
+	return len(text)/3 + 1
+}
@@ -0,0 +1,104 @@
+package embeddings
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// rateLimiter enforces requests-per-minute and tokens-per-minute budgets using
+// two continuously refilling buckets. A zero limit disables that bucket.
+type rateLimiter struct {
+	mu       sync.Mutex
+	requests bucket
+	tokens   bucket
+}
+
+type bucket struct {
+	capacity  float64
+	available float64
+	perSecond float64
+	updated   time.Time
+}
+
+func newRateLimiter(requestsPerMinute, tokensPerMinute int) *rateLimiter {
+	now := time.Now()
+	return &rateLimiter{
+		requests: newBucket(requestsPerMinute, now),
+		tokens:   newBucket(tokensPerMinute, now),
+	}
+}
+
+func newBucket(perMinute int, now time.Time) bucket {
+	return bucket{
+		capacity:  float64(perMinute),
+		available: float64(perMinute),
+		perSecond: float64(perMinute) / 60,
+		updated:   now,
+	}
+}
+
+// refill adds the budget accrued since the last update
+func (b *bucket) refill(now time.Time) {
+	if b.capacity <= 0 {
+		return
+	}
+	b.available += now.Sub(b.updated).Seconds() * b.perSecond
+	if b.available > b.capacity {
+		b.available = b.capacity
+	}
+	b.updated = now
+}
+
+// wait returns how long until n units are available
+func (b *bucket) wait(n float64) time.Duration {
+	if b.capacity <= 0 {
+		return 0
+	}
+	if n > b.capacity {
+		n = b.capacity
+	}
+	if b.available >= n {
+		return 0
+	}
+	return time.Duration((n - b.available) / b.perSecond * float64(time.Second))
+}
+
+// take consumes n units, allowing a single oversized request to drain the bucket
+func (b *bucket) take(n float64) {
+	if b.capacity <= 0 {
+		return
+	}
+	if n > b.capacity {
+		n = b.capacity
+	}
+	b.available -= n
+}
+
+// Wait blocks until one request carrying the given number of tokens fits in both budgets
+func (r *rateLimiter) Wait(ctx context.Context, tokens int) error {
+	for {
+		r.mu.Lock()
+		now := time.Now()
+		r.requests.refill(now)
+		r.tokens.refill(now)
+
+		delay := r.requests.wait(1)
+		if d := r.tokens.wait(float64(tokens)); d > delay {
+			delay = d
+		}
+		if delay == 0 {
+			r.requests.take(1)
+			r.tokens.take(float64(tokens))
+			r.mu.Unlock()
+			return nil
+		}
+		r.mu.Unlock()
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
@@ -2,8 +2,11 @@ package embeddings
 
 import (
 	"context"
-	
+	"fmt"
+	"time"
+
 	"github.com/michaelgalloway/sophia/internal/datasources"
+	"github.com/michaelgalloway/sophia/internal/retry"
 )
 
 // Vector represents an embedding vector
@@ -13,19 +16,56 @@ type Vector []float32
 type EmbeddingService interface {
 	// CreateEmbedding generates an embedding vector for the given text
 	CreateEmbedding(ctx context.Context, text string) (Vector, error)
-	
-	// CreateEmbeddings generates embedding vectors for multiple documents
+
+	// CreateEmbeddings generates embedding vectors for multiple documents. The result is
+	// aligned with docs; when only some documents fail it returns the successful vectors,
+	// with nil entries for the failures, together with a *PartialError.
 	CreateEmbeddings(ctx context.Context, docs []datasources.Document) ([]Vector, error)
-	
+
 	// QueryEmbedding generates an embedding vector for a query
 	QueryEmbedding(ctx context.Context, query string) (Vector, error)
 }
 
+// PartialError reports documents that could not be embedded while others succeeded
+type PartialError struct {
+	// Failed maps the index of each failed document to its error
+	Failed map[int]error
+}
+
+func (e *PartialError) Error() string {
+	return fmt.Sprintf("failed to embed %d documents: %v", len(e.Failed), e.first())
+}
+
+// first returns any one of the underlying errors
+func (e *PartialError) first() error {
+	for _, err := range e.Failed {
+		return err
+	}
+	return nil
+}
+
 // Config holds configuration for the embedding service
 type Config struct {
 	OpenAIKey     string
 	ModelName     string
 	BatchSize     int
 	MaxRetries    int
-	RetryInterval int
+	RetryInterval int // seconds before the first retry; doubles on each attempt
+
+	// MaxBatchTokens caps the estimated tokens sent in one request
+	MaxBatchTokens int
+
+	// RequestsPerMinute and TokensPerMinute throttle requests client-side. Zero disables a limit.
+	RequestsPerMinute int
+	TokensPerMinute   int
+}
+
+// retryPolicy converts the retry settings into a backoff policy
+func (c Config) retryPolicy() retry.Policy {
+	policy := retry.DefaultPolicy()
+	policy.MaxAttempts = c.MaxRetries + 1
+	if c.RetryInterval > 0 {
+		policy.InitialInterval = time.Duration(c.RetryInterval) * time.Second
+	}
+	return policy
 }
@@ -0,0 +1,82 @@
+package extract
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// MIME types of the file formats Text understands
+const (
+	MimePDF  = "application/pdf"
+	MimeDOCX = "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+	MimeXLSX = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	MimePPTX = "application/vnd.openxmlformats-officedocument.presentationml.presentation"
+	MimeText = "text/plain"
+	MimeMD   = "text/markdown"
+	MimeCSV  = "text/csv"
+	MimeHTML = "text/html"
+)
+
+// ErrUnsupported is returned for file types without an extractor
+var ErrUnsupported = errors.New("unsupported file type")
+
+// extractors maps MIME types to the function that reads their text
+var extractors = map[string]func([]byte) (string, error){
+	MimePDF:  PDFToText,
+	MimeDOCX: DOCXToText,
+	MimeXLSX: XLSXToText,
+	MimePPTX: PPTXToText,
+	MimeText: plainText,
+	MimeMD:   plainText,
+	MimeCSV:  plainText,
+	MimeHTML: func(data []byte) (string, error) { return HTMLToText(string(data)), nil },
+}
+
+// SupportedTypes returns the MIME types Text can extract, sorted
+func SupportedTypes() []string {
+	types := make([]string, 0, len(extractors))
+	for mimeType := range extractors {
+		types = append(types, mimeType)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// Supported reports whether Text can extract files of the given MIME type
+func Supported(mimeType string) bool {
+	_, ok := extractors[baseType(mimeType)]
+	return ok
+}
+
+// Text extracts the readable text of a file
+func Text(mimeType string, data []byte) (string, error) {
+	extractor, ok := extractors[baseType(mimeType)]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnsupported, mimeType)
+	}
+
+	text, err := extractor(data)
+	if err != nil {
+		return "", fmt.Errorf("failed to extract %s: %w", mimeType, err)
+	}
+	return strings.TrimSpace(text), nil
+}
+
+// baseType strips parameters such as charset from a MIME type
+func baseType(mimeType string) string {
+	if i := strings.Index(mimeType, ";"); i >= 0 {
+		mimeType = mimeType[:i]
+	}
+	return strings.ToLower(strings.TrimSpace(mimeType))
+}
+
+// plainText returns text files as is, replacing invalid UTF-8
+func plainText(data []byte) (string, error) {
+	if utf8.Valid(data) {
+		return string(data), nil
+	}
+	return strings.ToValidUTF8(string(data), "�"), nil
+}
@@ -0,0 +1,113 @@
+package extract
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestText(t *testing.T) {
+	pdfText := []string{"Quarterly report", "Revenue grew  12% (year over year)", "Second page, compressed"}
+
+	tests := []struct {
+		file     string
+		mimeType string
+		want     []string
+		exclude  []string
+	}{
+		{file: "report.pdf", mimeType: MimePDF, want: pdfText},
+		// Encrypted with an owner password only, so they open with the empty user password
+		{file: "report-rc4.pdf", mimeType: MimePDF, want: pdfText},
+		{file: "report-aes128.pdf", mimeType: MimePDF, want: pdfText},
+		{file: "report-aes256.pdf", mimeType: MimePDF, want: pdfText},
+		{
+			file:     "notes.docx",
+			mimeType: MimeDOCX,
+			want: []string{
+				"Launch plan\nShip the beta in July.\nThen iterate.\n",
+				"Owner | Task\nAna | Docs \\| site and blog\n",
+				"Done.",
+			},
+		},
+		{
+			file:     "budget.xlsx",
+			mimeType: MimeXLSX,
+			want: []string{
+				"## Budget\n\n| Item | Cost |  | Approved |\n| --- | --- | --- | --- |\n" +
+					"| Laptops | 1200 |  | TRUE |\n| Monitors | 300.5 |  | FALSE |",
+			},
+			exclude: []string{"Hidden", "secret"},
+		},
+		{
+			file:     "deck.pptx",
+			mimeType: MimePPTX,
+			want: []string{
+				"## Slide 1\n\nRoadmap 2024\n\nQ3: beta\nQ4: launch\n\nSpeaker notes:\nMention the hiring plan.",
+				"Questions?",
+			},
+			// The slide number placeholder on the notes page isn't part of the notes
+			exclude: []string{"Speaker notes:\n1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			text, err := Text(tt.mimeType, readFixture(t, tt.file))
+			if err != nil {
+				t.Fatalf("Text() error = %v", err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(text, want) {
+					t.Errorf("Text() = %q, missing %q", text, want)
+				}
+			}
+			for _, exclude := range tt.exclude {
+				if strings.Contains(text, exclude) {
+					t.Errorf("Text() = %q, should not contain %q", text, exclude)
+				}
+			}
+		})
+	}
+}
+
+func TestPDFUserPassword(t *testing.T) {
+	_, err := Text(MimePDF, readFixture(t, "report-user-password.pdf"))
+	if !errors.Is(err, ErrEncrypted) {
+		t.Fatalf("Text() error = %v, want ErrEncrypted", err)
+	}
+}
+
+func TestXLSXColumnLimit(t *testing.T) {
+	_, err := Text(MimeXLSX, readFixture(t, "huge-column.xlsx"))
+	if err == nil || !strings.Contains(err.Error(), "beyond the last column") {
+		t.Fatalf("Text() error = %v, want a malformed sheet error", err)
+	}
+}
+
+func TestColumnIndex(t *testing.T) {
+	tests := map[string]int{
+		"A1":        0,
+		"C12":       2,
+		"AA3":       26,
+		"XFD1":      maxSheetColumns - 1,
+		"XFE1":      maxSheetColumns,
+		"ZZZZZZZZ1": maxSheetColumns,
+		"12":        -1,
+	}
+	for ref, want := range tests {
+		if got := columnIndex(ref); got != want {
+			t.Errorf("columnIndex(%q) = %d, want %d", ref, got, want)
+		}
+	}
+}
+
+func readFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatalf("failed to read fixture: %v", err)
+	}
+	return data
+}
@@ -0,0 +1,540 @@
+package extract
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// maxPartSize bounds how much of a single archive member is read, guarding
+// against compressed files that expand to an unreasonable size
+const maxPartSize = 64 << 20
+
+// maxSheetRows caps how many rows of each worksheet are extracted
+const maxSheetRows = 1000
+
+// maxSheetColumns is Excel's column limit, XFD; cells beyond it make a sheet malformed
+const maxSheetColumns = 16384
+
+// DOCXToText extracts paragraphs and tables from a Word document
+func DOCXToText(data []byte) (string, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return "", fmt.Errorf("failed to open archive: %w", err)
+	}
+
+	body, err := readPart(archive, "word/document.xml")
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	var row []string
+	var cell strings.Builder
+	cellDepth := 0
+
+	err = walkXML(body, func(tok xml.Token) {
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "tc":
+				cellDepth++
+				cell.Reset()
+			case "tab":
+				writeText(&sb, &cell, cellDepth, "\t")
+			case "br", "cr":
+				writeText(&sb, &cell, cellDepth, "\n")
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "p":
+				writeText(&sb, &cell, cellDepth, "\n")
+			case "tc":
+				row = append(row, cell.String())
+				cellDepth--
+			case "tr":
+				sb.WriteString(strings.Join(cleanCells(row), " | ") + "\n")
+				row = nil
+			case "tbl":
+				sb.WriteString("\n")
+			}
+		case textToken:
+			writeText(&sb, &cell, cellDepth, string(t))
+		}
+	}, "t")
+	if err != nil {
+		return "", err
+	}
+
+	return collapseBlankLines(sb.String()), nil
+}
+
+// XLSXToText renders each worksheet of a workbook as a Markdown table
+func XLSXToText(data []byte) (string, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return "", fmt.Errorf("failed to open archive: %w", err)
+	}
+
+	shared, err := sharedStrings(archive)
+	if err != nil {
+		return "", err
+	}
+
+	sheets, err := worksheets(archive)
+	if err != nil {
+		return "", err
+	}
+
+	var sections []string
+	for _, sheet := range sheets {
+		part, err := readPart(archive, sheet.path)
+		if err != nil {
+			return "", err
+		}
+
+		rows, err := sheetRows(part, shared)
+		if err != nil {
+			return "", fmt.Errorf("failed to read sheet %s: %w", sheet.name, err)
+		}
+		if len(rows) == 0 {
+			continue
+		}
+		sections = append(sections, fmt.Sprintf("## %s\n\n%s", sheet.name, MarkdownTable(rows)))
+	}
+
+	return strings.Join(sections, "\n"), nil
+}
+
+// PPTXToText extracts slide text and speaker notes from a presentation
+func PPTXToText(data []byte) (string, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return "", fmt.Errorf("failed to open archive: %w", err)
+	}
+
+	slides := numberedParts(archive, "ppt/slides/slide")
+
+	var sections []string
+	for i, name := range slides {
+		part, err := readPart(archive, name)
+		if err != nil {
+			return "", err
+		}
+
+		text, err := shapeText(part, false)
+		if err != nil {
+			return "", fmt.Errorf("failed to read slide %d: %w", i+1, err)
+		}
+
+		if notesPath := relatedPart(archive, name, "/notesSlide"); notesPath != "" {
+			notesPart, err := readPart(archive, notesPath)
+			if err != nil {
+				return "", err
+			}
+			notes, err := shapeText(notesPart, true)
+			if err != nil {
+				return "", fmt.Errorf("failed to read notes for slide %d: %w", i+1, err)
+			}
+			if notes != "" {
+				text = strings.TrimSpace(text + "\n\nSpeaker notes:\n" + notes)
+			}
+		}
+
+		if text != "" {
+			sections = append(sections, fmt.Sprintf("## Slide %d\n\n%s", i+1, text))
+		}
+	}
+
+	return strings.Join(sections, "\n\n"), nil
+}
+
+// textToken is the character data of an element selected by walkXML
+type textToken string
+
+// walkXML calls fn for every element start and end, and for the text of the
+// elements named in textElements. Other character data is ignored.
+func walkXML(data []byte, fn func(xml.Token), textElements ...string) error {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	inText := 0
+
+	for {
+		tok, err := decoder.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to parse XML: %w", err)
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if contains(textElements, t.Name.Local) {
+				inText++
+			}
+			fn(t)
+		case xml.EndElement:
+			if contains(textElements, t.Name.Local) {
+				inText--
+			}
+			fn(t)
+		case xml.CharData:
+			if inText > 0 {
+				fn(textToken(t))
+			}
+		}
+	}
+}
+
+// writeText appends to the current table cell, or the document when outside a table
+func writeText(doc, cell *strings.Builder, cellDepth int, s string) {
+	if cellDepth > 0 {
+		if s == "\n" {
+			s = " "
+		}
+		cell.WriteString(s)
+		return
+	}
+	doc.WriteString(s)
+}
+
+func cleanCells(cells []string) []string {
+	out := make([]string, len(cells))
+	for i, c := range cells {
+		out[i] = tableCell(c)
+	}
+	return out
+}
+
+// sharedStrings reads the workbook's shared string table
+func sharedStrings(archive *zip.Reader) ([]string, error) {
+	part, err := readPart(archive, "xl/sharedStrings.xml")
+	if err != nil {
+		// Workbooks without text cells have no shared strings
+		return nil, nil
+	}
+
+	var strs []string
+	var current strings.Builder
+	skip := 0
+	err = walkXML(part, func(tok xml.Token) {
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if t.Name.Local == "si" {
+				current.Reset()
+			} else if t.Name.Local == "rPh" {
+				skip++ // phonetic guides repeat the text
+			}
+		case xml.EndElement:
+			if t.Name.Local == "si" {
+				strs = append(strs, current.String())
+			} else if t.Name.Local == "rPh" {
+				skip--
+			}
+		case textToken:
+			if skip == 0 {
+				current.WriteString(string(t))
+			}
+		}
+	}, "t")
+	return strs, err
+}
+
+type worksheet struct {
+	name string
+	path string
+}
+
+// worksheets lists the workbook's sheets in tab order with their archive paths
+func worksheets(archive *zip.Reader) ([]worksheet, error) {
+	workbook, err := readPart(archive, "xl/workbook.xml")
+	if err != nil {
+		return nil, err
+	}
+	targets := make(map[string]string)
+	for _, rel := range relationships(archive, "xl/workbook.xml") {
+		targets[rel.id] = rel.target
+	}
+
+	var sheets []worksheet
+	err = walkXML(workbook, func(tok xml.Token) {
+		start, ok := tok.(xml.StartElement)
+		if !ok || start.Name.Local != "sheet" {
+			return
+		}
+
+		var sheet worksheet
+		for _, attr := range start.Attr {
+			switch {
+			case attr.Name.Local == "name":
+				sheet.name = attr.Value
+			case attr.Name.Local == "id" && attr.Name.Space != "":
+				sheet.path = targets[attr.Value]
+			case attr.Name.Local == "state" && attr.Value != "visible":
+				return
+			}
+		}
+		if sheet.path != "" {
+			sheets = append(sheets, sheet)
+		}
+	})
+	return sheets, err
+}
+
+// sheetRows reads a worksheet's cell values, placing each cell in its column
+func sheetRows(part []byte, shared []string) ([][]string, error) {
+	var rows [][]string
+	var row []string
+	var cellType, cellRef string
+	var value strings.Builder
+	var malformed error
+
+	err := walkXML(part, func(tok xml.Token) {
+		if malformed != nil {
+			return
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "row":
+				row = nil
+			case "c":
+				cellType, cellRef = "", ""
+				value.Reset()
+				for _, attr := range t.Attr {
+					switch attr.Name.Local {
+					case "t":
+						cellType = attr.Value
+					case "r":
+						cellRef = attr.Value
+					}
+				}
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "c":
+				text := value.String()
+				if cellType == "s" {
+					if i, err := strconv.Atoi(text); err == nil && i >= 0 && i < len(shared) {
+						text = shared[i]
+					}
+				} else if cellType == "b" {
+					text = map[string]string{"0": "FALSE", "1": "TRUE"}[text]
+				}
+
+				col := columnIndex(cellRef)
+				if col < 0 {
+					col = len(row)
+				}
+				if col >= maxSheetColumns {
+					malformed = fmt.Errorf("cell %q is beyond the last column", cellRef)
+					return
+				}
+				for len(row) <= col {
+					row = append(row, "")
+				}
+				row[col] = text
+			case "row":
+				if len(rows) < maxSheetRows && !emptyRow(row) {
+					rows = append(rows, row)
+				}
+			}
+		case textToken:
+			value.WriteString(string(t))
+		}
+	}, "v", "t")
+	if err == nil {
+		err = malformed
+	}
+	return rows, err
+}
+
+// columnIndex converts the letters of a cell reference such as "C12" to a zero-based
+// column. References past maxSheetColumns return maxSheetColumns.
+func columnIndex(ref string) int {
+	col := 0
+	n := 0
+	for _, r := range ref {
+		if r < 'A' || r > 'Z' {
+			break
+		}
+		col = col*26 + int(r-'A'+1)
+		n++
+		if col > maxSheetColumns {
+			return maxSheetColumns
+		}
+	}
+	if n == 0 {
+		return -1
+	}
+	return col - 1
+}
+
+func emptyRow(row []string) bool {
+	for _, cell := range row {
+		if strings.TrimSpace(cell) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// shapeText joins the paragraphs of a slide's shapes. With bodyOnly, only the
+// body placeholder is read, which on a notes page holds the speaker notes.
+func shapeText(part []byte, bodyOnly bool) (string, error) {
+	var sb, shape strings.Builder
+	isBody := false
+
+	err := walkXML(part, func(tok xml.Token) {
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "sp":
+				shape.Reset()
+				isBody = false
+			case "ph":
+				for _, attr := range t.Attr {
+					if attr.Name.Local == "type" && attr.Value == "body" {
+						isBody = true
+					}
+				}
+			case "br":
+				shape.WriteString("\n")
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "p":
+				shape.WriteString("\n")
+			case "sp", "graphicFrame":
+				if text := strings.TrimSpace(shape.String()); text != "" && (!bodyOnly || isBody) {
+					sb.WriteString(text + "\n\n")
+				}
+				shape.Reset()
+			}
+		case textToken:
+			shape.WriteString(string(t))
+		}
+	}, "t")
+	if err != nil {
+		return "", err
+	}
+
+	return collapseBlankLines(sb.String()), nil
+}
+
+// numberedParts lists archive members named prefix<N>.xml in numeric order
+func numberedParts(archive *zip.Reader, prefix string) []string {
+	type numbered struct {
+		name string
+		n    int
+	}
+
+	var parts []numbered
+	for _, f := range archive.File {
+		if !strings.HasPrefix(f.Name, prefix) || !strings.HasSuffix(f.Name, ".xml") {
+			continue
+		}
+		n, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(f.Name, prefix), ".xml"))
+		if err != nil {
+			continue
+		}
+		parts = append(parts, numbered{f.Name, n})
+	}
+
+	sort.Slice(parts, func(i, j int) bool { return parts[i].n < parts[j].n })
+
+	names := make([]string, len(parts))
+	for i, p := range parts {
+		names[i] = p.name
+	}
+	return names
+}
+
+type relationship struct {
+	id     string
+	kind   string
+	target string
+}
+
+// relationships lists the relationships of a part, with targets resolved to archive paths
+func relationships(archive *zip.Reader, name string) []relationship {
+	dir, file := path.Split(name)
+
+	part, err := readPart(archive, dir+"_rels/"+file+".rels")
+	if err != nil {
+		return nil
+	}
+
+	var rels []relationship
+	walkXML(part, func(tok xml.Token) {
+		start, ok := tok.(xml.StartElement)
+		if !ok || start.Name.Local != "Relationship" {
+			return
+		}
+		var rel relationship
+		for _, attr := range start.Attr {
+			switch attr.Name.Local {
+			case "Id":
+				rel.id = attr.Value
+			case "Target":
+				rel.target = attr.Value
+			case "Type":
+				rel.kind = attr.Value
+			}
+		}
+		if strings.HasPrefix(rel.target, "/") {
+			rel.target = strings.TrimPrefix(rel.target, "/")
+		} else {
+			rel.target = path.Join(dir, rel.target)
+		}
+		rels = append(rels, rel)
+	})
+	return rels
+}
+
+// relatedPart returns the target of the first relationship whose type ends with typeSuffix
+func relatedPart(archive *zip.Reader, name, typeSuffix string) string {
+	for _, rel := range relationships(archive, name) {
+		if strings.HasSuffix(rel.kind, typeSuffix) {
+			return rel.target
+		}
+	}
+	return ""
+}
+
+// readPart reads a member of an OOXML archive
+func readPart(archive *zip.Reader, name string) ([]byte, error) {
+	for _, f := range archive.File {
+		if f.Name != name {
+			continue
+		}
+
+		rc, err := f.Open()
+		if err != nil {
+			return nil, fmt.Errorf("failed to open %s: %w", name, err)
+		}
+		defer rc.Close()
+
+		data, err := io.ReadAll(io.LimitReader(rc, maxPartSize))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", name, err)
+		}
+		return data, nil
+	}
+	return nil, fmt.Errorf("missing %s", name)
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,395 @@
+package extract
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+)
+
+// ErrEncrypted is returned for PDFs that can't be read without a password
+var ErrEncrypted = errors.New("encrypted PDF needs a password")
+
+var (
+	streamStart = regexp.MustCompile(`stream\r?\n`)
+	encryptKey  = regexp.MustCompile(`/Encrypt\b`)
+	flateFilter = regexp.MustCompile(`/FlateDecode\b`)
+	otherFilter = regexp.MustCompile(`/(DCTDecode|JPXDecode|CCITTFaxDecode|JBIG2Decode|LZWDecode|RunLengthDecode|ASCII85Decode|ASCIIHexDecode)\b`)
+)
+
+// PDFToText extracts text drawn by the content streams of a PDF. It handles the
+// common case of uncompressed or Flate-compressed streams with simple font
+// encodings; text in fonts with custom character maps may not be recoverable.
+// Encrypted PDFs are read when they open with the empty user password.
+func PDFToText(data []byte) (string, error) {
+	if !bytes.HasPrefix(bytes.TrimLeft(data, "\x00\t\r\n "), []byte("%PDF")) {
+		return "", errors.New("not a PDF file")
+	}
+
+	var dec *pdfDecrypter
+	if encryptKey.Match(data) {
+		var err error
+		if dec, err = newPDFDecrypter(data); err != nil {
+			return "", err
+		}
+	}
+
+	var sb strings.Builder
+	for _, stream := range pdfStreams(data, dec) {
+		sb.WriteString(contentText(stream))
+	}
+
+	return collapseBlankLines(sb.String()), nil
+}
+
+// pdfStreams returns the decoded contents of every stream that could hold page
+// content, decrypting them first when dec is set
+func pdfStreams(data []byte, dec *pdfDecrypter) [][]byte {
+	var streams [][]byte
+
+	offset := 0
+	for {
+		loc := streamStart.FindIndex(data[offset:])
+		if loc == nil {
+			return streams
+		}
+		start := offset + loc[1]
+
+		end := bytes.Index(data[start:], []byte("endstream"))
+		if end < 0 {
+			return streams
+		}
+		raw := data[start : start+end]
+		offset = start + end + len("endstream")
+
+		dict, dictStart := streamDict(data[:start])
+		if dec != nil {
+			num, gen, ok := objectNumber(data[:dictStart])
+			if !ok {
+				continue
+			}
+			if raw, ok = dec.decrypt(num, gen, raw); !ok {
+				continue
+			}
+		}
+
+		switch {
+		case otherFilter.Match(dict):
+			continue
+		case flateFilter.Match(dict):
+			r, err := zlib.NewReader(bytes.NewReader(raw))
+			if err != nil {
+				continue
+			}
+			// Truncated streams still yield their readable prefix
+			decoded, _ := io.ReadAll(io.LimitReader(r, maxPartSize))
+			r.Close()
+			streams = append(streams, decoded)
+		default:
+			streams = append(streams, raw)
+		}
+	}
+}
+
+// streamDict returns the dictionary preceding a stream keyword, and the offset
+// just past its object's "obj" keyword
+func streamDict(before []byte) ([]byte, int) {
+	start := bytes.LastIndex(before, []byte("obj"))
+	if start < 0 {
+		return before, 0
+	}
+	return before[start:], start + len("obj")
+}
+
+// contentText interprets the text operators of a content stream
+func contentText(stream []byte) string {
+	var sb strings.Builder
+	var operands []pdfOperand
+	inText := false
+
+	lex := &pdfLexer{data: stream}
+	for {
+		tok, ok := lex.next()
+		if !ok {
+			break
+		}
+		if tok.op == "" {
+			operands = append(operands, tok)
+			continue
+		}
+
+		switch tok.op {
+		case "BT":
+			inText = true
+		case "ET":
+			inText = false
+			sb.WriteString("\n")
+		case "Tj":
+			if inText && len(operands) > 0 {
+				sb.WriteString(operands[len(operands)-1].text)
+			}
+		case "'", "\"":
+			if inText && len(operands) > 0 {
+				sb.WriteString("\n" + operands[len(operands)-1].text)
+			}
+		case "TJ":
+			if inText && len(operands) > 0 {
+				sb.WriteString(operands[len(operands)-1].text)
+			}
+		case "T*":
+			sb.WriteString("\n")
+		case "Td", "TD":
+			if inText && len(operands) >= 2 && operands[len(operands)-1].num != 0 {
+				sb.WriteString("\n")
+			} else if inText {
+				sb.WriteString(" ")
+			}
+		case "Tm":
+			if inText {
+				sb.WriteString("\n")
+			}
+		}
+		operands = operands[:0]
+	}
+
+	return sb.String()
+}
+
+// pdfOperand is a lexed operand or, when op is set, an operator
+type pdfOperand struct {
+	op   string
+	text string
+	num  float64
+}
+
+type pdfLexer struct {
+	data []byte
+	pos  int
+}
+
+func (l *pdfLexer) next() (pdfOperand, bool) {
+	l.skipSpace()
+	if l.pos >= len(l.data) {
+		return pdfOperand{}, false
+	}
+
+	c := l.data[l.pos]
+	switch {
+	case c == '(':
+		return pdfOperand{text: decodePDFString(l.literal())}, true
+	case c == '<' && l.peek(1) == '<':
+		l.skipDict()
+		return pdfOperand{}, true
+	case c == '<':
+		return pdfOperand{text: decodePDFString(l.hex())}, true
+	case c == '[':
+		return pdfOperand{text: l.array()}, true
+	case c == '/':
+		l.pos++
+		l.word()
+		return pdfOperand{}, true
+	case c == '-' || c == '+' || c == '.' || (c >= '0' && c <= '9'):
+		n, _ := strconv.ParseFloat(l.word(), 64)
+		return pdfOperand{num: n}, true
+	default:
+		w := l.word()
+		if w == "" {
+			l.pos++
+			return pdfOperand{}, true
+		}
+		return pdfOperand{op: w}, true
+	}
+}
+
+// array reads a TJ array, joining its strings and turning wide negative kerning into spaces
+func (l *pdfLexer) array() string {
+	l.pos++ // [
+	var sb strings.Builder
+	for {
+		l.skipSpace()
+		if l.pos >= len(l.data) {
+			return sb.String()
+		}
+		if l.data[l.pos] == ']' {
+			l.pos++
+			return sb.String()
+		}
+		tok, ok := l.next()
+		if !ok {
+			return sb.String()
+		}
+		if tok.text != "" {
+			sb.WriteString(tok.text)
+		} else if tok.num < -200 {
+			sb.WriteString(" ")
+		}
+	}
+}
+
+func (l *pdfLexer) literal() []byte {
+	l.pos++ // (
+	var out []byte
+	depth := 1
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		l.pos++
+		switch c {
+		case '\\':
+			if l.pos >= len(l.data) {
+				return out
+			}
+			e := l.data[l.pos]
+			l.pos++
+			switch e {
+			case 'n':
+				out = append(out, '\n')
+			case 'r':
+				out = append(out, '\r')
+			case 't':
+				out = append(out, '\t')
+			case 'b', 'f':
+			case '\r', '\n':
+				// Line continuation
+			default:
+				if e >= '0' && e <= '7' {
+					n := int(e - '0')
+					for i := 0; i < 2 && l.pos < len(l.data) && l.data[l.pos] >= '0' && l.data[l.pos] <= '7'; i++ {
+						n = n*8 + int(l.data[l.pos]-'0')
+						l.pos++
+					}
+					out = append(out, byte(n))
+				} else {
+					out = append(out, e)
+				}
+			}
+		case '(':
+			depth++
+			out = append(out, c)
+		case ')':
+			depth--
+			if depth == 0 {
+				return out
+			}
+			out = append(out, c)
+		default:
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+func (l *pdfLexer) hex() []byte {
+	l.pos++ // <
+	var digits []byte
+	for l.pos < len(l.data) && l.data[l.pos] != '>' {
+		if isHexDigit(l.data[l.pos]) {
+			digits = append(digits, l.data[l.pos])
+		}
+		l.pos++
+	}
+	l.pos++ // >
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+
+	out := make([]byte, len(digits)/2)
+	for i := range out {
+		n, _ := strconv.ParseUint(string(digits[2*i:2*i+2]), 16, 8)
+		out[i] = byte(n)
+	}
+	return out
+}
+
+func (l *pdfLexer) skipDict() {
+	depth := 0
+	for l.pos < len(l.data)-1 {
+		switch {
+		case l.data[l.pos] == '<' && l.data[l.pos+1] == '<':
+			depth++
+			l.pos += 2
+		case l.data[l.pos] == '>' && l.data[l.pos+1] == '>':
+			depth--
+			l.pos += 2
+			if depth == 0 {
+				return
+			}
+		default:
+			l.pos++
+		}
+	}
+	l.pos = len(l.data)
+}
+
+func (l *pdfLexer) word() string {
+	start := l.pos
+	for l.pos < len(l.data) && !isPDFDelimiter(l.data[l.pos]) {
+		l.pos++
+	}
+	return string(l.data[start:l.pos])
+}
+
+func (l *pdfLexer) skipSpace() {
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		if c == '%' {
+			for l.pos < len(l.data) && l.data[l.pos] != '\n' && l.data[l.pos] != '\r' {
+				l.pos++
+			}
+			continue
+		}
+		if !isPDFSpace(c) {
+			return
+		}
+		l.pos++
+	}
+}
+
+func (l *pdfLexer) peek(n int) byte {
+	if l.pos+n < len(l.data) {
+		return l.data[l.pos+n]
+	}
+	return 0
+}
+
+// decodePDFString converts a PDF string to UTF-8, dropping unprintable bytes
+// that usually come from fonts with custom encodings
+func decodePDFString(b []byte) string {
+	var runes []rune
+	if len(b) >= 2 && b[0] == 0xFE && b[1] == 0xFF {
+		units := make([]uint16, 0, len(b)/2)
+		for i := 2; i+1 < len(b); i += 2 {
+			units = append(units, uint16(b[i])<<8|uint16(b[i+1]))
+		}
+		runes = utf16.Decode(units)
+	} else {
+		runes = make([]rune, len(b))
+		for i, c := range b {
+			runes[i] = rune(c)
+		}
+	}
+
+	var sb strings.Builder
+	for _, r := range runes {
+		if unicode.IsPrint(r) || r == '\n' || r == '\t' {
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String()
+}
+
+func isPDFSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n' || c == '\f' || c == 0
+}
+
+func isPDFDelimiter(c byte) bool {
+	return isPDFSpace(c) || strings.IndexByte("()<>[]{}/%", c) >= 0
+}
+
+func isHexDigit(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
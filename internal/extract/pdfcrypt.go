@@ -0,0 +1,427 @@
+package extract
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/md5"
+	"crypto/rc4"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"hash"
+	"regexp"
+	"strconv"
+)
+
+// passwordPadding pads passwords to 32 bytes in the standard security handler
+var passwordPadding = []byte{
+	0x28, 0xBF, 0x4E, 0x5E, 0x4E, 0x75, 0x8A, 0x41, 0x64, 0x00, 0x4E, 0x56, 0xFF, 0xFA, 0x01, 0x08,
+	0x2E, 0x2E, 0x00, 0xB6, 0xD0, 0x68, 0x3E, 0x80, 0x2F, 0x0C, 0xA9, 0xFE, 0x64, 0x53, 0x69, 0x7A,
+}
+
+var (
+	encryptRef  = regexp.MustCompile(`/Encrypt\s*(\d+)\s+(\d+)\s+R`)
+	encryptDict = regexp.MustCompile(`/Encrypt\s*<<`)
+	trailerID   = regexp.MustCompile(`/ID\s*\[`)
+	objectStart = regexp.MustCompile(`(\d+)\s+(\d+)\s+obj\s*$`)
+)
+
+// pdfDecrypter decrypts the streams of a PDF protected by the standard security handler
+type pdfDecrypter struct {
+	key []byte
+	aes bool
+	// Revisions 5 and 6 encrypt every object with the file key itself
+	fileKey bool
+	// identity is set when streams are left unencrypted, as with StmF /Identity
+	identity bool
+}
+
+// newPDFDecrypter opens an encrypted PDF with the empty user password, which many
+// documents use to restrict printing or editing without asking for a password to
+// read them. It returns ErrEncrypted when a password is needed or the encryption
+// scheme isn't supported.
+func newPDFDecrypter(data []byte) (*pdfDecrypter, error) {
+	dict, ok := encryptionDict(data)
+	if !ok || dict.name("Filter") != "Standard" {
+		return nil, ErrEncrypted
+	}
+
+	v, r := dict.int("V"), dict.int("R")
+	o, u := dict.bytes("O"), dict.bytes("U")
+
+	dec := &pdfDecrypter{}
+	switch v {
+	case 1, 2:
+	case 4, 5:
+		// Crypt filters name the cipher; only the one used for streams matters here
+		stmF := dict.name("StmF")
+		if stmF == "" || stmF == "Identity" {
+			dec.identity = true
+			return dec, nil
+		}
+		switch dict.dict("CF").dict(stmF).name("CFM") {
+		case "V2":
+		case "AESV2", "AESV3":
+			dec.aes = true
+		case "None":
+			dec.identity = true
+			return dec, nil
+		default:
+			return nil, ErrEncrypted
+		}
+	default:
+		return nil, ErrEncrypted
+	}
+
+	switch r {
+	case 2, 3, 4:
+		if len(o) < 32 || len(u) < 16 {
+			return nil, ErrEncrypted
+		}
+		length := 5
+		if r >= 3 {
+			length = dict.int("Length") / 8
+			if length == 0 && v == 4 {
+				// Crypt filters give their length in bytes, though some writers use bits
+				length = dict.dict("CF").dict(dict.name("StmF")).int("Length")
+				if length > 16 {
+					length /= 8
+				}
+				if length == 0 {
+					length = 16
+				}
+			}
+			if length == 0 {
+				length = 5
+			}
+			if length < 5 || length > 16 {
+				return nil, ErrEncrypted
+			}
+		}
+		key := rc4FileKey(o, uint32(int32(dict.int("P"))), firstID(data), r, length, dict.bool("EncryptMetadata", true))
+		if !checkUserKey(key, u, firstID(data), r) {
+			return nil, ErrEncrypted
+		}
+		dec.key = key
+	case 5, 6:
+		ue := dict.bytes("UE")
+		if len(u) < 48 || len(ue) < 32 {
+			return nil, ErrEncrypted
+		}
+		if !bytes.Equal(aes256Hash(r, u[32:40]), u[:32]) {
+			return nil, ErrEncrypted
+		}
+		block, err := aes.NewCipher(aes256Hash(r, u[40:48]))
+		if err != nil {
+			return nil, ErrEncrypted
+		}
+		key := make([]byte, 32)
+		cipher.NewCBCDecrypter(block, make([]byte, aes.BlockSize)).CryptBlocks(key, ue[:32])
+		dec.key = key
+		dec.fileKey = true
+	default:
+		return nil, ErrEncrypted
+	}
+	return dec, nil
+}
+
+// decrypt returns the plaintext of a stream belonging to object num, gen
+func (d *pdfDecrypter) decrypt(num, gen int, data []byte) ([]byte, bool) {
+	if d.identity {
+		return data, true
+	}
+
+	key := d.key
+	if !d.fileKey {
+		// Algorithm 1: each object has its own key derived from the file key
+		h := md5.New()
+		h.Write(key)
+		h.Write([]byte{byte(num), byte(num >> 8), byte(num >> 16), byte(gen), byte(gen >> 8)})
+		if d.aes {
+			h.Write([]byte("sAlT"))
+		}
+		key = h.Sum(nil)[:min(len(d.key)+5, 16)]
+	}
+
+	if !d.aes {
+		c, err := rc4.NewCipher(key)
+		if err != nil {
+			return nil, false
+		}
+		out := make([]byte, len(data))
+		c.XORKeyStream(out, data)
+		return out, true
+	}
+
+	// AES streams start with the IV and are padded to the block size; the end of
+	// line before endstream isn't part of the data
+	for len(data)%aes.BlockSize != 0 && len(data) > 0 &&
+		(data[len(data)-1] == '\n' || data[len(data)-1] == '\r') {
+		data = data[:len(data)-1]
+	}
+	if len(data) < 2*aes.BlockSize || len(data)%aes.BlockSize != 0 {
+		return nil, false
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, false
+	}
+	out := make([]byte, len(data)-aes.BlockSize)
+	cipher.NewCBCDecrypter(block, data[:aes.BlockSize]).CryptBlocks(out, data[aes.BlockSize:])
+	pad := int(out[len(out)-1])
+	if pad == 0 || pad > aes.BlockSize {
+		return nil, false
+	}
+	return out[:len(out)-pad], true
+}
+
+// rc4FileKey computes the file key for the empty user password (algorithm 2)
+func rc4FileKey(o []byte, p uint32, id []byte, r, length int, encryptMetadata bool) []byte {
+	h := md5.New()
+	h.Write(passwordPadding)
+	h.Write(o[:32])
+	binary.Write(h, binary.LittleEndian, p)
+	h.Write(id)
+	if r >= 4 && !encryptMetadata {
+		h.Write([]byte{0xFF, 0xFF, 0xFF, 0xFF})
+	}
+	key := h.Sum(nil)
+	if r >= 3 {
+		for i := 0; i < 50; i++ {
+			sum := md5.Sum(key[:length])
+			key = sum[:]
+		}
+	}
+	return key[:length]
+}
+
+// checkUserKey reports whether key reproduces the document's U entry (algorithms 4 and 5)
+func checkUserKey(key, u, id []byte, r int) bool {
+	if r == 2 {
+		out := make([]byte, 32)
+		c, _ := rc4.NewCipher(key)
+		c.XORKeyStream(out, passwordPadding)
+		return len(u) >= 32 && bytes.Equal(out, u[:32])
+	}
+
+	h := md5.New()
+	h.Write(passwordPadding)
+	h.Write(id)
+	out := h.Sum(nil)
+	round := make([]byte, len(key))
+	for i := 0; i < 20; i++ {
+		for j := range key {
+			round[j] = key[j] ^ byte(i)
+		}
+		c, _ := rc4.NewCipher(round)
+		c.XORKeyStream(out, out)
+	}
+	return bytes.Equal(out, u[:16])
+}
+
+// aes256Hash hashes the empty password with salt: plain SHA-256 for revision 5,
+// algorithm 2.B for revision 6
+func aes256Hash(r int, salt []byte) []byte {
+	k := sha256.Sum256(salt)
+	if r == 5 {
+		return k[:]
+	}
+
+	key := k[:]
+	var e []byte
+	for i := 0; i < 64 || int(e[len(e)-1]) > i-32; i++ {
+		// The password and user key are empty, so each round repeats just the key
+		k1 := bytes.Repeat(key, 64)
+		block, _ := aes.NewCipher(key[:16])
+		e = make([]byte, len(k1))
+		cipher.NewCBCEncrypter(block, key[16:32]).CryptBlocks(e, k1)
+
+		sum := 0
+		for _, b := range e[:16] {
+			sum += int(b)
+		}
+		var next hash.Hash
+		switch sum % 3 {
+		case 0:
+			next = sha256.New()
+		case 1:
+			next = sha512.New384()
+		default:
+			next = sha512.New()
+		}
+		next.Write(e)
+		key = next.Sum(nil)
+	}
+	return key[:32]
+}
+
+// encryptionDict finds the encryption dictionary named by the trailer
+func encryptionDict(data []byte) (pdfDict, bool) {
+	if loc := lastIndex(encryptDict, data); loc != nil {
+		return parseDictAt(data, loc[1]-2)
+	}
+
+	loc := lastIndex(encryptRef, data)
+	if loc == nil {
+		return nil, false
+	}
+	ref := encryptRef.FindSubmatch(data[loc[0]:loc[1]])
+	obj := lastIndex(regexp.MustCompile(`\b`+string(ref[1])+`\s+`+string(ref[2])+`\s+obj\s*<<`), data)
+	if obj == nil {
+		return nil, false
+	}
+	return parseDictAt(data, obj[1]-2)
+}
+
+// firstID returns the first element of the trailer's file identifier
+func firstID(data []byte) []byte {
+	loc := lastIndex(trailerID, data)
+	if loc == nil {
+		return nil
+	}
+	l := &pdfLexer{data: data, pos: loc[1]}
+	l.skipSpace()
+	switch {
+	case l.pos >= len(data):
+		return nil
+	case data[l.pos] == '<':
+		return l.hex()
+	case data[l.pos] == '(':
+		return l.literal()
+	}
+	return nil
+}
+
+// objectNumber returns the object and generation numbers of the object whose
+// "obj" keyword ends before
+func objectNumber(before []byte) (int, int, bool) {
+	m := objectStart.FindSubmatch(before[max(0, len(before)-32):])
+	if m == nil {
+		return 0, 0, false
+	}
+	num, _ := strconv.Atoi(string(m[1]))
+	gen, _ := strconv.Atoi(string(m[2]))
+	return num, gen, true
+}
+
+func lastIndex(re *regexp.Regexp, data []byte) []int {
+	all := re.FindAllIndex(data, -1)
+	if len(all) == 0 {
+		return nil
+	}
+	return all[len(all)-1]
+}
+
+// pdfDict is a parsed dictionary; values are float64, bool, []byte strings,
+// names prefixed with "/", nested pdfDicts or []interface{} arrays
+type pdfDict map[string]interface{}
+
+func (d pdfDict) name(key string) string {
+	if s, ok := d[key].(string); ok && len(s) > 0 && s[0] == '/' {
+		return s[1:]
+	}
+	return ""
+}
+
+func (d pdfDict) int(key string) int {
+	n, _ := d[key].(float64)
+	return int(n)
+}
+
+func (d pdfDict) bool(key string, def bool) bool {
+	if b, ok := d[key].(bool); ok {
+		return b
+	}
+	return def
+}
+
+func (d pdfDict) bytes(key string) []byte {
+	b, _ := d[key].([]byte)
+	return b
+}
+
+func (d pdfDict) dict(key string) pdfDict {
+	sub, _ := d[key].(pdfDict)
+	return sub
+}
+
+// parseDictAt parses the dictionary starting at the "<<" at pos
+func parseDictAt(data []byte, pos int) (pdfDict, bool) {
+	l := &pdfLexer{data: data, pos: pos}
+	dict, ok := l.value().(pdfDict)
+	return dict, ok
+}
+
+// value reads one object, keeping strings as raw bytes. Indirect references
+// are read as their object number.
+func (l *pdfLexer) value() interface{} {
+	l.skipSpace()
+	if l.pos >= len(l.data) {
+		return nil
+	}
+
+	c := l.data[l.pos]
+	switch {
+	case c == '<' && l.peek(1) == '<':
+		l.pos += 2
+		dict := pdfDict{}
+		for {
+			l.skipSpace()
+			if l.pos >= len(l.data) {
+				return dict
+			}
+			if l.data[l.pos] == '>' && l.peek(1) == '>' {
+				l.pos += 2
+				return dict
+			}
+			if l.data[l.pos] != '/' {
+				return dict
+			}
+			l.pos++
+			key := l.word()
+			dict[key] = l.value()
+		}
+	case c == '<':
+		return l.hex()
+	case c == '(':
+		return l.literal()
+	case c == '[':
+		l.pos++
+		var items []interface{}
+		for {
+			l.skipSpace()
+			if l.pos >= len(l.data) || l.data[l.pos] == ']' {
+				l.pos++
+				return items
+			}
+			items = append(items, l.value())
+		}
+	case c == '/':
+		l.pos++
+		return "/" + l.word()
+	case c == '-' || c == '+' || c == '.' || (c >= '0' && c <= '9'):
+		n, _ := strconv.ParseFloat(l.word(), 64)
+		// Skip the "G R" of an indirect reference
+		save := l.pos
+		l.skipSpace()
+		if gen := l.word(); gen != "" {
+			l.skipSpace()
+			if _, err := strconv.Atoi(gen); err == nil && l.word() == "R" {
+				return n
+			}
+		}
+		l.pos = save
+		return n
+	default:
+		switch w := l.word(); w {
+		case "true":
+			return true
+		case "false":
+			return false
+		case "":
+			l.pos++
+		}
+		return nil
+	}
+}
@@ -0,0 +1,99 @@
+package notify
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Delivery statuses
+const (
+	StatusDelivered = "delivered"
+	StatusFailed    = "failed"
+)
+
+// defaultLogSize is the number of deliveries kept in memory
+const defaultLogSize = 200
+
+// Delivery records the outcome of sending one message to one sink
+type Delivery struct {
+	Time     time.Time `json:"time"`
+	Kind     string    `json:"kind"`
+	Title    string    `json:"title"`
+	Sink     string    `json:"sink"`
+	Status   string    `json:"status"`
+	Attempts int       `json:"attempts"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// DeliveryLog keeps recent deliveries in memory and, when given a path, appends
+// every delivery to a JSON Lines file
+type DeliveryLog struct {
+	mu      sync.Mutex
+	path    string
+	recent  []Delivery
+	maxSize int
+}
+
+// NewDeliveryLog creates a delivery log. An empty path keeps deliveries in memory
+// only; otherwise the file's directory is created if needed.
+func NewDeliveryLog(path string) (*DeliveryLog, error) {
+	if path != "" {
+		if dir := filepath.Dir(path); dir != "." {
+			if err := os.MkdirAll(dir, 0o755); err != nil {
+				return nil, fmt.Errorf("failed to create delivery log directory: %w", err)
+			}
+		}
+	}
+	return &DeliveryLog{path: path, maxSize: defaultLogSize}, nil
+}
+
+// Record adds a delivery to the log
+func (l *DeliveryLog) Record(delivery Delivery) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.recent = append(l.recent, delivery)
+	if len(l.recent) > l.maxSize {
+		l.recent = l.recent[len(l.recent)-l.maxSize:]
+	}
+
+	if l.path == "" {
+		return nil
+	}
+
+	line, err := json.Marshal(delivery)
+	if err != nil {
+		return fmt.Errorf("failed to encode delivery: %w", err)
+	}
+
+	f, err := os.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open delivery log: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write delivery log: %w", err)
+	}
+	return nil
+}
+
+// Recent returns up to limit deliveries, newest first. A limit of zero returns all kept in memory.
+func (l *DeliveryLog) Recent(limit int) []Delivery {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	n := len(l.recent)
+	if limit > 0 && limit < n {
+		n = limit
+	}
+	result := make([]Delivery, 0, n)
+	for i := len(l.recent) - 1; i >= 0 && len(result) < n; i-- {
+		result = append(result, l.recent[i])
+	}
+	return result
+}
@@ -0,0 +1,130 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/michaelgalloway/sophia/internal/retry"
+)
+
+// Message is a notification delivered to every configured sink
+type Message struct {
+	// Kind groups messages by what produced them, such as "briefing"
+	Kind  string `json:"kind"`
+	Title string `json:"title"`
+	// Body is plain text with light Markdown, which every sink can show as is
+	Body   string    `json:"body"`
+	URL    string    `json:"url,omitempty"`
+	SentAt time.Time `json:"sent_at"`
+	// Data is the structured content behind the message, such as a briefing,
+	// for templates and webhook consumers
+	Data interface{} `json:"data,omitempty"`
+}
+
+// Sink delivers messages to one destination
+type Sink interface {
+	Name() string
+	Send(ctx context.Context, msg Message) error
+}
+
+// Config controls how a notifier renders, retries and records messages
+type Config struct {
+	// Retry applies to each sink separately
+	Retry retry.Policy
+	// Templates rewrite messages by kind; messages without one are sent as is
+	Templates *Templates
+	// Log records every delivery attempt when set
+	Log *DeliveryLog
+}
+
+// Notifier fans messages out to a set of sinks
+type Notifier struct {
+	sinks  []Sink
+	config Config
+}
+
+// NewNotifier creates a notifier for the given sinks
+func NewNotifier(config Config, sinks ...Sink) *Notifier {
+	return &Notifier{sinks: sinks, config: config}
+}
+
+// Enabled reports whether any sink is configured
+func (n *Notifier) Enabled() bool {
+	return len(n.sinks) > 0
+}
+
+// Sinks lists the names of the configured sinks
+func (n *Notifier) Sinks() []string {
+	names := make([]string, len(n.sinks))
+	for i, sink := range n.sinks {
+		names[i] = sink.Name()
+	}
+	return names
+}
+
+// Log returns the delivery log, or nil when deliveries aren't recorded
+func (n *Notifier) Log() *DeliveryLog {
+	return n.config.Log
+}
+
+// Send renders msg with its kind's template and delivers it to every sink in
+// parallel, retrying each according to the policy. A failing sink does not stop
+// delivery to the others; their errors are joined in the result.
+func (n *Notifier) Send(ctx context.Context, msg Message) error {
+	if msg.SentAt.IsZero() {
+		msg.SentAt = time.Now()
+	}
+
+	msg, err := n.config.Templates.Render(msg)
+	if err != nil {
+		// Fall back to the message as produced rather than dropping it
+		log.Printf("Failed to render %s notification: %v", msg.Kind, err)
+	}
+
+	errs := make([]error, len(n.sinks))
+	var wg sync.WaitGroup
+	for i, sink := range n.sinks {
+		wg.Add(1)
+		go func(i int, sink Sink) {
+			defer wg.Done()
+			errs[i] = n.deliver(ctx, sink, msg)
+		}(i, sink)
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// deliver sends msg to one sink with retries and records the outcome
+func (n *Notifier) deliver(ctx context.Context, sink Sink, msg Message) error {
+	attempts := 0
+	err := retry.Do(ctx, n.config.Retry, "notify via "+sink.Name(), func(ctx context.Context) error {
+		attempts++
+		return sink.Send(ctx, msg)
+	})
+
+	delivery := Delivery{
+		Time:     time.Now(),
+		Kind:     msg.Kind,
+		Title:    msg.Title,
+		Sink:     sink.Name(),
+		Status:   StatusDelivered,
+		Attempts: attempts,
+	}
+	if err != nil {
+		log.Printf("Failed to send %s notification via %s: %v", msg.Kind, sink.Name(), err)
+		delivery.Status = StatusFailed
+		delivery.Error = err.Error()
+		err = fmt.Errorf("%s: %w", sink.Name(), err)
+	}
+
+	if n.config.Log != nil {
+		if logErr := n.config.Log.Record(delivery); logErr != nil {
+			log.Printf("Failed to record notification delivery: %v", logErr)
+		}
+	}
+	return err
+}
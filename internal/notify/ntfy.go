@@ -0,0 +1,103 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/michaelgalloway/sophia/internal/retry"
+)
+
+// DefaultNtfyServer is the public ntfy instance
+const DefaultNtfyServer = "https://ntfy.sh"
+
+// ntfyPriorities raises the priority of messages that need attention; others use
+// the server's default
+var ntfyPriorities = map[string]int{
+	"alert": 4,
+}
+
+// NtfySink publishes messages to an ntfy topic, which phone and desktop clients
+// subscribed to the topic show as push notifications
+type NtfySink struct {
+	http   *http.Client
+	server string
+	topic  string
+	token  string
+}
+
+// NewNtfySink creates a sink publishing to topic on server, authenticating with
+// token when it is set
+func NewNtfySink(server, topic, token string) (*NtfySink, error) {
+	if topic == "" {
+		return nil, fmt.Errorf("ntfy topic not provided")
+	}
+	if server == "" {
+		server = DefaultNtfyServer
+	}
+	return &NtfySink{
+		http:   &http.Client{Timeout: 30 * time.Second},
+		server: strings.TrimRight(server, "/"),
+		topic:  topic,
+		token:  token,
+	}, nil
+}
+
+func (s *NtfySink) Name() string {
+	return "ntfy"
+}
+
+// ntfyMessage is ntfy's JSON publishing format, which unlike its header format
+// carries non-ASCII titles as is
+type ntfyMessage struct {
+	Topic    string   `json:"topic"`
+	Title    string   `json:"title,omitempty"`
+	Message  string   `json:"message"`
+	Click    string   `json:"click,omitempty"`
+	Tags     []string `json:"tags,omitempty"`
+	Priority int      `json:"priority,omitempty"`
+	Markdown bool     `json:"markdown"`
+}
+
+func (s *NtfySink) Send(ctx context.Context, msg Message) error {
+	payload := ntfyMessage{
+		Topic:    s.topic,
+		Title:    msg.Title,
+		Message:  msg.Body,
+		Click:    msg.URL,
+		Priority: ntfyPriorities[msg.Kind],
+		Markdown: true,
+	}
+	if msg.Kind != "" {
+		payload.Tags = []string{msg.Kind}
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return retry.Permanent(fmt.Errorf("failed to encode message: %w", err))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.server, bytes.NewReader(body))
+	if err != nil {
+		return retry.Permanent(fmt.Errorf("failed to create request: %w", err))
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.token)
+	}
+
+	resp, err := s.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to publish to ntfy: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return retry.NewStatusError(resp)
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/slack-go/slack"
+
+	"github.com/michaelgalloway/sophia/internal/retry"
+)
+
+// SlackSink posts messages to a channel, or as a direct message when the
+// channel is a user ID
+type SlackSink struct {
+	client  *slack.Client
+	channel string
+}
+
+// NewSlackSink creates a Slack sink posting as the bot behind token
+func NewSlackSink(token, channel string) (*SlackSink, error) {
+	if token == "" {
+		return nil, fmt.Errorf("Slack token not provided")
+	}
+	if channel == "" {
+		return nil, fmt.Errorf("Slack channel not provided")
+	}
+	return &SlackSink{client: slack.New(token), channel: channel}, nil
+}
+
+func (s *SlackSink) Name() string {
+	return "slack"
+}
+
+func (s *SlackSink) Send(ctx context.Context, msg Message) error {
+	text := fmt.Sprintf("*%s*\n%s", msg.Title, msg.Body)
+	if msg.URL != "" {
+		text += "\n" + msg.URL
+	}
+
+	_, _, err := s.client.PostMessageContext(ctx, s.channel,
+		slack.MsgOptionText(text, false),
+		slack.MsgOptionDisableLinkUnfurl(),
+	)
+	if err != nil {
+		// Errors reported by the API, such as channel_not_found, won't succeed on retry
+		var apiErr slack.SlackErrorResponse
+		if errors.As(err, &apiErr) {
+			return retry.Permanent(fmt.Errorf("failed to post Slack message: %w", err))
+		}
+		return fmt.Errorf("failed to post Slack message: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+
+	"github.com/michaelgalloway/sophia/internal/retry"
+)
+
+// SMTPConfig holds the mail server and addresses for email notifications
+type SMTPConfig struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+	To       []string
+}
+
+// SMTPSink emails messages as plain text
+type SMTPSink struct {
+	config SMTPConfig
+}
+
+// NewSMTPSink creates an email sink. Authentication is used when a username is set.
+func NewSMTPSink(config SMTPConfig) (*SMTPSink, error) {
+	if config.Host == "" {
+		return nil, fmt.Errorf("SMTP host not provided")
+	}
+	if config.From == "" || len(config.To) == 0 {
+		return nil, fmt.Errorf("SMTP from and to addresses are required")
+	}
+	if config.Port == 0 {
+		config.Port = 587
+	}
+	return &SMTPSink{config: config}, nil
+}
+
+func (s *SMTPSink) Name() string {
+	return "smtp"
+}
+
+func (s *SMTPSink) Send(ctx context.Context, msg Message) error {
+	var auth smtp.Auth
+	if s.config.Username != "" {
+		auth = smtp.PlainAuth("", s.config.Username, s.config.Password, s.config.Host)
+	}
+
+	body := msg.Body
+	if msg.URL != "" {
+		body += "\n\n" + msg.URL
+	}
+
+	var sb strings.Builder
+	sb.WriteString("From: " + s.config.From + "\r\n")
+	sb.WriteString("To: " + strings.Join(s.config.To, ", ") + "\r\n")
+	sb.WriteString("Subject: " + mime.QEncoding.Encode("utf-8", msg.Title) + "\r\n")
+	sb.WriteString("MIME-Version: 1.0\r\n")
+	sb.WriteString("Content-Type: text/plain; charset=\"UTF-8\"\r\n")
+	sb.WriteString("\r\n")
+	sb.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+
+	// net/smtp has no context support, so the deadline is only honored before sending
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	addr := net.JoinHostPort(s.config.Host, strconv.Itoa(s.config.Port))
+	if err := smtp.SendMail(addr, auth, s.config.From, s.config.To, []byte(sb.String())); err != nil {
+		// 5xx replies, such as a rejected login or recipient, won't succeed on retry
+		var protoErr *textproto.Error
+		if errors.As(err, &protoErr) && protoErr.Code >= 500 {
+			return retry.Permanent(fmt.Errorf("failed to send email: %w", err))
+		}
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package notify
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// titleTemplate is the name of the optional template that rewrites the title
+const titleTemplate = "title"
+
+// templateFuncs are available to every template
+var templateFuncs = template.FuncMap{
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+	"trim":  strings.TrimSpace,
+	"date": func(layout string, t time.Time) string {
+		return t.Local().Format(layout)
+	},
+}
+
+// Templates rewrite messages by kind with text/template. Each kind has its own
+// file, <kind>.tmpl, which renders the body and may {{define "title"}} as well.
+// Templates receive the Message, so {{.Body}} keeps the default text and {{.Data}}
+// reaches the structured content.
+type Templates struct {
+	byKind map[string]*template.Template
+}
+
+// LoadTemplates parses every .tmpl file in dir
+func LoadTemplates(dir string) (*Templates, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.tmpl"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list templates: %w", err)
+	}
+
+	t := &Templates{byKind: make(map[string]*template.Template)}
+	for _, path := range paths {
+		text, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read template %s: %w", path, err)
+		}
+		kind := strings.TrimSuffix(filepath.Base(path), ".tmpl")
+		tmpl, err := template.New(kind).Funcs(templateFuncs).Option("missingkey=zero").Parse(string(text))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse template %s: %w", path, err)
+		}
+		t.byKind[kind] = tmpl
+	}
+	return t, nil
+}
+
+// Kinds lists the message kinds that have a template
+func (t *Templates) Kinds() []string {
+	if t == nil {
+		return nil
+	}
+	kinds := make([]string, 0, len(t.byKind))
+	for kind := range t.byKind {
+		kinds = append(kinds, kind)
+	}
+	return kinds
+}
+
+// Render applies the template for msg's kind. Messages without one, or with a
+// nil Templates, are returned unchanged; so is msg when rendering fails.
+func (t *Templates) Render(msg Message) (Message, error) {
+	if t == nil {
+		return msg, nil
+	}
+	tmpl, ok := t.byKind[msg.Kind]
+	if !ok {
+		return msg, nil
+	}
+
+	rendered := msg
+	var buf bytes.Buffer
+	if tmpl.Lookup(titleTemplate) != nil {
+		if err := tmpl.ExecuteTemplate(&buf, titleTemplate, msg); err != nil {
+			return msg, fmt.Errorf("failed to render %s title: %w", msg.Kind, err)
+		}
+		rendered.Title = strings.TrimSpace(buf.String())
+		buf.Reset()
+	}
+
+	if err := tmpl.Execute(&buf, msg); err != nil {
+		return msg, fmt.Errorf("failed to render %s body: %w", msg.Kind, err)
+	}
+	rendered.Body = strings.TrimSpace(buf.String())
+	return rendered, nil
+}
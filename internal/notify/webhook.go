@@ -0,0 +1,86 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/michaelgalloway/sophia/internal/retry"
+)
+
+// Headers set on signed webhook requests
+const (
+	TimestampHeader = "X-Sophia-Timestamp"
+	SignatureHeader = "X-Sophia-Signature"
+)
+
+// WebhookSink posts messages as JSON to an HTTP endpoint
+type WebhookSink struct {
+	http   *http.Client
+	url    string
+	secret []byte
+}
+
+// NewWebhookSink creates a sink posting to url. With a secret, each request is
+// signed so the receiver can verify it came from Sophia; see Sign.
+func NewWebhookSink(url, secret string) (*WebhookSink, error) {
+	if url == "" {
+		return nil, fmt.Errorf("webhook URL not provided")
+	}
+	return &WebhookSink{
+		http:   &http.Client{Timeout: 30 * time.Second},
+		url:    url,
+		secret: []byte(secret),
+	}, nil
+}
+
+func (s *WebhookSink) Name() string {
+	return "webhook"
+}
+
+func (s *WebhookSink) Send(ctx context.Context, msg Message) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return retry.Permanent(fmt.Errorf("failed to encode message: %w", err))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return retry.Permanent(fmt.Errorf("failed to create request: %w", err))
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	if len(s.secret) > 0 {
+		// Signed per attempt, so a retried request carries a fresh timestamp
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set(TimestampHeader, timestamp)
+		req.Header.Set(SignatureHeader, Sign(s.secret, timestamp, body))
+	}
+
+	resp, err := s.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to call webhook: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return retry.NewStatusError(resp)
+	}
+	return nil
+}
+
+// Sign returns the signature header value for a webhook request: "sha256=" and
+// the hex HMAC-SHA256 of the timestamp, a dot and the body
+func Sign(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,345 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/michaelgalloway/sophia/internal/database"
+	"github.com/michaelgalloway/sophia/internal/datasources"
+	"github.com/michaelgalloway/sophia/internal/embeddings"
+	"github.com/michaelgalloway/sophia/internal/retry"
+)
+
+// ErrClosed is returned when documents are submitted to a closed pipeline
+var ErrClosed = errors.New("pipeline closed")
+
+// Config controls batching and concurrency of the pipeline
+type Config struct {
+	// Workers is the number of embedding requests in flight across all sources
+	Workers int
+
+	// BatchSize is the number of chunks sent in each embedding request
+	BatchSize int
+
+	// QueueSize bounds the number of batches waiting between stages
+	QueueSize int
+
+	// ChunkSize and ChunkOverlap control how documents are split, in characters
+	ChunkSize    int
+	ChunkOverlap int
+
+	// Retry controls how failed writes to the vector database are retried
+	Retry retry.Policy
+}
+
+// Result summarizes a single Process call
+type Result struct {
+	Documents int `json:"documents"`
+	Chunks    int `json:"chunks"`
+	Unchanged int `json:"unchanged"`
+	Embedded  int `json:"embedded"`
+	Stored    int `json:"stored"`
+	Failed    int `json:"failed"`
+	Deleted   int `json:"deleted"`
+}
+
+// StoredHook is called with each batch of chunks, and their embeddings, right
+// after it is stored. Hooks run on the goroutine storing the batch and must be
+// safe for concurrent use, since sources are processed in parallel.
+type StoredHook func(ctx context.Context, source string, docs []datasources.Document, vectors []embeddings.Vector)
+
+// Pipeline moves documents through chunk → embed → store stages. Chunks whose content
+// hash matches what is already stored skip embedding and only have their metadata refreshed. Embedding runs on a
+// worker pool shared by every caller, so concurrent source syncs stay within the
+// configured number of in-flight requests, and bounded queues apply backpressure
+// to the earlier stages.
+type Pipeline struct {
+	embeddingService embeddings.EmbeddingService
+	vectorDB         database.VectorDB
+	config           Config
+	hooks            []StoredHook
+	jobs             chan job
+	done             chan struct{}
+	closeOnce        sync.Once
+}
+
+// batch is a group of chunks embedded in one request, along with chunks
+// from the same group that are already stored with identical content
+type batch struct {
+	docs      []datasources.Document
+	unchanged []datasources.Document
+	vectors   []embeddings.Vector
+	err       error
+}
+
+// job is a batch queued for the worker pool
+type job struct {
+	ctx    context.Context
+	batch  *batch
+	result chan<- *batch
+	wg     *sync.WaitGroup
+}
+
+// New creates a pipeline and starts its embedding workers
+func New(embeddingService embeddings.EmbeddingService, vectorDB database.VectorDB, config Config) *Pipeline {
+	if config.Workers <= 0 {
+		config.Workers = 4
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = 100
+	}
+	if config.QueueSize <= 0 {
+		config.QueueSize = config.Workers * 2
+	}
+	if config.ChunkSize <= 0 {
+		config.ChunkSize = datasources.MaxContentLength
+	}
+	if config.ChunkOverlap <= 0 {
+		config.ChunkOverlap = datasources.DefaultChunkOverlap
+	}
+
+	p := &Pipeline{
+		embeddingService: embeddingService,
+		vectorDB:         vectorDB,
+		config:           config,
+		jobs:             make(chan job),
+		done:             make(chan struct{}),
+	}
+
+	for i := 0; i < config.Workers; i++ {
+		go p.worker()
+	}
+	return p
+}
+
+// OnStored registers a hook run after every stored batch. Hooks must be
+// registered before documents are processed.
+func (p *Pipeline) OnStored(hook StoredHook) {
+	p.hooks = append(p.hooks, hook)
+}
+
+// Close stops the workers. Batches still queued fail with ErrClosed.
+func (p *Pipeline) Close() {
+	p.closeOnce.Do(func() {
+		close(p.done)
+	})
+}
+
+// Process chunks, embeds and stores docs, returning once every chunk has been handled.
+// The first failure cancels the remaining work for this call.
+func (p *Pipeline) Process(ctx context.Context, name string, docs []datasources.Document) (Result, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	result := Result{Documents: len(docs)}
+
+	// Removals skip the other stages entirely
+	var deleted []string
+	var live []datasources.Document
+	for _, doc := range docs {
+		if doc.Deleted {
+			deleted = append(deleted, doc.ID)
+		} else {
+			live = append(live, doc)
+		}
+	}
+	if len(deleted) > 0 {
+		err := retry.Do(ctx, p.config.Retry, "delete "+name, func(ctx context.Context) error {
+			return p.vectorDB.Delete(ctx, deleted)
+		})
+		if err != nil {
+			return result, fmt.Errorf("failed to delete documents: %w", err)
+		}
+		result.Deleted = len(deleted)
+		log.Printf("Deleted %d documents for %s", len(deleted), name)
+	}
+
+	docs = live
+	if len(docs) == 0 {
+		return result, nil
+	}
+
+	chunked := make(chan []datasources.Document, p.config.QueueSize)
+	embedded := make(chan *batch, p.config.QueueSize)
+
+	go p.chunk(ctx, docs, chunked)
+	go p.embed(ctx, chunked, embedded)
+
+	// Store stage
+	var firstErr error
+	for b := range embedded {
+		result.Chunks += len(b.docs) + len(b.unchanged)
+		if firstErr != nil {
+			continue
+		}
+
+		var partial *embeddings.PartialError
+		if errors.As(b.err, &partial) {
+			// Store what succeeded; the failures are picked up again next sync
+			result.Failed += len(partial.Failed)
+			log.Printf("Skipping %d chunks for %s that failed to embed: %v", len(partial.Failed), name, partial)
+			b.docs, b.vectors = withoutFailed(b.docs, b.vectors, partial)
+		} else if b.err != nil {
+			firstErr = fmt.Errorf("failed to create embeddings: %w", b.err)
+			cancel()
+			continue
+		}
+
+		if len(b.unchanged) > 0 {
+			err := retry.Do(ctx, p.config.Retry, "update "+name, func(ctx context.Context) error {
+				return p.vectorDB.UpdateMetadata(ctx, b.unchanged)
+			})
+			if err != nil {
+				firstErr = fmt.Errorf("failed to update unchanged documents: %w", err)
+				cancel()
+				continue
+			}
+			result.Unchanged += len(b.unchanged)
+		}
+		if len(b.docs) == 0 {
+			continue
+		}
+
+		if len(b.vectors) != len(b.docs) {
+			firstErr = fmt.Errorf("received %d embeddings for %d chunks", len(b.vectors), len(b.docs))
+			cancel()
+			continue
+		}
+		result.Embedded += len(b.vectors)
+
+		err := retry.Do(ctx, p.config.Retry, "store "+name, func(ctx context.Context) error {
+			return p.vectorDB.Store(ctx, b.docs, b.vectors)
+		})
+		if err != nil {
+			firstErr = fmt.Errorf("failed to store embeddings: %w", err)
+			cancel()
+			continue
+		}
+		result.Stored += len(b.docs)
+		log.Printf("Stored %d/%d chunks for %s", result.Stored, result.Chunks, name)
+
+		for _, hook := range p.hooks {
+			hook(ctx, name, b.docs, b.vectors)
+		}
+	}
+
+	if firstErr == nil {
+		firstErr = ctx.Err()
+	}
+	return result, firstErr
+}
+
+// withoutFailed drops the documents, and their nil vectors, listed in a partial failure
+func withoutFailed(docs []datasources.Document, vectors []embeddings.Vector, partial *embeddings.PartialError) ([]datasources.Document, []embeddings.Vector) {
+	var keptDocs []datasources.Document
+	var keptVectors []embeddings.Vector
+	for i := range docs {
+		if _, failed := partial.Failed[i]; failed || i >= len(vectors) {
+			continue
+		}
+		keptDocs = append(keptDocs, docs[i])
+		keptVectors = append(keptVectors, vectors[i])
+	}
+	return keptDocs, keptVectors
+}
+
+// chunk splits documents and groups the chunks into batches
+func (p *Pipeline) chunk(ctx context.Context, docs []datasources.Document, out chan<- []datasources.Document) {
+	defer close(out)
+
+	var pending []datasources.Document
+	send := func() bool {
+		select {
+		case out <- pending:
+			pending = nil
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	for _, doc := range docs {
+		for _, c := range datasources.Chunk(doc, p.config.ChunkSize, p.config.ChunkOverlap) {
+			pending = append(pending, c)
+			if len(pending) == p.config.BatchSize && !send() {
+				return
+			}
+		}
+	}
+	if len(pending) > 0 {
+		send()
+	}
+}
+
+// embed hands batches to the shared worker pool and closes out once all have returned
+func (p *Pipeline) embed(ctx context.Context, in <-chan []datasources.Document, out chan<- *batch) {
+	var wg sync.WaitGroup
+	defer func() {
+		wg.Wait()
+		close(out)
+	}()
+
+	for docs := range in {
+		changed, unchanged, err := p.partition(ctx, docs)
+		b := &batch{docs: changed, unchanged: unchanged, err: err}
+		if err != nil || len(changed) == 0 {
+			out <- b
+			continue
+		}
+
+		wg.Add(1)
+
+		select {
+		case p.jobs <- job{ctx: ctx, batch: b, result: out, wg: &wg}:
+		case <-ctx.Done():
+			wg.Done()
+		case <-p.done:
+			b.err = ErrClosed
+			out <- b
+			wg.Done()
+		}
+	}
+}
+
+// partition separates chunks that need embedding from those already stored with the same content
+func (p *Pipeline) partition(ctx context.Context, docs []datasources.Document) (changed, unchanged []datasources.Document, err error) {
+	ids := make([]string, len(docs))
+	for i, doc := range docs {
+		ids[i] = doc.ID
+	}
+
+	hashes, err := p.vectorDB.ContentHashes(ctx, ids)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to look up content hashes: %w", err)
+	}
+
+	for _, doc := range docs {
+		if hash, ok := hashes[doc.ID]; ok && hash == doc.ContentHash() {
+			unchanged = append(unchanged, doc)
+		} else {
+			changed = append(changed, doc)
+		}
+	}
+	return changed, unchanged, nil
+}
+
+// worker embeds batches until the pipeline is closed
+func (p *Pipeline) worker() {
+	for {
+		select {
+		case j := <-p.jobs:
+			if err := j.ctx.Err(); err != nil {
+				j.batch.err = err
+			} else {
+				j.batch.vectors, j.batch.err = p.embeddingService.CreateEmbeddings(j.ctx, j.batch.docs)
+			}
+			j.result <- j.batch
+			j.wg.Done()
+		case <-p.done:
+			return
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package prep
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/michaelgalloway/sophia/internal/database"
+	"github.com/michaelgalloway/sophia/internal/datasources"
+)
+
+const (
+	// findWindow bounds the meetings Find considers, from findPast ago onwards
+	findWindow = 7 * 24 * time.Hour
+	findPast   = 12 * time.Hour
+
+	// timeTolerance is how far a meeting's start may be from the time asked for
+	timeTolerance = 30 * time.Minute
+)
+
+// clockPattern matches times such as "2pm", "2:30 pm" and "14:30"
+var clockPattern = regexp.MustCompile(`(?i)\b(\d{1,2})(?::(\d{2}))?\s*([ap]\.?m\.?)|\b(\d{1,2}):(\d{2})\b`)
+
+// stopWords are left out when matching a query against meeting titles
+var stopWords = map[string]bool{
+	"prep": true, "prepare": true, "for": true, "my": true, "the": true, "with": true,
+	"meeting": true, "call": true, "sync": true, "today": true, "tomorrow": true, "next": true,
+}
+
+// attendee is a guest of a calendar event other than the user
+type attendee struct {
+	email string
+	name  string
+}
+
+func (a attendee) label() string {
+	if a.name != "" && a.email != "" {
+		return fmt.Sprintf("%s <%s>", a.name, a.email)
+	}
+	if a.name != "" {
+		return a.name
+	}
+	return a.email
+}
+
+// Find resolves a query to a calendar event. The query may be an event ID, a time
+// such as "2pm" or "prep for my 14:30", words from the title, or empty or "next"
+// for the next meeting.
+func (g *Generator) Find(ctx context.Context, query string, now time.Time) (datasources.Document, error) {
+	events, err := g.upcoming(ctx, now.Add(-findPast), now.Add(findWindow))
+	if err != nil {
+		return datasources.Document{}, err
+	}
+
+	query = strings.TrimSpace(query)
+	for _, event := range events {
+		if event.ParentID == query {
+			return event, nil
+		}
+	}
+
+	if query == "" || strings.EqualFold(query, "next") {
+		for _, event := range events {
+			if event.Timestamp.After(now) {
+				return event, nil
+			}
+		}
+		return datasources.Document{}, ErrNoEvent
+	}
+
+	if target, ok := parseClock(query, now); ok {
+		var best datasources.Document
+		bestDiff := timeTolerance + 1
+		for _, event := range events {
+			diff := event.Timestamp.Sub(target)
+			if diff < 0 {
+				diff = -diff
+			}
+			if diff < bestDiff {
+				best, bestDiff = event, diff
+			}
+		}
+		if bestDiff > timeTolerance {
+			return datasources.Document{}, fmt.Errorf("%w at %s", ErrNoEvent, target.Format("Jan 2 15:04"))
+		}
+		return best, nil
+	}
+
+	// Pick the meeting whose title shares the most words with the query, soonest first
+	words := titleWords(query)
+	var best datasources.Document
+	bestScore := 0
+	for _, event := range events {
+		if event.Timestamp.Before(now.Add(-timeTolerance)) {
+			continue
+		}
+		title := strings.ToLower(event.Title)
+		score := 0
+		for _, word := range words {
+			if strings.Contains(title, word) {
+				score++
+			}
+		}
+		if score > bestScore {
+			best, bestScore = event, score
+		}
+	}
+	if bestScore == 0 {
+		return datasources.Document{}, ErrNoEvent
+	}
+	return best, nil
+}
+
+// upcoming lists timed calendar events starting in [from, to) that the user hasn't declined
+func (g *Generator) upcoming(ctx context.Context, from, to time.Time) ([]datasources.Document, error) {
+	docs, err := g.db.List(ctx, database.ListOptions{
+		Source: "google_calendar",
+		Since:  from,
+		Until:  to,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list calendar events: %w", err)
+	}
+
+	var events []datasources.Document
+	for _, doc := range docs {
+		if metaBool(doc, "all_day") || metaString(doc, "my_response") == "declined" {
+			continue
+		}
+		events = append(events, doc)
+	}
+	return events, nil
+}
+
+// parseClock finds a time of day in the query and returns its next occurrence,
+// allowing for meetings that started up to an hour ago
+func parseClock(query string, now time.Time) (time.Time, bool) {
+	m := clockPattern.FindStringSubmatch(query)
+	if m == nil {
+		return time.Time{}, false
+	}
+
+	var hour, minute int
+	if m[1] != "" {
+		hour, _ = strconv.Atoi(m[1])
+		minute, _ = strconv.Atoi(m[2])
+		if hour < 1 || hour > 12 {
+			return time.Time{}, false
+		}
+		pm := strings.HasPrefix(strings.ToLower(m[3]), "p")
+		if hour == 12 {
+			hour = 0
+		}
+		if pm {
+			hour += 12
+		}
+	} else {
+		hour, _ = strconv.Atoi(m[4])
+		minute, _ = strconv.Atoi(m[5])
+	}
+	if hour > 23 || minute > 59 {
+		return time.Time{}, false
+	}
+
+	target := time.Date(now.Year(), now.Month(), now.Day(), hour, minute, 0, 0, now.Location())
+	if target.Before(now.Add(-time.Hour)) {
+		target = target.AddDate(0, 0, 1)
+	}
+	return target, true
+}
+
+// titleWords returns the lowercased words of a query worth matching against titles
+func titleWords(query string) []string {
+	var words []string
+	for _, word := range strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r > 127)
+	}) {
+		if len(word) > 2 && !stopWords[word] {
+			words = append(words, word)
+		}
+	}
+	return words
+}
+
+// attendees returns an event's guests, leaving out the user
+func attendees(event datasources.Document) []attendee {
+	list, _ := event.Metadata["attendees"].([]interface{})
+
+	var result []attendee
+	for _, entry := range list {
+		a, ok := entry.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if self, _ := a["self"].(bool); self {
+			continue
+		}
+		email, _ := a["email"].(string)
+		name, _ := a["name"].(string)
+		if email == "" && name == "" {
+			continue
+		}
+		result = append(result, attendee{email: strings.ToLower(email), name: name})
+	}
+	return result
+}
+
+func eventEnd(event datasources.Document) time.Time {
+	end, err := time.Parse(time.RFC3339, metaString(event, "end_time"))
+	if err != nil {
+		return time.Time{}
+	}
+	return end
+}
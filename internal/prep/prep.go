@@ -0,0 +1,297 @@
+package prep
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/michaelgalloway/sophia/internal/database"
+	"github.com/michaelgalloway/sophia/internal/datasources"
+	"github.com/michaelgalloway/sophia/internal/embeddings"
+	"github.com/michaelgalloway/sophia/internal/notify"
+)
+
+const (
+	defaultLeadTime = 15 * time.Minute
+	defaultLookback = 30 * 24 * time.Hour
+
+	// maxExcerpt bounds how much of each document is given to the model
+	maxExcerpt = 800
+)
+
+// instructions tell the chat model how to write a prep pack
+const instructions = `You help the user prepare for an upcoming meeting. From the meeting details and the
+related emails, documents and Slack threads below, write short prep notes: what the meeting is likely
+about, where each topic stands according to the recent discussion, decisions or questions still open,
+and anything the user promised or was asked to do. Mention who said what when it matters. Only use the
+material given; if it says little about the meeting, say so briefly. Use plain text with short bullet lists.`
+
+// ErrNoEvent is returned when no calendar event matches a query
+var ErrNoEvent = errors.New("no matching meeting found")
+
+// Summarizer turns the assembled material into prose
+type Summarizer interface {
+	Summarize(ctx context.Context, instructions, content string) (string, error)
+}
+
+// Config controls when packs are prepared and what they include
+type Config struct {
+	// LeadTime is how long before a meeting its pack is prepared and delivered
+	LeadTime time.Duration
+	// Lookback limits related email, documents and Slack threads to ones active this recently
+	Lookback time.Duration
+}
+
+// Related is an email thread, document or Slack thread connected to a meeting
+type Related struct {
+	Source string    `json:"source"`
+	Title  string    `json:"title"`
+	URL    string    `json:"url,omitempty"`
+	Time   time.Time `json:"time"`
+	// Reason says why the item was included, e.g. "with ann@example.com"
+	Reason string `json:"reason"`
+
+	excerpt string
+}
+
+// Pack is the prep material for one meeting
+type Pack struct {
+	EventID     string    `json:"event_id"`
+	Title       string    `json:"title"`
+	URL         string    `json:"url,omitempty"`
+	Start       time.Time `json:"start"`
+	End         time.Time `json:"end"`
+	Location    string    `json:"location,omitempty"`
+	Attendees   []string  `json:"attendees"`
+	Summary     string    `json:"summary"`
+	Related     []Related `json:"related"`
+	GeneratedAt time.Time `json:"generated_at"`
+}
+
+// Generator assembles prep packs from the index and delivers them
+type Generator struct {
+	db         database.VectorDB
+	embedder   embeddings.EmbeddingService
+	summarizer Summarizer
+	notifier   *notify.Notifier
+	config     Config
+
+	mu        sync.Mutex
+	packs     map[string]*Pack
+	delivered map[string]time.Time
+	// undelivered holds packs Run prepared but could not deliver, so the next
+	// run retries the delivery without asking the model again
+	undelivered map[string]*Pack
+}
+
+// NewGenerator creates a prep pack generator. notifier may be nil when packs
+// are only read through the API.
+func NewGenerator(db database.VectorDB, embedder embeddings.EmbeddingService, summarizer Summarizer, notifier *notify.Notifier, config Config) *Generator {
+	if config.LeadTime <= 0 {
+		config.LeadTime = defaultLeadTime
+	}
+	if config.Lookback <= 0 {
+		config.Lookback = defaultLookback
+	}
+	return &Generator{
+		db:          db,
+		embedder:    embedder,
+		summarizer:  summarizer,
+		notifier:    notifier,
+		config:      config,
+		packs:       make(map[string]*Pack),
+		delivered:   make(map[string]time.Time),
+		undelivered: make(map[string]*Pack),
+	}
+}
+
+// Prepare builds the pack for a calendar event, as returned by Find
+func (g *Generator) Prepare(ctx context.Context, event datasources.Document, now time.Time) (*Pack, error) {
+	p := &Pack{
+		EventID:     event.ParentID,
+		Title:       event.Title,
+		URL:         event.URL,
+		Start:       event.Timestamp,
+		End:         eventEnd(event),
+		Location:    metaString(event, "location"),
+		GeneratedAt: now,
+	}
+	for _, a := range attendees(event) {
+		p.Attendees = append(p.Attendees, a.label())
+	}
+
+	related, err := g.related(ctx, event, now)
+	if err != nil {
+		return nil, err
+	}
+	p.Related = related
+
+	p.Summary, err = g.summarizer.Summarize(ctx, instructions, p.prompt(event))
+	if err != nil {
+		return nil, err
+	}
+
+	g.mu.Lock()
+	g.packs[p.EventID] = p
+	g.mu.Unlock()
+	return p, nil
+}
+
+// Cached returns the pack last prepared for an event, if any
+func (g *Generator) Cached(eventID string) (*Pack, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	p, ok := g.packs[eventID]
+	return p, ok
+}
+
+// Deliver sends a pack to every notification sink
+func (g *Generator) Deliver(ctx context.Context, p *Pack) error {
+	if g.notifier == nil || !g.notifier.Enabled() {
+		return nil
+	}
+	return g.notifier.Send(ctx, notify.Message{
+		Kind:  "prep",
+		Title: fmt.Sprintf("Prep for %s at %s", p.Title, p.Start.Local().Format("15:04")),
+		Body:  p.Text(),
+		URL:   p.URL,
+		Data:  p,
+	})
+}
+
+// Run prepares and delivers packs for meetings starting within the lead time.
+// It is the scheduled job, run every minute; each meeting is delivered once.
+func (g *Generator) Run(ctx context.Context) error {
+	now := time.Now()
+	events, err := g.upcoming(ctx, now, now.Add(g.config.LeadTime))
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, event := range events {
+		// Time blocked out alone, such as focus time, needs no prep
+		if len(attendees(event)) == 0 {
+			continue
+		}
+
+		key := event.ParentID + "@" + event.Timestamp.Format(time.RFC3339)
+		g.mu.Lock()
+		_, done := g.delivered[key]
+		p, prepared := g.undelivered[key]
+		g.mu.Unlock()
+		if done {
+			continue
+		}
+
+		if !prepared {
+			var err error
+			if p, err = g.Prepare(ctx, event, now); err != nil {
+				errs = append(errs, fmt.Errorf("failed to prepare %q: %w", event.Title, err))
+				continue
+			}
+		}
+		if err := g.Deliver(ctx, p); err != nil {
+			g.mu.Lock()
+			g.undelivered[key] = p
+			g.mu.Unlock()
+			errs = append(errs, fmt.Errorf("failed to deliver prep for %q: %w", event.Title, err))
+			continue
+		}
+
+		g.mu.Lock()
+		g.delivered[key] = event.Timestamp
+		delete(g.undelivered, key)
+		g.mu.Unlock()
+	}
+
+	g.prune(now)
+	return errors.Join(errs...)
+}
+
+// prune forgets packs and deliveries for meetings that started over a day ago
+func (g *Generator) prune(now time.Time) {
+	cutoff := now.Add(-24 * time.Hour)
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for key, start := range g.delivered {
+		if start.Before(cutoff) {
+			delete(g.delivered, key)
+		}
+	}
+	for id, p := range g.packs {
+		if p.Start.Before(cutoff) {
+			delete(g.packs, id)
+		}
+	}
+	for key, p := range g.undelivered {
+		if p.Start.Before(cutoff) {
+			delete(g.undelivered, key)
+		}
+	}
+}
+
+// Text renders the pack as a notification: when and with whom, the notes, then links
+func (p *Pack) Text() string {
+	var sb strings.Builder
+	sb.WriteString(p.when())
+	if len(p.Attendees) > 0 {
+		sb.WriteString("\nWith " + strings.Join(p.Attendees, ", "))
+	}
+	sb.WriteString("\n\n" + strings.TrimSpace(p.Summary))
+
+	if len(p.Related) > 0 {
+		sb.WriteString("\n\nRelated:")
+		for _, item := range p.Related {
+			line := fmt.Sprintf("%s (%s, %s)", item.Title, sourceLabels[item.Source], item.Reason)
+			if item.URL != "" {
+				line += " " + item.URL
+			}
+			sb.WriteString("\n- " + line)
+		}
+	}
+	return sb.String()
+}
+
+// prompt lays out the meeting and related items for the model
+func (p *Pack) prompt(event datasources.Document) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "## Meeting\n\n%s\n%s\n", p.Title, p.when())
+	if len(p.Attendees) > 0 {
+		fmt.Fprintf(&sb, "Attendees: %s\n", strings.Join(p.Attendees, ", "))
+	}
+	if text := excerpt(event.Content); text != "" {
+		sb.WriteString("\n" + text + "\n")
+	}
+
+	if len(p.Related) == 0 {
+		sb.WriteString("\nNo related emails, documents or Slack threads were found.\n")
+		return sb.String()
+	}
+
+	sb.WriteString("\n## Related material\n")
+	for _, item := range p.Related {
+		fmt.Fprintf(&sb, "\n### %s: %s\n", sourceLabels[item.Source], item.Title)
+		fmt.Fprintf(&sb, "%s, last active %s\n", item.Reason, item.Time.Local().Format("Jan 2 15:04"))
+		if item.excerpt != "" {
+			sb.WriteString(item.excerpt + "\n")
+		}
+	}
+	return sb.String()
+}
+
+func (p *Pack) when() string {
+	start := p.Start.Local()
+	when := start.Format("Monday, January 2, 15:04")
+	if !p.End.IsZero() {
+		when += "–" + p.End.Local().Format("15:04")
+	}
+	if p.Location != "" {
+		when += ", " + p.Location
+	}
+	return when
+}
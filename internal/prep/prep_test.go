@@ -0,0 +1,99 @@
+package prep
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/michaelgalloway/sophia/internal/database"
+	"github.com/michaelgalloway/sophia/internal/datasources"
+	"github.com/michaelgalloway/sophia/internal/notify"
+)
+
+// calendarDB lists a fixed set of calendar events and nothing else
+type calendarDB struct {
+	database.VectorDB
+	events []datasources.Document
+}
+
+func (db *calendarDB) List(ctx context.Context, opts database.ListOptions) ([]datasources.Document, error) {
+	if opts.Source != "google_calendar" {
+		return nil, nil
+	}
+	return db.events, nil
+}
+
+// countingSummarizer counts how often a pack is written
+type countingSummarizer struct {
+	mu    sync.Mutex
+	calls int
+}
+
+func (s *countingSummarizer) Summarize(ctx context.Context, instructions, content string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls++
+	return "- Review the launch checklist", nil
+}
+
+// flakySink fails until it is brought back up
+type flakySink struct {
+	mu   sync.Mutex
+	down bool
+	sent []notify.Message
+}
+
+func (s *flakySink) Name() string { return "flaky" }
+
+func (s *flakySink) Send(ctx context.Context, msg notify.Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.down {
+		return errors.New("connection refused")
+	}
+	s.sent = append(s.sent, msg)
+	return nil
+}
+
+func TestRunRetriesDeliveryOnly(t *testing.T) {
+	start := time.Now().Add(5 * time.Minute).Truncate(time.Second)
+	db := &calendarDB{events: []datasources.Document{{
+		ID:        "evt1",
+		ParentID:  "evt1",
+		Source:    "google_calendar",
+		Title:     "Launch review",
+		Timestamp: start,
+		Metadata: map[string]interface{}{
+			"attendees": []interface{}{map[string]interface{}{"email": "ann@example.com", "name": "Ann"}},
+		},
+	}}}
+	summarizer := &countingSummarizer{}
+	sink := &flakySink{down: true}
+	g := NewGenerator(db, nil, summarizer, notify.NewNotifier(notify.Config{}, sink), Config{})
+
+	for i := 0; i < 2; i++ {
+		if err := g.Run(context.Background()); err == nil {
+			t.Fatalf("Run() %d error = nil, want the delivery failure", i+1)
+		}
+	}
+
+	sink.mu.Lock()
+	sink.down = false
+	sink.mu.Unlock()
+
+	if err := g.Run(context.Background()); err != nil {
+		t.Fatalf("Run() after recovery error = %v", err)
+	}
+	if err := g.Run(context.Background()); err != nil {
+		t.Fatalf("Run() after delivery error = %v", err)
+	}
+
+	if summarizer.calls != 1 {
+		t.Errorf("summarizer called %d times, want 1", summarizer.calls)
+	}
+	if len(sink.sent) != 1 || sink.sent[0].Kind != "prep" {
+		t.Errorf("sent %+v, want one prep message", sink.sent)
+	}
+}
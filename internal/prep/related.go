@@ -0,0 +1,158 @@
+package prep
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/michaelgalloway/sophia/internal/database"
+	"github.com/michaelgalloway/sophia/internal/datasources"
+)
+
+const (
+	// maxPerSource caps how many items of each source a pack links to
+	maxPerSource = 5
+
+	// scanLimit is how many recent documents per source are checked for attendees
+	scanLimit = 500
+
+	// searchLimit is how many documents the title search retrieves
+	searchLimit = 20
+
+	// minScore drops title search results too dissimilar to be about the meeting
+	minScore = 0.4
+)
+
+// sourceLabels names the sources a pack draws from
+var sourceLabels = map[string]string{
+	"gmail":       "email",
+	"google_docs": "doc",
+	"slack":       "Slack",
+}
+
+// related finds email threads, documents and Slack threads involving the
+// attendees or matching the meeting title, newest first
+func (g *Generator) related(ctx context.Context, event datasources.Document, now time.Time) ([]Related, error) {
+	people := attendees(event)
+	seen := make(map[string]bool)
+	counts := make(map[string]int)
+	var items []Related
+
+	add := func(doc datasources.Document, reason string) {
+		id := doc.Source + ":" + doc.ParentID
+		if seen[id] || counts[doc.Source] >= maxPerSource {
+			return
+		}
+		seen[id] = true
+		counts[doc.Source]++
+		items = append(items, Related{
+			Source:  doc.Source,
+			Title:   doc.Title,
+			URL:     doc.URL,
+			Time:    doc.Timestamp,
+			Reason:  reason,
+			excerpt: excerpt(doc.Content),
+		})
+	}
+
+	// Recent threads and documents the attendees took part in
+	if len(people) > 0 {
+		for _, source := range []string{"gmail", "google_docs", "slack"} {
+			docs, err := g.db.List(ctx, database.ListOptions{
+				Source: source,
+				Since:  now.Add(-g.config.Lookback),
+				Newest: true,
+				Limit:  scanLimit,
+			})
+			if err != nil {
+				return nil, fmt.Errorf("failed to list %s documents: %w", source, err)
+			}
+			for _, doc := range docs {
+				if who := involved(doc, people); who != "" {
+					add(doc, "with "+who)
+				}
+			}
+		}
+	}
+
+	// Anything else about the meeting's subject
+	if g.embedder != nil && strings.TrimSpace(event.Title) != "" {
+		vector, err := g.embedder.QueryEmbedding(ctx, event.Title)
+		if err != nil {
+			return nil, fmt.Errorf("failed to embed meeting title: %w", err)
+		}
+		results, err := g.db.Search(ctx, vector, database.SearchOptions{
+			Limit:    searchLimit,
+			Sources:  []string{"gmail", "google_docs", "slack"},
+			Distinct: true,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to search related documents: %w", err)
+		}
+		for _, result := range results {
+			if result.Score < minScore {
+				continue
+			}
+			add(result.Document, "matches the title")
+		}
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].Time.After(items[j].Time)
+	})
+	return items, nil
+}
+
+// involved returns the first attendee found among a document's email participants,
+// Slack participants or document owners
+func involved(doc datasources.Document, people []attendee) string {
+	var values []string
+	for _, key := range []string{"participants", "owners"} {
+		list, _ := doc.Metadata[key].([]interface{})
+		for _, v := range list {
+			if s, ok := v.(string); ok {
+				values = append(values, strings.ToLower(s))
+			}
+		}
+	}
+
+	for _, person := range people {
+		for _, value := range values {
+			// Emails list participants as "Name <address>", Slack by display name
+			if person.email != "" && strings.Contains(value, person.email) ||
+				person.name != "" && value == strings.ToLower(person.name) {
+				if person.name != "" {
+					return person.name
+				}
+				return person.email
+			}
+		}
+	}
+	return ""
+}
+
+// excerpt returns the start of a document's content for the prompt
+func excerpt(content string) string {
+	content = strings.TrimSpace(content)
+	if len(content) <= maxExcerpt {
+		return content
+	}
+	cut := maxExcerpt
+	for cut > 0 && !utf8.RuneStart(content[cut]) {
+		cut--
+	}
+	return content[:cut] + "…"
+}
+
+func metaString(doc datasources.Document, key string) string {
+	s, _ := doc.Metadata[key].(string)
+	return s
+}
+
+func metaBool(doc datasources.Document, key string) bool {
+	b, _ := doc.Metadata[key].(bool)
+	return b
+}
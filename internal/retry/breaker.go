@@ -0,0 +1,124 @@
+package retry
+
+import (
+	"sync"
+	"time"
+)
+
+// Circuit states
+const (
+	StateClosed   = "closed"
+	StateOpen     = "open"
+	StateHalfOpen = "half_open"
+)
+
+// BreakerState is a snapshot of a circuit breaker
+type BreakerState struct {
+	State     string    `json:"state"`
+	Failures  int       `json:"consecutive_failures"`
+	OpenUntil time.Time `json:"open_until,omitempty"`
+	LastError string    `json:"last_error,omitempty"`
+}
+
+// Breaker stops calling an operation after repeated consecutive failures. Once
+// open it rejects calls until the cooldown passes, then lets a single trial call
+// through; success closes it again and failure reopens it with a doubled cooldown.
+type Breaker struct {
+	threshold   int
+	cooldown    time.Duration
+	maxCooldown time.Duration
+
+	mu        sync.Mutex
+	failures  int
+	trips     int
+	openUntil time.Time
+	trial     bool
+	lastError string
+}
+
+// NewBreaker creates a breaker that opens after threshold consecutive failures.
+// A threshold below 1 disables the breaker.
+func NewBreaker(threshold int, cooldown time.Duration) *Breaker {
+	if cooldown <= 0 {
+		cooldown = 15 * time.Minute
+	}
+	return &Breaker{
+		threshold:   threshold,
+		cooldown:    cooldown,
+		maxCooldown: 24 * time.Hour,
+	}
+}
+
+// Allow reports whether a call may proceed
+func (b *Breaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.threshold < 1 || b.failures < b.threshold {
+		return true
+	}
+	if time.Now().Before(b.openUntil) || b.trial {
+		return false
+	}
+
+	// Cooldown elapsed: allow one trial call
+	b.trial = true
+	return true
+}
+
+// Success records a successful call and closes the breaker
+func (b *Breaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures = 0
+	b.trips = 0
+	b.trial = false
+	b.openUntil = time.Time{}
+	b.lastError = ""
+}
+
+// Failure records a failed call. It returns true when this failure opened the breaker.
+func (b *Breaker) Failure(err error) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	b.trial = false
+	if err != nil {
+		b.lastError = err.Error()
+	}
+
+	if b.threshold < 1 || b.failures < b.threshold {
+		return false
+	}
+
+	cooldown := b.cooldown << b.trips
+	if cooldown <= 0 || cooldown > b.maxCooldown {
+		cooldown = b.maxCooldown
+	}
+	b.trips++
+	b.openUntil = time.Now().Add(cooldown)
+	return true
+}
+
+// State returns a snapshot of the breaker
+func (b *Breaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	state := BreakerState{
+		State:     StateClosed,
+		Failures:  b.failures,
+		LastError: b.lastError,
+	}
+	if b.threshold >= 1 && b.failures >= b.threshold {
+		state.OpenUntil = b.openUntil
+		if time.Now().Before(b.openUntil) {
+			state.State = StateOpen
+		} else {
+			state.State = StateHalfOpen
+		}
+	}
+	return state
+}
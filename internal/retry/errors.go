@@ -0,0 +1,166 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/slack-go/slack"
+	"google.golang.org/api/googleapi"
+)
+
+// permanentError marks an error that must not be retried
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent wraps err so that Do returns it without retrying
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+func unwrapPermanent(err error) error {
+	var p *permanentError
+	if errors.As(err, &p) && p == err {
+		return p.err
+	}
+	return err
+}
+
+// StatusError is returned for unsuccessful HTTP responses from plain REST APIs
+type StatusError struct {
+	StatusCode int
+	Status     string
+	Body       string
+	RetryAfter time.Duration
+}
+
+func (e *StatusError) Error() string {
+	if e.Body == "" {
+		return e.Status
+	}
+	return fmt.Sprintf("%s: %s", e.Status, e.Body)
+}
+
+// NewStatusError builds a StatusError from a response, reading its body and Retry-After header
+func NewStatusError(resp *http.Response) *StatusError {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	return &StatusError{
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		Body:       string(body),
+		RetryAfter: ParseRetryAfter(resp.Header.Get("Retry-After")),
+	}
+}
+
+// ParseRetryAfter parses a Retry-After header given in seconds or as an HTTP date
+func ParseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(value); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+// RetryAfter extracts a server-provided delay from err, if there is one
+func RetryAfter(err error) (time.Duration, bool) {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) && statusErr.RetryAfter > 0 {
+		return statusErr.RetryAfter, true
+	}
+
+	var slackErr *slack.RateLimitedError
+	if errors.As(err, &slackErr) && slackErr.RetryAfter > 0 {
+		return slackErr.RetryAfter, true
+	}
+
+	var googleErr *googleapi.Error
+	if errors.As(err, &googleErr) && googleErr.Header != nil {
+		if d := ParseRetryAfter(googleErr.Header.Get("Retry-After")); d > 0 {
+			return d, true
+		}
+	}
+
+	return 0, false
+}
+
+// IsRetryable reports whether err is worth retrying. Rate limits, server errors and
+// network failures are retryable; client errors and cancellations are not.
+func IsRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var p *permanentError
+	if errors.As(err, &p) {
+		return false
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var slackErr *slack.RateLimitedError
+	if errors.As(err, &slackErr) {
+		return true
+	}
+
+	if code, ok := statusCode(err); ok {
+		return RetryableStatus(code)
+	}
+
+	// Errors without a status code are usually transport failures
+	return true
+}
+
+// RetryableStatus reports whether an HTTP status code indicates a transient failure
+func RetryableStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code == http.StatusRequestTimeout || code >= 500
+}
+
+// statusCode finds the HTTP status code carried by a provider error
+func statusCode(err error) (int, bool) {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode, true
+	}
+
+	var googleErr *googleapi.Error
+	if errors.As(err, &googleErr) {
+		return googleErr.Code, true
+	}
+
+	var apiErr *openai.APIError
+	if errors.As(err, &apiErr) && apiErr.HTTPStatusCode != 0 {
+		return apiErr.HTTPStatusCode, true
+	}
+
+	var reqErr *openai.RequestError
+	if errors.As(err, &reqErr) && reqErr.HTTPStatusCode != 0 {
+		return reqErr.HTTPStatusCode, true
+	}
+
+	var slackStatus slack.StatusCodeError
+	if errors.As(err, &slackStatus) {
+		return slackStatus.Code, true
+	}
+
+	return 0, false
+}
@@ -0,0 +1,93 @@
+package retry
+
+import (
+	"context"
+	"log"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// Policy describes how an operation is retried
+type Policy struct {
+	// MaxAttempts is the total number of attempts, including the first. Values below 1 mean a single attempt.
+	MaxAttempts int
+
+	// InitialInterval is the delay before the first retry
+	InitialInterval time.Duration
+
+	// MaxInterval caps the delay between attempts
+	MaxInterval time.Duration
+
+	// Multiplier grows the delay after each attempt
+	Multiplier float64
+
+	// Jitter randomizes each delay by up to this fraction (0.2 = ±20%)
+	Jitter float64
+}
+
+// DefaultPolicy returns the policy used when nothing else is configured
+func DefaultPolicy() Policy {
+	return Policy{
+		MaxAttempts:     4,
+		InitialInterval: time.Second,
+		MaxInterval:     time.Minute,
+		Multiplier:      2,
+		Jitter:          0.2,
+	}
+}
+
+// Backoff returns the delay before the given retry (1 for the first retry)
+func (p Policy) Backoff(retry int) time.Duration {
+	interval := p.InitialInterval
+	if interval <= 0 {
+		interval = time.Second
+	}
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+
+	delay := float64(interval) * math.Pow(multiplier, float64(retry-1))
+	if p.MaxInterval > 0 && delay > float64(p.MaxInterval) {
+		delay = float64(p.MaxInterval)
+	}
+
+	if p.Jitter > 0 {
+		delay += delay * p.Jitter * (2*rand.Float64() - 1)
+	}
+	return time.Duration(delay)
+}
+
+// Do calls fn until it succeeds, returns a non-retryable error, the attempts run out or ctx is done.
+// Delays honor any Retry-After hint carried by the error.
+func Do(ctx context.Context, policy Policy, name string, fn func(ctx context.Context) error) error {
+	attempts := policy.MaxAttempts
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		if err = fn(ctx); err == nil {
+			return nil
+		}
+		if attempt >= attempts || !IsRetryable(err) {
+			return unwrapPermanent(err)
+		}
+
+		delay := policy.Backoff(attempt)
+		if after, ok := RetryAfter(err); ok && after > delay {
+			delay = after
+		}
+
+		log.Printf("%s failed (attempt %d/%d), retrying in %v: %v",
+			name, attempt, attempts, delay.Round(time.Millisecond), err)
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return err
+		}
+	}
+}
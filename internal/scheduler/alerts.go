@@ -0,0 +1,74 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/michaelgalloway/sophia/internal/notify"
+)
+
+// Alerter notifies when a source has failed to sync several times in a row, and
+// again when it recovers. Each outage is reported once, however long it lasts.
+type Alerter struct {
+	notifier *notify.Notifier
+	after    int
+
+	mu       sync.Mutex
+	failures map[string]int
+	alerted  map[string]bool
+}
+
+// NewAlerter creates an alerter that fires after the given number of
+// consecutive failed runs of a source
+func NewAlerter(notifier *notify.Notifier, after int) *Alerter {
+	if after < 1 {
+		after = 1
+	}
+	return &Alerter{
+		notifier: notifier,
+		after:    after,
+		failures: make(map[string]int),
+		alerted:  make(map[string]bool),
+	}
+}
+
+// RunFinished is a RunHook tracking failures per source
+func (a *Alerter) RunFinished(ctx context.Context, rec RunRecord) {
+	a.mu.Lock()
+	var msg *notify.Message
+	if rec.Error != "" {
+		a.failures[rec.Source]++
+		if a.failures[rec.Source] == a.after {
+			a.alerted[rec.Source] = true
+			msg = &notify.Message{
+				Kind:  "alert",
+				Title: fmt.Sprintf("Sync of %s is failing", rec.Source),
+				Body: fmt.Sprintf("The last %d syncs of %s failed. Latest error:\n\n%s",
+					a.failures[rec.Source], rec.Source, rec.Error),
+				Data: rec,
+			}
+		}
+	} else {
+		if a.alerted[rec.Source] {
+			msg = &notify.Message{
+				Kind:  "alert",
+				Title: fmt.Sprintf("Sync of %s recovered", rec.Source),
+				Body: fmt.Sprintf("%s synced successfully after %d failed attempts.",
+					rec.Source, a.failures[rec.Source]),
+				Data: rec,
+			}
+		}
+		delete(a.failures, rec.Source)
+		delete(a.alerted, rec.Source)
+	}
+	a.mu.Unlock()
+
+	if msg == nil || !a.notifier.Enabled() {
+		return
+	}
+	if err := a.notifier.Send(ctx, *msg); err != nil {
+		log.Printf("Failed to send sync alert for %s: %v", rec.Source, err)
+	}
+}
@@ -0,0 +1,119 @@
+package scheduler
+
+import (
+	"sync"
+	"time"
+)
+
+// Trigger values describe what started a sync run
+const (
+	TriggerStartup   = "startup"
+	TriggerScheduled = "scheduled"
+	TriggerManual    = "manual"
+)
+
+// defaultHistorySize is the number of runs kept in memory
+const defaultHistorySize = 500
+
+// RunRecord describes a single sync run of a data source
+type RunRecord struct {
+	ID         int64     `json:"id"`
+	Source     string    `json:"source"`
+	Trigger    string    `json:"trigger"`
+	Full       bool      `json:"full"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	Fetched    int       `json:"fetched"`
+	Chunks     int       `json:"chunks"`
+	Unchanged  int       `json:"unchanged"`
+	Embedded   int       `json:"embedded"`
+	Stored     int       `json:"stored"`
+	Failed     int       `json:"failed"`
+	Deleted    int       `json:"deleted"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// Duration returns how long the run took, or zero if it is still running
+func (r RunRecord) Duration() time.Duration {
+	if r.FinishedAt.IsZero() {
+		return 0
+	}
+	return r.FinishedAt.Sub(r.StartedAt)
+}
+
+// History keeps a bounded, in-memory log of sync runs
+type History struct {
+	mu      sync.RWMutex
+	records []RunRecord
+	max     int
+	nextID  int64
+}
+
+// NewHistory creates a history that retains at most max runs
+func NewHistory(max int) *History {
+	if max <= 0 {
+		max = defaultHistorySize
+	}
+	return &History{max: max}
+}
+
+// begin records the start of a run and returns its ID
+func (h *History) begin(source, trigger string, full bool) RunRecord {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.nextID++
+	rec := RunRecord{
+		ID:        h.nextID,
+		Source:    source,
+		Trigger:   trigger,
+		Full:      full,
+		StartedAt: time.Now(),
+	}
+
+	h.records = append(h.records, rec)
+	if len(h.records) > h.max {
+		h.records = h.records[len(h.records)-h.max:]
+	}
+	return rec
+}
+
+// finish replaces the stored record with its completed version
+func (h *History) finish(rec RunRecord) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for i := len(h.records) - 1; i >= 0; i-- {
+		if h.records[i].ID == rec.ID {
+			h.records[i] = rec
+			return
+		}
+	}
+}
+
+// List returns up to limit runs, newest first. An empty source matches all sources.
+func (h *History) List(source string, limit int) []RunRecord {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	var out []RunRecord
+	for i := len(h.records) - 1; i >= 0; i-- {
+		if source != "" && h.records[i].Source != source {
+			continue
+		}
+		out = append(out, h.records[i])
+		if limit > 0 && len(out) == limit {
+			break
+		}
+	}
+	return out
+}
+
+// Last returns the most recent run for a source
+func (h *History) Last(source string) (RunRecord, bool) {
+	runs := h.List(source, 1)
+	if len(runs) == 0 {
+		return RunRecord{}, false
+	}
+	return runs[0], true
+}
@@ -0,0 +1,131 @@
+package scheduler
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+
+	"github.com/michaelgalloway/sophia/internal/pipeline"
+	"github.com/michaelgalloway/sophia/internal/retry"
+)
+
+// DefaultSchedule is used for sources without their own schedule
+const DefaultSchedule = "@hourly"
+
+// Config controls when each source is synced and how failures are handled
+type Config struct {
+	// DefaultSchedule applies to sources without an entry in Schedules
+	DefaultSchedule string
+
+	// Schedules maps source names to a cron expression ("*/5 * * * *", "@daily")
+	// or an interval ("15m", "24h")
+	Schedules map[string]string
+
+	// Jitter delays each scheduled run by a random amount up to this duration
+	Jitter time.Duration
+
+	// QuietHours suppresses scheduled runs during a daily window
+	QuietHours *QuietHours
+
+	// Retry controls how fetch and store failures are retried within a run
+	Retry retry.Policy
+
+	// BreakerThreshold is the number of consecutive failed runs after which a
+	// source is paused. Zero disables the circuit breaker.
+	BreakerThreshold int
+
+	// BreakerCooldown is how long a paused source waits before a trial run
+	BreakerCooldown time.Duration
+
+	// Pipeline controls chunking, batching and the embedding worker pool.
+	// Its retry policy defaults to Retry.
+	Pipeline pipeline.Config
+}
+
+// ScheduleFor returns the schedule spec configured for a source
+func (c Config) ScheduleFor(source string) string {
+	if spec, ok := c.Schedules[source]; ok && spec != "" {
+		return spec
+	}
+	if c.DefaultSchedule != "" {
+		return c.DefaultSchedule
+	}
+	return DefaultSchedule
+}
+
+// parseSchedule accepts either a Go duration or a standard cron expression
+func parseSchedule(spec string) (cron.Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	if d, err := time.ParseDuration(spec); err == nil {
+		if d <= 0 {
+			return nil, fmt.Errorf("interval must be positive: %s", spec)
+		}
+		return cron.Every(d), nil
+	}
+
+	schedule, err := cron.ParseStandard(spec)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schedule %q: %w", spec, err)
+	}
+	return schedule, nil
+}
+
+// jitter returns a random delay in [0, max)
+func jitter(max time.Duration) time.Duration {
+	if max <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(max)))
+}
+
+// QuietHours is a daily window, in local time, during which scheduled syncs are skipped.
+// The window may wrap past midnight, e.g. 22:00-07:00.
+type QuietHours struct {
+	Start time.Duration // offset from midnight
+	End   time.Duration // offset from midnight
+}
+
+// ParseQuietHours parses a window in the form "HH:MM-HH:MM"
+func ParseQuietHours(s string) (*QuietHours, error) {
+	parts := strings.Split(s, "-")
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("invalid quiet hours %q: expected HH:MM-HH:MM", s)
+	}
+
+	start, err := parseClock(parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("invalid quiet hours start: %w", err)
+	}
+	end, err := parseClock(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("invalid quiet hours end: %w", err)
+	}
+
+	return &QuietHours{Start: start, End: end}, nil
+}
+
+// Contains reports whether t falls inside the quiet window
+func (q *QuietHours) Contains(t time.Time) bool {
+	if q == nil || q.Start == q.End {
+		return false
+	}
+
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	offset := t.Sub(midnight)
+
+	if q.Start < q.End {
+		return offset >= q.Start && offset < q.End
+	}
+	return offset >= q.Start || offset < q.End
+}
+
+func parseClock(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
@@ -2,24 +2,58 @@ package scheduler
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
+	"sort"
 	"sync"
 	"time"
 
 	"github.com/michaelgalloway/sophia/internal/database"
 	"github.com/michaelgalloway/sophia/internal/datasources"
 	"github.com/michaelgalloway/sophia/internal/embeddings"
+	"github.com/michaelgalloway/sophia/internal/pipeline"
+	"github.com/michaelgalloway/sophia/internal/retry"
 	"github.com/robfig/cron/v3"
 )
 
+var (
+	// ErrUnknownSource is returned when a sync is requested for a source that isn't configured
+	ErrUnknownSource = errors.New("unknown data source")
+
+	// ErrSyncInProgress is returned when a source is already being synced
+	ErrSyncInProgress = errors.New("sync already in progress")
+
+	// ErrSourcePaused is returned when a source's circuit breaker is open
+	ErrSourcePaused = errors.New("source paused after repeated failures")
+)
+
+// SourceStatus reports the current sync state of a data source
+type SourceStatus struct {
+	Source   string             `json:"source"`
+	Running  bool               `json:"running"`
+	LastSync time.Time          `json:"last_sync"`
+	LastRun  *RunRecord         `json:"last_run,omitempty"`
+	Circuit  retry.BreakerState `json:"circuit"`
+}
+
+// RunHook is called after every sync run with its finished record
+type RunHook func(ctx context.Context, rec RunRecord)
+
 // Scheduler manages periodic data fetching from all sources
 type Scheduler struct {
-	cron             *cron.Cron
-	sources          map[string]datasources.DataSource
-	embeddingService embeddings.EmbeddingService
-	vectorDB         database.VectorDB
-	lastSync         map[string]time.Time
-	mu               sync.RWMutex
+	cron     *cron.Cron
+	sources  map[string]datasources.DataSource
+	vectorDB database.VectorDB
+	pipeline *pipeline.Pipeline
+	config   Config
+	history  *History
+	breakers map[string]*retry.Breaker
+	lastSync map[string]time.Time
+	running  map[string]bool
+	hooks    []RunHook
+	ctx      context.Context
+	mu       sync.RWMutex
 }
 
 // NewScheduler creates a new scheduler instance
@@ -27,80 +61,315 @@ func NewScheduler(
 	sources map[string]datasources.DataSource,
 	embeddingService embeddings.EmbeddingService,
 	vectorDB database.VectorDB,
+	config Config,
 ) *Scheduler {
+	breakers := make(map[string]*retry.Breaker, len(sources))
+	for name := range sources {
+		breakers[name] = retry.NewBreaker(config.BreakerThreshold, config.BreakerCooldown)
+	}
+
+	pipelineConfig := config.Pipeline
+	if pipelineConfig.Retry.MaxAttempts == 0 {
+		pipelineConfig.Retry = config.Retry
+	}
+
 	return &Scheduler{
-		cron:             cron.New(),
-		sources:          sources,
-		embeddingService: embeddingService,
-		vectorDB:         vectorDB,
-		lastSync:         make(map[string]time.Time),
+		cron:     cron.New(),
+		sources:  sources,
+		vectorDB: vectorDB,
+		pipeline: pipeline.New(embeddingService, vectorDB, pipelineConfig),
+		config:   config,
+		history:  NewHistory(defaultHistorySize),
+		breakers: breakers,
+		lastSync: make(map[string]time.Time),
+		running:  make(map[string]bool),
+		ctx:      context.Background(),
 	}
 }
 
 // Start begins the scheduling of data fetching jobs
 func (s *Scheduler) Start(ctx context.Context) error {
+	s.ctx = ctx
 
-	s.vectorDB.DeleteAll(ctx)
-
-	// Schedule hourly jobs for each source
-	for name, source := range s.sources {
-		source := source // Create new variable for closure
-		name := name
-
-		s.fetchAndProcess(ctx, source, name)
+	// Schedule jobs for each source
+	for name := range s.sources {
+		name := name // Create new variable for closure
 
-		_, err := s.cron.AddFunc("@hourly", func() {
-			s.fetchAndProcess(ctx, source, name)
-		})
+		spec := s.config.ScheduleFor(name)
+		schedule, err := parseSchedule(spec)
 		if err != nil {
-			return err
+			return fmt.Errorf("failed to schedule %s: %w", name, err)
 		}
+
+		// Initial syncs run in the background so the API is available immediately
+		go func() {
+			if err := s.runSource(ctx, name, TriggerStartup, false); err != nil {
+				log.Printf("Startup sync of %s failed: %v", name, err)
+			}
+		}()
+
+		s.cron.Schedule(schedule, cron.FuncJob(func() {
+			s.scheduledRun(ctx, name)
+		}))
+		log.Printf("Scheduled %s with %q", name, spec)
 	}
 
 	s.cron.Start()
 	return nil
 }
 
-// Stop halts all scheduled jobs
+// AddJob runs job on a schedule alongside the source syncs, such as generating a
+// briefing each morning. spec accepts the same forms as sync schedules.
+func (s *Scheduler) AddJob(name, spec string, job func(ctx context.Context) error) error {
+	schedule, err := parseSchedule(spec)
+	if err != nil {
+		return fmt.Errorf("failed to schedule %s: %w", name, err)
+	}
+
+	s.cron.Schedule(schedule, cron.FuncJob(func() {
+		if err := job(s.ctx); err != nil {
+			log.Printf("Scheduled job %s failed: %v", name, err)
+		}
+	}))
+	log.Printf("Scheduled %s with %q", name, spec)
+	return nil
+}
+
+// OnRunFinished registers a hook called after every sync run, in the goroutine
+// that ran it. Hooks must be registered before Start.
+func (s *Scheduler) OnRunFinished(hook RunHook) {
+	s.hooks = append(s.hooks, hook)
+}
+
+// OnStored registers a hook run after each batch of new or changed chunks is
+// stored, by syncs and ingestion alike. Hooks must be registered before Start.
+func (s *Scheduler) OnStored(hook pipeline.StoredHook) {
+	s.pipeline.OnStored(hook)
+}
+
+// Stop halts all scheduled jobs, waiting for running ones to finish
 func (s *Scheduler) Stop() {
-	s.cron.Stop()
+	<-s.cron.Stop().Done()
+	s.pipeline.Close()
+}
+
+// TriggerSync starts an immediate sync of a single source in the background.
+// A full sync ignores the last sync time and replaces everything stored for the source.
+// Manual syncs run even when the source is paused, and close its breaker on success.
+func (s *Scheduler) TriggerSync(name string, full bool) error {
+	rec, err := s.begin(name, TriggerManual, full, false)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		if err := s.run(s.ctx, rec); err != nil {
+			log.Printf("Manual sync of %s failed: %v", name, err)
+		}
+	}()
+	return nil
+}
+
+// Ingest stores documents pushed by a source outside its sync schedule, such as
+// real-time events, through the same chunk → embed → store pipeline
+func (s *Scheduler) Ingest(ctx context.Context, name string, docs []datasources.Document) error {
+	if _, ok := s.sources[name]; !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownSource, name)
+	}
+
+	result, err := s.pipeline.Process(ctx, name, docs)
+	if err != nil {
+		return fmt.Errorf("failed to ingest %s documents: %w", name, err)
+	}
+
+	log.Printf("Ingested %d documents for %s: chunks=%d unchanged=%d embedded=%d stored=%d failed=%d deleted=%d",
+		result.Documents, name, result.Chunks, result.Unchanged, result.Embedded, result.Stored, result.Failed, result.Deleted)
+	return nil
 }
 
-func (s *Scheduler) fetchAndProcess(ctx context.Context, source datasources.DataSource, name string) {
+// Status returns the sync state of every configured source, ordered by name
+func (s *Scheduler) Status() []SourceStatus {
 	s.mu.RLock()
-	since := s.lastSync[name]
-	s.mu.RUnlock()
+	defer s.mu.RUnlock()
+
+	statuses := make([]SourceStatus, 0, len(s.sources))
+	for name := range s.sources {
+		status := SourceStatus{
+			Source:   name,
+			Running:  s.running[name],
+			LastSync: s.lastSync[name],
+			Circuit:  s.breakers[name].State(),
+		}
+		if last, ok := s.history.Last(name); ok {
+			status.LastRun = &last
+		}
+		statuses = append(statuses, status)
+	}
+
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Source < statuses[j].Source
+	})
+	return statuses
+}
+
+// History returns up to limit recorded runs, newest first. An empty source matches all sources.
+func (s *Scheduler) History(source string, limit int) ([]RunRecord, error) {
+	if source != "" {
+		if _, ok := s.sources[source]; !ok {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownSource, source)
+		}
+	}
+	return s.history.List(source, limit), nil
+}
+
+// scheduledRun applies quiet hours and jitter before syncing a source
+func (s *Scheduler) scheduledRun(ctx context.Context, name string) {
+	if s.config.QuietHours.Contains(time.Now()) {
+		log.Printf("Skipping scheduled sync of %s during quiet hours", name)
+		return
+	}
+
+	if delay := jitter(s.config.Jitter); delay > 0 {
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return
+		}
+	}
+
+	if err := s.runSource(ctx, name, TriggerScheduled, false); err != nil {
+		log.Printf("Scheduled sync of %s failed: %v", name, err)
+	}
+}
+
+// runSource syncs a source synchronously unless its circuit breaker is open
+func (s *Scheduler) runSource(ctx context.Context, name, trigger string, full bool) error {
+	rec, err := s.begin(name, trigger, full, true)
+	if err != nil {
+		return err
+	}
+	return s.run(ctx, rec)
+}
+
+// begin marks a source as running and records the start of the run.
+// When checkBreaker is set, paused sources are rejected.
+func (s *Scheduler) begin(name, trigger string, full, checkBreaker bool) (RunRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.sources[name]; !ok {
+		return RunRecord{}, fmt.Errorf("%w: %s", ErrUnknownSource, name)
+	}
+	if s.running[name] {
+		return RunRecord{}, fmt.Errorf("%w: %s", ErrSyncInProgress, name)
+	}
+	if checkBreaker && !s.breakers[name].Allow() {
+		return RunRecord{}, fmt.Errorf("%w: %s", ErrSourcePaused, name)
+	}
+
+	s.running[name] = true
+	return s.history.begin(name, trigger, full), nil
+}
+
+// run executes a started run and records its outcome
+func (s *Scheduler) run(ctx context.Context, rec RunRecord) error {
+	defer func() {
+		s.mu.Lock()
+		s.running[rec.Source] = false
+		s.mu.Unlock()
+	}()
+
+	err := s.fetchAndProcess(ctx, s.sources[rec.Source], &rec)
+
+	rec.FinishedAt = time.Now()
+	breaker := s.breakers[rec.Source]
+	if err != nil {
+		rec.Error = err.Error()
+		if breaker.Failure(err) {
+			state := breaker.State()
+			log.Printf("Pausing %s after %d consecutive failed syncs until %v",
+				rec.Source, state.Failures, state.OpenUntil.Format(time.RFC3339))
+		}
+	} else {
+		breaker.Success()
+	}
+	s.history.finish(rec)
+
+	log.Printf("Sync of %s finished in %v: fetched=%d chunks=%d unchanged=%d embedded=%d stored=%d failed=%d deleted=%d",
+		rec.Source, rec.Duration().Round(time.Millisecond),
+		rec.Fetched, rec.Chunks, rec.Unchanged, rec.Embedded, rec.Stored, rec.Failed, rec.Deleted)
+
+	for _, hook := range s.hooks {
+		hook(ctx, rec)
+	}
+	return err
+}
+
+func (s *Scheduler) fetchAndProcess(ctx context.Context, source datasources.DataSource, rec *RunRecord) error {
+	name := rec.Source
+	started := rec.StartedAt
+
+	var since time.Time
+	if !rec.Full {
+		s.mu.RLock()
+		since = s.lastSync[name]
+		s.mu.RUnlock()
+	}
 
 	log.Printf("Fetching %v", source.Name())
 
-	docs, err := source.FetchData(ctx, since)
+	var docs []datasources.Document
+	err := retry.Do(ctx, s.config.Retry, "fetch "+name, func(ctx context.Context) error {
+		var err error
+		docs, err = source.FetchData(ctx, since)
+		return err
+	})
 	if err != nil {
-		// TODO: Add proper error handling/logging
-		return
+		return fmt.Errorf("failed to fetch data: %w", err)
 	}
+	rec.Fetched = len(docs)
 
 	log.Printf("Found %d number of docs", len(docs))
-	if len(docs) == 0 {
-		return
+
+	var fetched []string
+	if rec.Full {
+		fetched = make([]string, 0, len(docs))
+		for _, doc := range docs {
+			if !doc.Deleted {
+				fetched = append(fetched, doc.ID)
+			}
+		}
 	}
 
-	log.Printf("Creating embeddings for %v", source.Name())
-	vectors, err := s.embeddingService.CreateEmbeddings(ctx, docs)
+	result, err := s.pipeline.Process(ctx, name, docs)
+	rec.Chunks = result.Chunks
+	rec.Unchanged = result.Unchanged
+	rec.Embedded = result.Embedded
+	rec.Stored = result.Stored
+	rec.Failed = result.Failed
+	rec.Deleted = result.Deleted
 	if err != nil {
-		log.Printf("Error creating embedding: %v", err)
-		return
+		return err
 	}
-	log.Printf("Created %d embeddings for %v", len(vectors), source.Name())
 
-	log.Printf("Storing embeddings for %v", source.Name())
-	err = s.vectorDB.Store(ctx, docs, vectors)
-	if err != nil {
-		log.Printf("Error storing embedding: %v", err)
-		return
+	// A full sync replaces the source by dropping whatever it no longer returns.
+	// This waits until processing succeeded, so a failed run leaves the index as it was.
+	if rec.Full {
+		removed, err := s.vectorDB.DeleteMissing(ctx, name, fetched)
+		if err != nil {
+			return fmt.Errorf("failed to remove documents missing from full sync: %w", err)
+		}
+		rec.Deleted += removed
+	}
+
+	// Leave the sync time alone so chunks that failed to embed are fetched again;
+	// everything that was stored is skipped next time by its content hash
+	if result.Failed > 0 {
+		return nil
 	}
-	log.Printf("Stored %d embeddings for %v", len(vectors), source.Name())
 
 	s.mu.Lock()
-	s.lastSync[name] = time.Now()
+	s.lastSync[name] = started
 	s.mu.Unlock()
+	return nil
 }
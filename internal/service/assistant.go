@@ -2,9 +2,12 @@ package service
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"log"
+	"time"
 
+	"github.com/michaelgalloway/sophia/internal/actions"
 	"github.com/michaelgalloway/sophia/internal/database"
 	"github.com/michaelgalloway/sophia/internal/embeddings"
 	"github.com/sashabaranov/go-openai"
@@ -15,6 +18,23 @@ type Assistant struct {
 	openAIClient     *openai.Client
 	embeddingService embeddings.EmbeddingService
 	vectorDB         database.VectorDB
+	actions          *actions.Manager
+}
+
+// maxToolRounds bounds how many times the model may propose actions before it must answer
+const maxToolRounds = 3
+
+// systemPrompt is the assistant's standing instruction
+const systemPrompt = "You are a helpful assistant with access to the user's personal information. Use the context provided to give accurate and relevant answers."
+
+// actionsPrompt is added when the assistant can propose actions
+const actionsPrompt = " You can propose changes with the provided tools when the user asks for them. " +
+	"Proposed actions are only carried out after the user confirms them, so never say an action has been done; say it is awaiting confirmation."
+
+// Answer is the assistant's reply to a query, with any actions it proposed
+type Answer struct {
+	Text    string           `json:"answer"`
+	Actions []actions.Action `json:"actions"`
 }
 
 // Config holds the configuration for the Assistant service
@@ -23,11 +43,13 @@ type Config struct {
 	ModelName string
 }
 
-// NewAssistant creates a new instance of the Assistant service
+// NewAssistant creates a new instance of the Assistant service. actionManager
+// may be nil, in which case the assistant only answers questions.
 func NewAssistant(
 	config Config,
 	embeddingService embeddings.EmbeddingService,
 	vectorDB database.VectorDB,
+	actionManager *actions.Manager,
 ) *Assistant {
 	client := openai.NewClient(config.OpenAIKey)
 
@@ -35,59 +57,146 @@ func NewAssistant(
 		openAIClient:     client,
 		embeddingService: embeddingService,
 		vectorDB:         vectorDB,
+		actions:          actionManager,
 	}
 }
 
-// Ask processes a user query and returns a response
-func (a *Assistant) Ask(ctx context.Context, query string) (string, error) {
+// Ask processes a user query and returns a response. Actions the model
+// proposes are held for confirmation and returned with the answer.
+func (a *Assistant) Ask(ctx context.Context, query string) (*Answer, error) {
 	// Generate embedding for the query
 	queryVector, err := a.embeddingService.QueryEmbedding(ctx, query)
 	if err != nil {
-		return "", fmt.Errorf("failed to create query embedding: %w", err)
+		return nil, fmt.Errorf("failed to create query embedding: %w", err)
 	}
 
 	// Search for relevant documents
-	results, err := a.vectorDB.Search(ctx, queryVector, 25)
+	results, err := a.vectorDB.Search(ctx, queryVector, database.SearchOptions{Limit: 25})
 	if err != nil {
-		return "", fmt.Errorf("failed to search vector database: %w", err)
+		return nil, fmt.Errorf("failed to search vector database: %w", err)
 	}
 
 	// Construct prompt with context
 	prompt := constructPrompt(query, results)
 
-	// Generate response using OpenAI
-	resp, err := a.openAIClient.CreateChatCompletion(
-		ctx,
-		openai.ChatCompletionRequest{
-			Model: openai.GPT4oMini20240718,
-			Messages: []openai.ChatCompletionMessage{
-				{
-					Role:    openai.ChatMessageRoleSystem,
-					Content: "You are a helpful assistant with access to the user's personal information. Use the context provided to give accurate and relevant answers.",
-				},
-				{
-					Role:    openai.ChatMessageRoleUser,
-					Content: prompt,
-				},
+	system := systemPrompt
+	if a.actions != nil {
+		// Dates like "tomorrow afternoon" need to be resolved to exact times
+		system += actionsPrompt + fmt.Sprintf(" The current time is %s.", time.Now().Format(time.RFC1123Z))
+	}
+	messages := []openai.ChatCompletionMessage{
+		{
+			Role:    openai.ChatMessageRoleSystem,
+			Content: system,
+		},
+		{
+			Role:    openai.ChatMessageRoleUser,
+			Content: prompt,
+		},
+	}
+
+	answer := &Answer{Actions: []actions.Action{}}
+	for round := 0; ; round++ {
+		req := openai.ChatCompletionRequest{
+			Model:    openai.GPT4oMini20240718,
+			Messages: messages,
+		}
+		if a.actions != nil && round < maxToolRounds {
+			req.Tools = a.tools()
+		}
+
+		// Generate response using OpenAI
+		resp, err := a.openAIClient.CreateChatCompletion(ctx, req)
+		if err != nil {
+			log.Printf("failed to generate response: %v", err)
+			return nil, fmt.Errorf("failed to generate response: %w", err)
+		}
+		if len(resp.Choices) == 0 {
+			return nil, fmt.Errorf("failed to generate response: no choices returned")
+		}
+
+		msg := resp.Choices[0].Message
+		if len(msg.ToolCalls) == 0 {
+			answer.Text = msg.Content
+			return answer, nil
+		}
+
+		messages = append(messages, msg)
+		for _, call := range msg.ToolCalls {
+			messages = append(messages, openai.ChatCompletionMessage{
+				Role:       openai.ChatMessageRoleTool,
+				Content:    a.propose(ctx, call, answer),
+				ToolCallID: call.ID,
+			})
+		}
+	}
+}
+
+// Summarize has the chat model rewrite content following instructions, without
+// searching the index. It is used for generated digests such as briefings.
+func (a *Assistant) Summarize(ctx context.Context, instructions, content string) (string, error) {
+	resp, err := a.openAIClient.CreateChatCompletion(ctx, openai.ChatCompletionRequest{
+		Model: openai.GPT4oMini20240718,
+		Messages: []openai.ChatCompletionMessage{
+			{
+				Role:    openai.ChatMessageRoleSystem,
+				Content: instructions,
+			},
+			{
+				Role:    openai.ChatMessageRoleUser,
+				Content: content,
 			},
 		},
-	)
+	})
 	if err != nil {
-		log.Printf("failed to generate response: %w", err)
-		return "", fmt.Errorf("failed to generate response: %w", err)
+		return "", fmt.Errorf("failed to generate summary: %w", err)
+	}
+	if len(resp.Choices) == 0 {
+		return "", fmt.Errorf("failed to generate summary: no choices returned")
 	}
-
 	return resp.Choices[0].Message.Content, nil
 }
 
+// tools describes the available actions to the model
+func (a *Assistant) tools() []openai.Tool {
+	var tools []openai.Tool
+	for _, def := range a.actions.Definitions() {
+		tools = append(tools, openai.Tool{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        def.Name,
+				Description: def.Description,
+				Parameters:  def.Parameters,
+			},
+		})
+	}
+	return tools
+}
+
+// propose holds a tool call for confirmation and returns the result reported back to the model
+func (a *Assistant) propose(ctx context.Context, call openai.ToolCall, answer *Answer) string {
+	action, err := a.actions.Propose(ctx, call.Function.Name, json.RawMessage(call.Function.Arguments))
+	if err != nil {
+		return fmt.Sprintf("The action could not be proposed: %v", err)
+	}
+
+	answer.Actions = append(answer.Actions, *action)
+	return fmt.Sprintf("Proposed action %s: %s. It is awaiting the user's confirmation.", action.ID, action.Summary)
+}
+
 func constructPrompt(query string, results []database.SearchResult) string {
 	prompt := fmt.Sprintf("Question: %s\n\nRelevant Context:\n", query)
 
 	for i, result := range results {
-		prompt += fmt.Sprintf("\n%d. From %s (%s):\n%s\n",
+		id := result.Document.ParentID
+		if id == "" {
+			id = result.Document.ID
+		}
+		prompt += fmt.Sprintf("\n%d. From %s (%s, id %s):\n%s\n",
 			i+1,
 			result.Document.Source,
 			result.Document.Timestamp.Format("2006-01-02 15:04:05"),
+			id,
 			result.Document.Content,
 		)
 	}
@@ -0,0 +1,196 @@
+package watch
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/michaelgalloway/sophia/internal/datasources"
+	"github.com/michaelgalloway/sophia/internal/embeddings"
+)
+
+// maxSnippet bounds the excerpt shown for each match
+const maxSnippet = 200
+
+// Observe is a pipeline.StoredHook that checks newly stored chunks against every
+// watch. Matches are held until the next Flush; only documents active since the
+// watch was created, and since it last notified about them, count as new.
+func (w *Watcher) Observe(ctx context.Context, source string, docs []datasources.Document, vectors []embeddings.Vector) {
+	w.mu.Lock()
+	watches := make([]Watch, len(w.watches))
+	for i, watch := range w.watches {
+		watches[i] = *watch
+	}
+	w.mu.Unlock()
+
+	for _, watch := range watches {
+		if len(watch.Sources) > 0 && !contains(watch.Sources, source) {
+			continue
+		}
+
+		var query embeddings.Vector
+		if watch.Query != "" {
+			var err error
+			if query, err = w.vector(ctx, watch); err != nil {
+				log.Printf("Skipping watch %q: %v", watch.Name, err)
+				continue
+			}
+		}
+
+		for i, doc := range docs {
+			if doc.Timestamp.Before(watch.CreatedAt) || !matchesMetadata(doc, watch.Metadata) {
+				continue
+			}
+
+			var score float64
+			if query != nil {
+				score = cosine(query, vectors[i])
+				if score < watch.Threshold {
+					continue
+				}
+			}
+
+			w.add(watch.ID, Match{
+				DocumentID: doc.ParentID,
+				Source:     doc.Source,
+				Title:      doc.Title,
+				URL:        doc.URL,
+				Time:       doc.Timestamp,
+				Score:      score,
+				Snippet:    snippet(doc.Content),
+			})
+		}
+	}
+}
+
+// add queues a match unless it was already notified, keeping the best-scoring
+// chunk of each document
+func (w *Watcher) add(watchID string, match Match) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if last, ok := w.notified[watchID][match.DocumentID]; ok && !match.Time.After(last) {
+		return
+	}
+
+	pending := w.pending[watchID]
+	if pending == nil {
+		pending = make(map[string]Match)
+		w.pending[watchID] = pending
+	}
+	if existing, ok := pending[match.DocumentID]; ok && existing.Score >= match.Score {
+		return
+	}
+	pending[match.DocumentID] = match
+}
+
+// vector returns the embedding of a watch query, computing it for watches
+// loaded from disk
+func (w *Watcher) vector(ctx context.Context, watch Watch) (embeddings.Vector, error) {
+	w.mu.Lock()
+	vector, ok := w.vectors[watch.ID]
+	w.mu.Unlock()
+	if ok {
+		return vector, nil
+	}
+
+	vector, err := w.embedder.QueryEmbedding(ctx, watch.Query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed watch query: %w", err)
+	}
+
+	w.mu.Lock()
+	w.vectors[watch.ID] = vector
+	w.mu.Unlock()
+	return vector, nil
+}
+
+// matchesMetadata reports whether every filter value occurs in the document's metadata
+func matchesMetadata(doc datasources.Document, filter map[string]string) bool {
+	for key, want := range filter {
+		want = strings.ToLower(want)
+		found := false
+		for _, value := range metadataStrings(doc.Metadata[key]) {
+			if strings.Contains(strings.ToLower(value), want) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// metadataStrings flattens a metadata value into the strings it holds
+func metadataStrings(value interface{}) []string {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case string:
+		return []string{v}
+	case []string:
+		return v
+	case []interface{}:
+		var values []string
+		for _, item := range v {
+			values = append(values, metadataStrings(item)...)
+		}
+		return values
+	default:
+		return []string{fmt.Sprint(v)}
+	}
+}
+
+// describeFilter names a filter-only watch, e.g. "from: legal@"
+func describeFilter(filter map[string]string) string {
+	parts := make([]string, 0, len(filter))
+	for key, value := range filter {
+		parts = append(parts, key+": "+value)
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ", ")
+}
+
+func cosine(a, b embeddings.Vector) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
+
+// snippet returns the start of a chunk on one line
+func snippet(content string) string {
+	content = strings.Join(strings.Fields(content), " ")
+	if len(content) <= maxSnippet {
+		return content
+	}
+	cut := maxSnippet
+	for cut > 0 && !utf8.RuneStart(content[cut]) {
+		cut--
+	}
+	return content[:cut] + "…"
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
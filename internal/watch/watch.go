@@ -0,0 +1,373 @@
+package watch
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/michaelgalloway/sophia/internal/embeddings"
+	"github.com/michaelgalloway/sophia/internal/notify"
+	"github.com/michaelgalloway/sophia/internal/scheduler"
+)
+
+const (
+	// DefaultThreshold is the similarity a chunk needs to match a watch query
+	DefaultThreshold = 0.4
+
+	// maxRecent is the number of fired matches kept per watch
+	maxRecent = 20
+
+	// maxNotified caps the matches listed in one notification
+	maxNotified = 10
+
+	// notifiedRetention is how long a notified document is remembered, so that
+	// re-storing it unchanged, as a full sync does, doesn't notify again
+	notifiedRetention = 30 * 24 * time.Hour
+)
+
+var (
+	// ErrNotFound is returned for an unknown watch ID
+	ErrNotFound = errors.New("watch not found")
+
+	// ErrInvalid is returned for a watch that can never match
+	ErrInvalid = errors.New("invalid watch")
+)
+
+// Watch is a standing question or filter evaluated against newly stored documents
+type Watch struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// Query is matched semantically; chunks need a similarity of at least Threshold
+	Query     string  `json:"query,omitempty"`
+	Threshold float64 `json:"threshold,omitempty"`
+	// Sources limits the watch to these data sources
+	Sources []string `json:"sources,omitempty"`
+	// Metadata requires each key's value to contain the given text, ignoring case,
+	// e.g. {"from": "legal@example.com"}. List values match if any element does.
+	Metadata map[string]string `json:"metadata,omitempty"`
+
+	CreatedAt   time.Time `json:"created_at"`
+	LastFiredAt time.Time `json:"last_fired_at,omitempty"`
+	// Fired counts the matches notified so far
+	Fired int `json:"fired"`
+}
+
+// Match is a document that satisfied a watch
+type Match struct {
+	DocumentID string    `json:"document_id"`
+	Source     string    `json:"source"`
+	Title      string    `json:"title"`
+	URL        string    `json:"url,omitempty"`
+	Time       time.Time `json:"time"`
+	Score      float64   `json:"score,omitempty"`
+	Snippet    string    `json:"snippet"`
+}
+
+// Status is a watch with the matches it fired most recently, newest first
+type Status struct {
+	Watch
+	Recent []Match `json:"recent"`
+}
+
+// watchFile is the saved state: the watches, and for each the documents already
+// notified and its recent matches, so a restart followed by a full sync doesn't
+// repeat alerts
+type watchFile struct {
+	Watches  []*Watch                        `json:"watches"`
+	Notified map[string]map[string]time.Time `json:"notified,omitempty"`
+	Recent   map[string][]Match              `json:"recent,omitempty"`
+}
+
+// Watcher keeps the saved watches, matches them against stored chunks and
+// notifies about new matches after each sync
+type Watcher struct {
+	path     string
+	embedder embeddings.EmbeddingService
+	notifier *notify.Notifier
+
+	mu       sync.Mutex
+	watches  []*Watch
+	vectors  map[string]embeddings.Vector
+	pending  map[string]map[string]Match
+	notified map[string]map[string]time.Time
+	recent   map[string][]Match
+}
+
+// NewWatcher loads the watches saved at path, creating its directory if needed.
+// notifier may be nil, in which case matches are only kept for the API.
+func NewWatcher(path string, embedder embeddings.EmbeddingService, notifier *notify.Notifier) (*Watcher, error) {
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create watches directory: %w", err)
+		}
+	}
+
+	w := &Watcher{
+		path:     path,
+		embedder: embedder,
+		notifier: notifier,
+		vectors:  make(map[string]embeddings.Vector),
+		pending:  make(map[string]map[string]Match),
+		notified: make(map[string]map[string]time.Time),
+		recent:   make(map[string][]Match),
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return w, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read watches: %w", err)
+	}
+
+	var file watchFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse watches: %w", err)
+	}
+	w.watches = file.Watches
+	if file.Notified != nil {
+		w.notified = file.Notified
+	}
+	if file.Recent != nil {
+		w.recent = file.Recent
+	}
+	return w, nil
+}
+
+// List returns every watch with its recent matches
+func (w *Watcher) List() []Status {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	result := make([]Status, 0, len(w.watches))
+	for _, watch := range w.watches {
+		recent := append([]Match{}, w.recent[watch.ID]...)
+		result = append(result, Status{Watch: *watch, Recent: recent})
+	}
+	return result
+}
+
+// Create validates and saves a new watch, embedding its query
+func (w *Watcher) Create(ctx context.Context, watch Watch) (*Watch, error) {
+	watch.Query = strings.TrimSpace(watch.Query)
+	if watch.Query == "" && len(watch.Metadata) == 0 {
+		return nil, fmt.Errorf("%w: a query or metadata filter is required", ErrInvalid)
+	}
+	if watch.Threshold < 0 || watch.Threshold > 1 {
+		return nil, fmt.Errorf("%w: threshold must be between 0 and 1", ErrInvalid)
+	}
+	if watch.Query != "" && watch.Threshold == 0 {
+		watch.Threshold = DefaultThreshold
+	}
+	if watch.Name == "" {
+		watch.Name = watch.Query
+	}
+	if watch.Name == "" {
+		watch.Name = describeFilter(watch.Metadata)
+	}
+
+	var vector embeddings.Vector
+	if watch.Query != "" {
+		var err error
+		vector, err = w.embedder.QueryEmbedding(ctx, watch.Query)
+		if err != nil {
+			return nil, fmt.Errorf("failed to embed watch query: %w", err)
+		}
+	}
+
+	id, err := newID()
+	if err != nil {
+		return nil, err
+	}
+	watch.ID = id
+	watch.CreatedAt = time.Now()
+	watch.LastFiredAt = time.Time{}
+	watch.Fired = 0
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.watches = append(w.watches, &watch)
+	if vector != nil {
+		w.vectors[watch.ID] = vector
+	}
+	if err := w.save(); err != nil {
+		w.watches = w.watches[:len(w.watches)-1]
+		delete(w.vectors, watch.ID)
+		return nil, err
+	}
+	return &watch, nil
+}
+
+// Delete removes a watch
+func (w *Watcher) Delete(id string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for i, watch := range w.watches {
+		if watch.ID != id {
+			continue
+		}
+		w.watches = append(w.watches[:i:i], w.watches[i+1:]...)
+		delete(w.vectors, id)
+		delete(w.pending, id)
+		delete(w.notified, id)
+		delete(w.recent, id)
+		return w.save()
+	}
+	return fmt.Errorf("%w: %s", ErrNotFound, id)
+}
+
+// RunFinished is a scheduler.RunHook that notifies about the matches collected
+// so far, including ones from documents ingested between syncs
+func (w *Watcher) RunFinished(ctx context.Context, _ scheduler.RunRecord) {
+	w.Flush(ctx)
+}
+
+// Flush sends one notification per watch with new matches
+func (w *Watcher) Flush(ctx context.Context) {
+	now := time.Now()
+
+	type firing struct {
+		watch   Watch
+		matches []Match
+	}
+	var firings []firing
+
+	w.mu.Lock()
+	for _, watch := range w.watches {
+		pending := w.pending[watch.ID]
+		if len(pending) == 0 {
+			continue
+		}
+		delete(w.pending, watch.ID)
+
+		matches := make([]Match, 0, len(pending))
+		notified := w.notified[watch.ID]
+		if notified == nil {
+			notified = make(map[string]time.Time)
+			w.notified[watch.ID] = notified
+		}
+		for _, match := range pending {
+			matches = append(matches, match)
+			notified[match.DocumentID] = match.Time
+		}
+		for id, t := range notified {
+			if now.Sub(t) > notifiedRetention {
+				delete(notified, id)
+			}
+		}
+		sort.Slice(matches, func(i, j int) bool {
+			if matches[i].Score != matches[j].Score {
+				return matches[i].Score > matches[j].Score
+			}
+			return matches[i].Time.After(matches[j].Time)
+		})
+
+		recent := append(append([]Match{}, matches...), w.recent[watch.ID]...)
+		if len(recent) > maxRecent {
+			recent = recent[:maxRecent]
+		}
+		w.recent[watch.ID] = recent
+
+		watch.LastFiredAt = now
+		watch.Fired += len(matches)
+		firings = append(firings, firing{watch: *watch, matches: matches})
+	}
+	if len(firings) > 0 {
+		if err := w.save(); err != nil {
+			log.Printf("Failed to save watches: %v", err)
+		}
+	}
+	w.mu.Unlock()
+
+	if w.notifier == nil || !w.notifier.Enabled() {
+		return
+	}
+	for _, f := range firings {
+		if err := w.notifier.Send(ctx, message(f.watch, f.matches)); err != nil {
+			log.Printf("Failed to send watch notification for %q: %v", f.watch.Name, err)
+		}
+	}
+}
+
+// message renders the matches of one watch as a notification
+func message(watch Watch, matches []Match) notify.Message {
+	title := fmt.Sprintf("%d new matches for %q", len(matches), watch.Name)
+	if len(matches) == 1 {
+		title = fmt.Sprintf("New match for %q", watch.Name)
+	}
+
+	var sb strings.Builder
+	for i, match := range matches {
+		if i == maxNotified {
+			fmt.Fprintf(&sb, "\n…and %d more", len(matches)-maxNotified)
+			break
+		}
+		line := fmt.Sprintf("- %s (%s", match.Title, match.Source)
+		if match.Score > 0 {
+			line += fmt.Sprintf(", %.2f", match.Score)
+		}
+		line += ")"
+		if match.URL != "" {
+			line += " " + match.URL
+		}
+		if i > 0 {
+			sb.WriteString("\n")
+		}
+		sb.WriteString(line)
+		if match.Snippet != "" {
+			sb.WriteString("\n  " + match.Snippet)
+		}
+	}
+
+	return notify.Message{
+		Kind:  "watch",
+		Title: title,
+		Body:  sb.String(),
+		Data: map[string]interface{}{
+			"watch":   watch,
+			"matches": matches,
+		},
+	}
+}
+
+// save writes the watches and what they notified to disk, replacing the file
+// atomically. The caller must hold w.mu.
+func (w *Watcher) save() error {
+	data, err := json.MarshalIndent(watchFile{
+		Watches:  w.watches,
+		Notified: w.notified,
+		Recent:   w.recent,
+	}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode watches: %w", err)
+	}
+
+	tmp := w.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write watches: %w", err)
+	}
+	if err := os.Rename(tmp, w.path); err != nil {
+		return fmt.Errorf("failed to save watches: %w", err)
+	}
+	return nil
+}
+
+func newID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate watch ID: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
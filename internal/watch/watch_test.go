@@ -0,0 +1,82 @@
+package watch
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/michaelgalloway/sophia/internal/datasources"
+	"github.com/michaelgalloway/sophia/internal/embeddings"
+	"github.com/michaelgalloway/sophia/internal/notify"
+)
+
+// recordingSink keeps every message it is sent
+type recordingSink struct {
+	mu   sync.Mutex
+	sent []notify.Message
+}
+
+func (s *recordingSink) Name() string { return "recording" }
+
+func (s *recordingSink) Send(ctx context.Context, msg notify.Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sent = append(s.sent, msg)
+	return nil
+}
+
+func TestRestartDoesNotRepeatAlerts(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "watches.json")
+	sink := &recordingSink{}
+	notifier := notify.NewNotifier(notify.Config{}, sink)
+
+	w, err := NewWatcher(path, nil, notifier)
+	if err != nil {
+		t.Fatalf("NewWatcher() error = %v", err)
+	}
+	watch, err := w.Create(ctx, Watch{Metadata: map[string]string{"from": "legal@example.com"}})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	doc := datasources.Document{
+		ID:        "18c2f4a9b1e3d7c5",
+		ParentID:  "18c2f4a9b1e3d7c5",
+		Source:    "gmail",
+		Title:     "Contract renewal",
+		Timestamp: time.Now(),
+		Metadata:  map[string]interface{}{"from": "Legal <legal@example.com>"},
+	}
+	ingest := func(w *Watcher, doc datasources.Document) {
+		w.Observe(ctx, "gmail", []datasources.Document{doc}, []embeddings.Vector{nil})
+		w.Flush(ctx)
+	}
+
+	ingest(w, doc)
+	if len(sink.sent) != 1 {
+		t.Fatalf("sent %d notifications, want 1", len(sink.sent))
+	}
+
+	// A restart starts with a full sync, which stores the same document again
+	restarted, err := NewWatcher(path, nil, notifier)
+	if err != nil {
+		t.Fatalf("NewWatcher() after restart error = %v", err)
+	}
+	ingest(restarted, doc)
+	if len(sink.sent) != 1 {
+		t.Errorf("sent %d notifications after restart, want no repeat", len(sink.sent))
+	}
+	if status := restarted.List(); len(status) != 1 || status[0].ID != watch.ID || len(status[0].Recent) != 1 {
+		t.Errorf("List() after restart = %+v, want the watch with its match", status)
+	}
+
+	// New activity on the document is still reported
+	doc.Timestamp = doc.Timestamp.Add(time.Hour)
+	ingest(restarted, doc)
+	if len(sink.sent) != 2 {
+		t.Errorf("sent %d notifications after new activity, want 2", len(sink.sent))
+	}
+}